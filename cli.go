@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+	"git.luzifer.io/luzifer/accounting/pkg/exporter"
+	"git.luzifer.io/luzifer/accounting/pkg/importer"
+)
+
+type (
+	cliCommand func(dbc *database.Client, args []string) error
+)
+
+var (
+	cliCommands = map[string]cliCommand{
+		"backup":       cliBackup,
+		"create-admin": cliCreateAdmin,
+		"export":       cliExport,
+		"import":       cliImport,
+		"migrate":      cliMigrate,
+		"migrate-db":   cliMigrateDB,
+		"purge-trash":  cliPurgeTrash,
+		"restore":      cliRestore,
+	}
+
+	cliImportParsers = map[string]func(io.Reader) (importer.Statement, error){
+		"camt":  importer.ParseCAMT,
+		"mt940": importer.ParseMT940,
+		"ofx":   importer.ParseOFX,
+		"qif":   importer.ParseQIF,
+	}
+)
+
+func runCLICommand(dbc *database.Client, args []string) error {
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return cmd(dbc, args[1:])
+}
+
+// cliBackup writes a backup of the whole database to stdout:
+// backup
+func cliBackup(dbc *database.Client, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: backup")
+	}
+
+	b, err := dbc.Backup()
+	if err != nil {
+		return fmt.Errorf("creating backup: %w", err)
+	}
+
+	if err = json.NewEncoder(os.Stdout).Encode(b); err != nil {
+		return fmt.Errorf("encoding backup: %w", err)
+	}
+
+	return nil
+}
+
+// cliBudgetClient scopes the client to the budget with the given ID
+func cliBudgetClient(dbc *database.Client, budget string) (*database.Client, error) {
+	id, err := uuid.Parse(budget)
+	if err != nil {
+		return nil, fmt.Errorf("parsing budget id: %w", err)
+	}
+
+	if dbc, err = dbc.ForBudget(id); err != nil {
+		return nil, fmt.Errorf("getting budget: %w", err)
+	}
+
+	return dbc, nil
+}
+
+// cliCreateAdmin creates a user with admin permissions reading the
+// password from the first line of stdin:
+// create-admin <username>
+func cliCreateAdmin(dbc *database.Client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: create-admin <username>")
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("reading password: %w", err)
+		}
+		return errors.New("no password given on stdin")
+	}
+
+	u, err := dbc.CreateUser(args[0], strings.TrimRight(scanner.Text(), "\r"), true)
+	if err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+
+	logrus.WithField("username", u.Username).Info("admin created")
+	return nil
+}
+
+// cliExport writes the transactions up to now to stdout:
+// export beancount [currency] | export ledger | export qif <account-id>
+func cliExport(dbc *database.Client, args []string) error {
+	const usage = "usage: export beancount [currency] | export ledger | export qif <account-id>"
+
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch {
+	case args[0] == "beancount" && len(args) <= 2: //revive:disable-line:add-constant // number of arguments
+		currency := exporter.DefaultCurrency
+		if len(args) == 2 { //revive:disable-line:add-constant // number of arguments
+			currency = args[1]
+		}
+
+		if err := exporter.WriteBeancount(os.Stdout, dbc, time.Time{}, time.Now(), currency); err != nil {
+			return fmt.Errorf("exporting beancount: %w", err)
+		}
+
+	case args[0] == "ledger" && len(args) == 1:
+		if err := exporter.WriteLedger(os.Stdout, dbc, time.Time{}, time.Now()); err != nil {
+			return fmt.Errorf("exporting journal: %w", err)
+		}
+
+	case args[0] == "qif" && len(args) == 2: //revive:disable-line:add-constant // number of arguments
+		acctID, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("parsing account id: %w", err)
+		}
+
+		if err = exporter.WriteQIF(os.Stdout, dbc, acctID, time.Time{}, time.Now()); err != nil {
+			return fmt.Errorf("exporting account: %w", err)
+		}
+
+	default:
+		return errors.New(usage)
+	}
+
+	return nil
+}
+
+// cliImport imports a statement file into an account:
+// import <format> <account-id> <file>
+func cliImport(dbc *database.Client, args []string) error {
+	if len(args) != 3 { //revive:disable-line:add-constant // number of arguments
+		return errors.New("usage: import <format> <account-id> <file>")
+	}
+
+	parse, ok := cliImportParsers[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown import format %q", args[0])
+	}
+
+	acctID, err := uuid.Parse(args[1])
+	if err != nil {
+		return fmt.Errorf("parsing account id: %w", err)
+	}
+
+	f, err := os.Open(args[2])
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logrus.WithError(err).Error("closing import file (leaked fd)")
+		}
+	}()
+
+	stmt, err := parse(f)
+	if err != nil {
+		return fmt.Errorf("parsing statement: %w", err)
+	}
+
+	res, err := importer.Import(dbc, acctID, stmt)
+	if err != nil {
+		return fmt.Errorf("importing statement: %w", err)
+	}
+
+	logger := logrus.
+		WithField("created", res.Created).
+		WithField("matched", res.Matched).
+		WithField("skipped", res.Skipped).
+		WithField("account_balance", res.AccountBalance)
+
+	if res.OpeningDifference != nil {
+		logger = logger.
+			WithField("opening_balance", *res.OpeningBalance).
+			WithField("opening_difference", *res.OpeningDifference)
+
+		if *res.OpeningDifference != 0 {
+			logger.Warn("statement imported, account balance does not match statement opening balance")
+			return nil
+		}
+	}
+
+	if res.Difference != nil {
+		logger = logger.
+			WithField("ledger_balance", *res.LedgerBalance).
+			WithField("difference", *res.Difference)
+
+		if *res.Difference != 0 {
+			logger.Warn("statement imported, account balance does not match statement")
+			return nil
+		}
+	}
+
+	logger.Info("statement imported")
+	return nil
+}
+
+// cliMigrate shows or changes the version of the database schema:
+// migrate status | migrate up | migrate down
+func cliMigrate(dbc *database.Client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate status | migrate up | migrate down")
+	}
+
+	switch args[0] {
+	case "down":
+		m, err := dbc.MigrateDown()
+		if err != nil {
+			return fmt.Errorf("reverting migration: %w", err)
+		}
+
+		logrus.WithField("version", m.Version).WithField("name", m.Name).Info("migration reverted")
+
+	case "status":
+		migrations, err := dbc.ListMigrations()
+		if err != nil {
+			return fmt.Errorf("listing migrations: %w", err)
+		}
+
+		for _, m := range migrations {
+			state := "pending"
+			if m.AppliedAt != nil {
+				state = m.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(os.Stdout, "%04d\t%s\t%s\n", m.Version, m.Name, state)
+		}
+
+	case "up":
+		applied, err := dbc.MigrateUp()
+		if err != nil {
+			return fmt.Errorf("applying migrations: %w", err)
+		}
+
+		for _, m := range applied {
+			logrus.WithField("version", m.Version).WithField("name", m.Name).Info("migration applied")
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
+
+// cliMigrateDB copies all data from one database into another empty
+// one, both given through the --from(-type) and --to(-type) flags. The
+// source is not modified and must have all migrations applied:
+// migrate-db
+func cliMigrateDB(_ *database.Client, args []string) error {
+	if len(args) != 0 || cfg.MigrateFrom == "" || cfg.MigrateFromType == "" || cfg.MigrateTo == "" || cfg.MigrateToType == "" {
+		return errors.New("usage: migrate-db --from-type <type> --from <dsn> --to-type <type> --to <dsn>")
+	}
+
+	src, err := database.Open(cfg.MigrateFromType, cfg.MigrateFrom)
+	if err != nil {
+		return fmt.Errorf("connecting to source database: %w", err)
+	}
+
+	migrations, err := src.ListMigrations()
+	if err != nil {
+		return fmt.Errorf("listing source migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.AppliedAt == nil {
+			return fmt.Errorf("source database has pending migration %d (%s), run \"migrate up\" on it first", m.Version, m.Name)
+		}
+	}
+
+	dst, err := database.New(cfg.MigrateToType, cfg.MigrateTo)
+	if err != nil {
+		return fmt.Errorf("connecting to target database: %w", err)
+	}
+
+	if err = src.CopyTo(dst); err != nil {
+		return fmt.Errorf("migrating database: %w", err)
+	}
+
+	logrus.
+		WithField("from", cfg.MigrateFromType).
+		WithField("to", cfg.MigrateToType).
+		Info("database migrated")
+	return nil
+}
+
+// cliPurgeTrash permanently removes the accounts and transactions of
+// all budgets deleted longer than the given duration (e.g. 720h) ago:
+// purge-trash <max-age>
+func cliPurgeTrash(dbc *database.Client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: purge-trash <max-age>")
+	}
+
+	maxAge, err := time.ParseDuration(args[0])
+	if err != nil {
+		return fmt.Errorf("parsing max-age: %w", err)
+	}
+
+	if maxAge <= 0 {
+		// Would purge everything including records deleted just now
+		return errors.New("max-age must be positive")
+	}
+
+	n, err := dbc.PurgeTrash(time.Now().Add(-maxAge))
+	if err != nil {
+		return fmt.Errorf("purging trash: %w", err)
+	}
+
+	logrus.WithField("records", n).Info("trash purged")
+	return nil
+}
+
+// cliRestore loads a backup file into the database, overwriting
+// existing data only when "force" is given:
+// restore <file> [force]
+func cliRestore(dbc *database.Client, args []string) error {
+	const usage = "usage: restore <file> [force]"
+
+	if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "force") { //revive:disable-line:add-constant // number of arguments
+		return errors.New(usage)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logrus.WithError(err).Error("closing backup file (leaked fd)")
+		}
+	}()
+
+	var b database.Backup
+	if err = json.NewDecoder(f).Decode(&b); err != nil {
+		return fmt.Errorf("parsing backup: %w", err)
+	}
+
+	if err = dbc.Restore(b, len(args) == 2); err != nil { //revive:disable-line:add-constant // number of arguments
+		return fmt.Errorf("restoring backup: %w", err)
+	}
+
+	logrus.
+		WithField("accounts", len(b.Accounts)).
+		WithField("transactions", len(b.Transactions)).
+		Info("backup restored")
+	return nil
+}
@@ -1 +1,2 @@
+export const defaultBudget = '00000000-0000-0000-0001-000000000001'
 export const unallocatedMoneyAcc = '00000000-0000-0000-0000-000000000001'
@@ -1,3 +1,9 @@
+import { defaultBudget } from './constants'
+
+export function budgetAPIPath(path: string): string {
+  return `/api/budgets/${defaultBudget}${path}`
+}
+
 export function classFromNumber(num: number, extraClasses: string[] = [], positiveClass: null | string = null): string {
   const classes = extraClasses || []
   if (num < 0) {
@@ -50,6 +56,11 @@ export async function requestAPI<T>(method: string, apiPath: string, payload?: a
     method,
   })
 
+  if (resp.status === 401 && apiPath !== '/api/login') {
+    // Session expired or never existed: the login will bring the user back
+    window.location.href = '/login'
+  }
+
   if (resp.status > 299) {
     throw new Error(`non-2xx status code: ${resp.status}`)
   }
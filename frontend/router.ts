@@ -3,10 +3,12 @@ import { createRouter, createWebHistory, type RouteRecordRaw } from 'vue-router'
 
 import accountOverview from './views/accountOverview.vue'
 import budgetDashboard from './views/budgetDashboard.vue'
+import login from './views/login.vue'
 
 const routes: RouteRecordRaw[] = [
   { component: budgetDashboard, name: 'budget', path: '/' },
   { component: accountOverview, name: 'account-transactions', path: '/accounts/:accountId', props: true },
+  { component: login, name: 'login', path: '/login' },
 ]
 
 const router = createRouter({
@@ -2,12 +2,32 @@ export type AccountType = 'budget' | 'category' | 'tracking'
 
 export interface Account {
   balance: number
+  goal?: GoalStatus
+  group: string | null
   hidden: boolean
   id: string
   name: string
+  sortOrder: number
   type: AccountType
 }
 
+export interface AccountGroup {
+  id: string
+  name: string
+  sortOrder: number
+}
+
+export type GoalType = 'monthlyFunding' | 'monthlySpending' | 'targetBalance'
+
+export interface GoalStatus {
+  amount: number
+  category: string
+  id: string
+  targetDate?: string
+  type: GoalType
+  underfunded: number
+}
+
 export interface DateRange {
   end?: Date
   start?: Date
@@ -25,11 +45,20 @@ export interface Transaction {
   cleared: boolean
   description: string
   id: string
+  importId?: string
   payee: string
   reconciled: boolean
+  splits?: TransactionSplit[]
   time: string
 }
 
+export interface TransactionSplit {
+  amount: number
+  category: string | null
+  description: string
+  id?: string
+}
+
 export interface JsonPatchOperation {
   op: 'replace'
   path: string
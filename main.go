@@ -19,11 +19,22 @@ import (
 
 var (
 	cfg = struct {
-		DatabaseConnection string `flag:"database-connection" default:"file::memory:?cache=shared" description:"Connection string for the selected database type"`
-		DatabaseType       string `flag:"database-type" default:"sqlite" description:"Type of the database to connect to (postgres, sqlite)"`
-		Listen             string `flag:"listen" default:":3000" description:"Port/IP to listen on"`
-		LogLevel           string `flag:"log-level" default:"info" description:"Log level (debug, info, warn, error, fatal)"`
-		VersionAndExit     bool   `flag:"version" default:"false" description:"Prints current version and exits"`
+		Budget             string        `flag:"budget" default:"" description:"ID of the budget to use in CLI commands (defaults to the default budget)"`
+		DatabaseConnection string        `flag:"database-connection" default:"file::memory:?cache=shared" description:"Connection string for the selected database type"`
+		DatabaseType       string        `flag:"database-type" default:"sqlite" description:"Type of the database to connect to (postgres, sqlite)"`
+		Listen             string        `flag:"listen" default:":3000" description:"Port/IP to listen on"`
+		MigrateFrom        string        `flag:"from" default:"" description:"Connection string of the database to copy from (migrate-db)"`
+		MigrateFromType    string        `flag:"from-type" default:"" description:"Type of the database to copy from (migrate-db)"`
+		MigrateTo          string        `flag:"to" default:"" description:"Connection string of the database to copy to (migrate-db)"`
+		MigrateToType      string        `flag:"to-type" default:"" description:"Type of the database to copy to (migrate-db)"`
+		LogLevel           string        `flag:"log-level" default:"info" description:"Log level (debug, info, warn, error, fatal)"`
+		OIDCAllowedGroups  []string      `flag:"oidc-allowed-groups" default:"" description:"Groups (in the groups claim) allowed to log in through OIDC (empty: all users)"`
+		OIDCClientID       string        `flag:"oidc-client-id" default:"" description:"Client ID registered at the OIDC provider"`
+		OIDCClientSecret   string        `flag:"oidc-client-secret" default:"" description:"Client secret registered at the OIDC provider"`
+		OIDCIssuer         string        `flag:"oidc-issuer" default:"" description:"Issuer URL of the OIDC provider to log in with (empty: disabled)"`
+		OIDCRedirectURL    string        `flag:"oidc-redirect-url" default:"" description:"Callback URL registered at the OIDC provider (empty: derived from request)"`
+		ScheduleInterval   time.Duration `flag:"schedule-interval" default:"1h" description:"How often to create due scheduled transactions"`
+		VersionAndExit     bool          `flag:"version" default:"false" description:"Prints current version and exits"`
 	}{}
 
 	version = "dev"
@@ -55,14 +66,45 @@ func main() {
 		os.Exit(0)
 	}
 
-	dbc, err := database.New(cfg.DatabaseType, cfg.DatabaseConnection)
+	dbc, err := database.Open(cfg.DatabaseType, cfg.DatabaseConnection)
 	if err != nil {
 		logrus.WithError(err).Fatal("connecting to database")
 	}
 
+	args := rconfig.Args()[1:]
+	if len(args) == 0 || args[0] != "migrate" {
+		// The migrate command manages the schema on its own
+		if _, err = dbc.MigrateUp(); err != nil {
+			logrus.WithError(err).Fatal("migrating database schema")
+		}
+	}
+
+	if len(args) > 0 {
+		if cfg.Budget != "" {
+			if dbc, err = cliBudgetClient(dbc, cfg.Budget); err != nil {
+				logrus.WithError(err).Fatal("selecting budget")
+			}
+		}
+
+		if err = runCLICommand(dbc, args); err != nil {
+			logrus.WithError(err).Fatal("executing command")
+		}
+		return
+	}
+
+	go materializeScheduledTransactions(dbc, cfg.ScheduleInterval)
+
 	router := mux.NewRouter()
-	api.RegisterHandler(router.PathPrefix("/api").Subrouter(), dbc, logrus.StandardLogger())
-	frontend.RegisterHandler(router, logrus.StandardLogger())
+	if err = api.RegisterHandler(router.PathPrefix("/api").Subrouter(), dbc, logrus.StandardLogger(), api.OIDCConfig{
+		Issuer:        cfg.OIDCIssuer,
+		ClientID:      cfg.OIDCClientID,
+		ClientSecret:  cfg.OIDCClientSecret,
+		RedirectURL:   cfg.OIDCRedirectURL,
+		AllowedGroups: cfg.OIDCAllowedGroups,
+	}); err != nil {
+		logrus.WithError(err).Fatal("registering API")
+	}
+	frontend.RegisterHandler(router, logrus.StandardLogger(), api.SessionRequired(dbc, logrus.StandardLogger()))
 
 	var hdl http.Handler = router
 	hdl = httphelper.GzipHandler(hdl)
@@ -83,3 +125,18 @@ func main() {
 		logrus.WithError(err).Fatal("running HTTP server")
 	}
 }
+
+func materializeScheduledTransactions(dbc *database.Client, interval time.Duration) {
+	for {
+		n, err := dbc.MaterializeScheduledTransactions(time.Now())
+		if err != nil {
+			logrus.WithError(err).Error("creating scheduled transactions")
+		}
+
+		if n > 0 {
+			logrus.WithField("count", n).Info("created scheduled transactions")
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,7 +16,7 @@ import (
 func (a apiServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
 		Name            string               `json:"name"`
-		StartingBalance float64              `json:"startingBalance"`
+		StartingBalance database.Money       `json:"startingBalance"`
 		Type            database.AccountType `json:"type"`
 	}
 
@@ -50,12 +49,12 @@ func (a apiServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 				Description: "Starting Balance",
 				Amount:      payload.StartingBalance,
 				Account:     uuid.NullUUID{UUID: acc.ID, Valid: true},
-				Category:    uuid.NullUUID{UUID: database.UnallocatedMoney, Valid: true},
+				Category:    uuid.NullUUID{UUID: a.dbc.Budget().UnallocatedMoney, Valid: true},
 				Cleared:     true,
 			})
 
 		case database.AccountTypeCategory:
-			err = a.dbc.TransferMoney(database.UnallocatedMoney, acc.ID, payload.StartingBalance, "")
+			err = a.dbc.TransferMoney(a.dbc.Budget().UnallocatedMoney, acc.ID, payload.StartingBalance, "")
 
 		case database.AccountTypeTracking:
 			_, err = a.dbc.CreateTransaction(database.Transaction{
@@ -73,7 +72,7 @@ func (a apiServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	u, err := a.router.Get("GetAccount").URL("id", acc.ID.String())
+	u, err := a.router.Get("GetAccount").URL("budget", a.dbc.Budget().ID.String(), "id", acc.ID.String())
 	if err != nil {
 		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
 		return
@@ -82,6 +81,30 @@ func (a apiServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, u.String(), http.StatusFound)
 }
 
+func (a apiServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	var replacement uuid.NullUUID
+	if v := r.URL.Query().Get("replacement"); v != "" {
+		if replacement.UUID, err = uuid.Parse(v); err != nil {
+			a.errorResponse(w, err, "parsing replacement", http.StatusBadRequest)
+			return
+		}
+		replacement.Valid = true
+	}
+
+	if err = a.dbc.DeleteAccount(id, replacement); err != nil {
+		a.accountDeleteError(w, err, "deleting account")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (a apiServer) handleGetAccount(w http.ResponseWriter, r *http.Request) {
 	accid, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
@@ -107,7 +130,14 @@ func (a apiServer) handleListAccounts(w http.ResponseWriter, r *http.Request) {
 		payload    any
 		showHidden = r.URL.Query().Has("with-hidden")
 	)
-	if r.URL.Query().Has("with-balances") {
+	if r.URL.Query().Has("with-balances") && r.URL.Query().Has("grouped") {
+		groups, err := a.dbc.ListAccountGroupBalances(showHidden)
+		if err != nil {
+			a.errorResponse(w, err, "getting account group balances", http.StatusInternalServerError)
+			return
+		}
+		payload = groups
+	} else if r.URL.Query().Has("with-balances") {
 		accs, err := a.dbc.ListAccountBalances(showHidden)
 		if err != nil {
 			a.errorResponse(w, err, "getting account balances", http.StatusInternalServerError)
@@ -136,10 +166,42 @@ func (a apiServer) handleListAccounts(w http.ResponseWriter, r *http.Request) {
 	a.jsonResponse(w, http.StatusOK, payload)
 }
 
+func (a apiServer) handleMergeAccounts(w http.ResponseWriter, r *http.Request) {
+	var (
+		from, into uuid.UUID
+		err        error
+	)
+
+	if from, err = uuid.Parse(mux.Vars(r)["id"]); err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if into, err = uuid.Parse(mux.Vars(r)["into"]); err != nil {
+		a.errorResponse(w, err, "parsing into", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.MergeAccounts(from, into); err != nil {
+		a.accountDeleteError(w, err, "merging accounts")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAccountReconcile checks the statement balance at the given
+// date against the cleared balance and records the reconciliation.
+// Both balance and date are required.
 func (a apiServer) handleAccountReconcile(w http.ResponseWriter, r *http.Request) {
 	var (
-		acctID uuid.UUID
-		err    error
+		acctID  uuid.UUID
+		err     error
+		payload struct {
+			Adjust  bool            `json:"adjust"`
+			Balance *database.Money `json:"balance"`
+			Date    string          `json:"date"`
+		}
 	)
 
 	if acctID, err = uuid.Parse(mux.Vars(r)["id"]); err != nil {
@@ -147,17 +209,59 @@ func (a apiServer) handleAccountReconcile(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err = a.dbc.MarkAccountReconciled(acctID); err != nil {
-		a.errorResponse(w, err, "marking reconciled", http.StatusInternalServerError)
+	if err = json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	if payload.Balance == nil {
+		a.errorResponse(w, errors.New("missing balance"), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse(time.DateOnly, payload.Date)
+	if err != nil {
+		a.errorResponse(w, err, "parsing date", http.StatusBadRequest)
+		return
+	}
+
+	rec, err := a.dbc.ReconcileAccount(acctID, date, *payload.Balance, payload.Adjust)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			a.errorResponse(w, err, "reconciling account", http.StatusNotFound)
+		case errors.Is(err, database.ErrNotReconcilable):
+			a.errorResponse(w, err, "reconciling account", http.StatusBadRequest)
+		case errors.Is(err, database.ErrReconcileMismatch):
+			a.errorResponse(w, err, "reconciling account", http.StatusConflict)
+		default:
+			a.errorResponse(w, err, "reconciling account", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, rec)
+}
+
+func (a apiServer) handleListReconciliations(w http.ResponseWriter, r *http.Request) {
+	acctID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	recs, err := a.dbc.ListReconciliations(acctID)
+	if err != nil {
+		a.errorResponse(w, err, "listing reconciliations", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, recs)
 }
 
 func (a apiServer) handleTransferMoney(w http.ResponseWriter, r *http.Request) {
 	var (
-		amount             float64
+		amount             database.Money
 		err                error
 		from, to, category uuid.UUID
 	)
@@ -172,7 +276,7 @@ func (a apiServer) handleTransferMoney(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if amount, err = strconv.ParseFloat(r.URL.Query().Get("amount"), 64); err != nil {
+	if amount, err = database.ParseMoney(r.URL.Query().Get("amount")); err != nil {
 		a.errorResponse(w, err, "parsing amount", http.StatusBadRequest)
 		return
 	}
@@ -217,6 +321,26 @@ func (a apiServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if r.URL.Query().Has("group") {
+		var group uuid.NullUUID
+		if v := r.URL.Query().Get("group"); v != "" {
+			if group.UUID, err = uuid.Parse(v); err != nil {
+				a.errorResponse(w, err, "parsing group", http.StatusBadRequest)
+				return
+			}
+			group.Valid = true
+		}
+
+		if err = a.dbc.UpdateAccountGroup(acctID, group); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				a.errorResponse(w, err, "moving account", http.StatusNotFound)
+				return
+			}
+			a.errorResponse(w, err, "moving account", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if r.URL.Query().Has("hidden") {
 		if err = a.dbc.UpdateAccountHidden(acctID, r.URL.Query().Get("hidden") == "true"); err != nil {
 			a.errorResponse(w, err, "updating account visibility", http.StatusInternalServerError)
@@ -226,3 +350,21 @@ func (a apiServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// accountDeleteError maps the errors of deleting or merging accounts
+// to the matching status
+func (a apiServer) accountDeleteError(w http.ResponseWriter, err error, desc string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		a.errorResponse(w, err, desc, http.StatusNotFound)
+
+	case errors.Is(err, database.ErrAccountProtected), errors.Is(err, database.ErrInvalidReplacement):
+		a.errorResponse(w, err, desc, http.StatusBadRequest)
+
+	case errors.Is(err, database.ErrAccountNotEmpty), errors.Is(err, database.ErrAccountScheduled):
+		a.errorResponse(w, err, desc, http.StatusConflict)
+
+	default:
+		a.errorResponse(w, err, desc, http.StatusInternalServerError)
+	}
+}
@@ -3,6 +3,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,60 +19,295 @@ type (
 		router *mux.Router
 		dbc    *database.Client
 		log    *logrus.Logger
+		oidc   *oidcLogin
+		role   database.BudgetRole
 	}
 )
 
 // RegisterHandler takes a (Sub)Router and registers the API onto that
-// router
-func RegisterHandler(apiRouter *mux.Router, dbc *database.Client, logger *logrus.Logger) {
-	as := apiServer{apiRouter, dbc, logger}
+// router. Routes accessing data of a budget are registered below
+// /budgets/{budget} and require the user to be a member of that budget:
+// viewers may only use GET routes, editors may change the data and
+// owners may additionally manage the members. All routes except the health-check and the login
+// require a session or an API token. If an Issuer is configured in the
+// OIDCConfig the login through that provider is enabled.
+func RegisterHandler(apiRouter *mux.Router, dbc *database.Client, logger *logrus.Logger, oidcConfig OIDCConfig) (err error) {
+	as := apiServer{router: apiRouter, dbc: dbc, log: logger}
+
+	if oidcConfig.Issuer != "" {
+		if as.oidc, err = newOIDCLogin(context.Background(), oidcConfig); err != nil {
+			return fmt.Errorf("initializing OIDC login: %w", err)
+		}
+	}
+
+	apiRouter.Use(as.authenticate)
+
+	apiRouter.
+		HandleFunc("/audit", as.adminOnly(as.handleListAllAuditEntries)).
+		Methods(http.MethodGet)
+
+	apiRouter.
+		HandleFunc("/backup", as.adminOnly(as.handleGetBackup)).
+		Methods(http.MethodGet)
+	apiRouter.
+		HandleFunc("/backup", as.adminOnly(as.handleRestoreBackup)).
+		Methods(http.MethodPut)
 
 	apiRouter.
-		HandleFunc("/accounts", as.handleListAccounts).
+		HandleFunc("/budgets", as.handleListBudgets).
 		Methods(http.MethodGet)
 	apiRouter.
-		HandleFunc("/accounts", as.handleCreateAccount).
+		HandleFunc("/budgets", as.handleCreateBudget).
 		Methods(http.MethodPost)
 	apiRouter.
-		HandleFunc("/accounts/{id}", as.handleGetAccount).
+		HandleFunc("/budgets/{budget}", as.inBudget(apiServer.handleGetBudget)).
 		Methods(http.MethodGet).
-		Name("GetAccount")
+		Name("GetBudget")
 	apiRouter.
-		HandleFunc("/accounts/{id}", as.handleUpdateAccount).
+		HandleFunc("/budgets/{budget}", as.inBudget(as.ownerOnly(apiServer.handleUpdateBudget))).
 		Methods(http.MethodPatch)
+
 	apiRouter.
-		HandleFunc("/accounts/{id}/reconcile", as.handleAccountReconcile).
-		Methods(http.MethodPut)
+		HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }).
+		Methods(http.MethodGet).
+		Name("Healthz")
+
 	apiRouter.
-		HandleFunc("/accounts/{id}/transactions", as.handleListTransactionsByAccount).
+		HandleFunc("/import-profiles", as.handleListImportProfiles).
 		Methods(http.MethodGet)
 	apiRouter.
-		HandleFunc("/accounts/{id}/transfer/{to}", as.handleTransferMoney).
+		HandleFunc("/import-profiles", as.anyBudgetEditor(as.handleCreateImportProfile)).
+		Methods(http.MethodPost)
+	apiRouter.
+		HandleFunc("/import-profiles/{id}", as.anyBudgetEditor(as.handleDeleteImportProfile)).
+		Methods(http.MethodDelete)
+	apiRouter.
+		HandleFunc("/import-profiles/{id}", as.handleGetImportProfile).
+		Methods(http.MethodGet).
+		Name("GetImportProfile")
+	apiRouter.
+		HandleFunc("/import-profiles/{id}", as.anyBudgetEditor(as.handleUpdateImportProfile)).
 		Methods(http.MethodPut)
 
 	apiRouter.
-		HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }).
+		HandleFunc("/login", as.handleGetLoginOptions).
+		Methods(http.MethodGet).
+		Name("GetLoginOptions")
+	apiRouter.
+		HandleFunc("/login", as.handleLogin).
+		Methods(http.MethodPost).
+		Name("Login")
+	apiRouter.
+		HandleFunc("/logout", as.handleLogout).
+		Methods(http.MethodPost)
+
+	apiRouter.
+		HandleFunc("/me", as.handleGetMe).
 		Methods(http.MethodGet)
+	apiRouter.
+		HandleFunc("/me/password", as.handleUpdatePassword).
+		Methods(http.MethodPut)
+
+	if as.oidc != nil {
+		apiRouter.
+			HandleFunc("/oidc/callback", as.handleOIDCCallback).
+			Methods(http.MethodGet).
+			Name("OIDCCallback")
+		apiRouter.
+			HandleFunc("/oidc/login", as.handleOIDCLogin).
+			Methods(http.MethodGet).
+			Name("OIDCLogin")
+	}
 
 	apiRouter.
-		HandleFunc("/transactions", as.handleListTransactions).
+		HandleFunc("/tokens", as.handleListAPITokens).
 		Methods(http.MethodGet)
 	apiRouter.
-		HandleFunc("/transactions", as.handleCreateTransaction).
+		HandleFunc("/tokens", as.handleCreateAPIToken).
 		Methods(http.MethodPost)
 	apiRouter.
-		HandleFunc("/transactions/{id}", as.handleDeleteTransaction).
+		HandleFunc("/tokens/{id}", as.handleDeleteAPIToken).
 		Methods(http.MethodDelete)
+
+	apiRouter.
+		HandleFunc("/users", as.adminOnly(as.handleListUsers)).
+		Methods(http.MethodGet)
 	apiRouter.
-		HandleFunc("/transactions/{id}", as.handleGetTransactionByID).
+		HandleFunc("/users", as.adminOnly(as.handleCreateUser)).
+		Methods(http.MethodPost)
+	apiRouter.
+		HandleFunc("/users/{id}", as.adminOnly(as.handleDeleteUser)).
+		Methods(http.MethodDelete)
+
+	// Everything else belongs to a budget
+	budgetRouter := apiRouter.PathPrefix("/budgets/{budget}").Subrouter()
+
+	budgetRouter.
+		HandleFunc("/accounts", as.inBudget(apiServer.handleListAccounts)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/accounts", as.inBudget(apiServer.handleCreateAccount)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}", as.inBudget(as.ownerOnly(apiServer.handleDeleteAccount))).
+		Methods(http.MethodDelete)
+	budgetRouter.
+		HandleFunc("/accounts/{id}", as.inBudget(apiServer.handleGetAccount)).
+		Methods(http.MethodGet).
+		Name("GetAccount")
+	budgetRouter.
+		HandleFunc("/accounts/{id}", as.inBudget(apiServer.handleUpdateAccount)).
+		Methods(http.MethodPatch)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/export/qif", as.inBudget(apiServer.handleExportQIF)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/goal", as.inBudget(apiServer.handleDeleteCategoryGoal)).
+		Methods(http.MethodDelete)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/goal", as.inBudget(apiServer.handleSetCategoryGoal)).
+		Methods(http.MethodPut)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/import/camt", as.inBudget(apiServer.handleImportCAMT)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/import/csv", as.inBudget(apiServer.handleImportCSV)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/import/csv/preview", as.inBudget(apiServer.handlePreviewImportCSV)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/import/mt940", as.inBudget(apiServer.handleImportMT940)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/import/ofx", as.inBudget(apiServer.handleImportOFX)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/import/qif", as.inBudget(apiServer.handleImportQIF)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/merge/{into}", as.inBudget(as.ownerOnly(apiServer.handleMergeAccounts))).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/reconcile", as.inBudget(apiServer.handleAccountReconcile)).
+		Methods(http.MethodPut)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/reconciliations", as.inBudget(apiServer.handleListReconciliations)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/transactions", as.inBudget(apiServer.handleListTransactionsByAccount)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/accounts/{id}/transfer/{to}", as.inBudget(apiServer.handleTransferMoney)).
+		Methods(http.MethodPut)
+
+	budgetRouter.
+		HandleFunc("/audit", as.inBudget(apiServer.handleListAuditEntries)).
+		Methods(http.MethodGet)
+
+	budgetRouter.
+		HandleFunc("/budget/{month:[0-9]{4}-[0-9]{2}}", as.inBudget(apiServer.handleGetBudgetSummary)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/budget/{month:[0-9]{4}-[0-9]{2}}/underfunded", as.inBudget(apiServer.handleListUnderfundedCategories)).
+		Methods(http.MethodGet)
+
+	budgetRouter.
+		HandleFunc("/export/beancount", as.inBudget(apiServer.handleExportBeancount)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/export/ledger", as.inBudget(apiServer.handleExportLedger)).
+		Methods(http.MethodGet)
+
+	budgetRouter.
+		HandleFunc("/groups", as.inBudget(apiServer.handleListAccountGroups)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/groups", as.inBudget(apiServer.handleCreateAccountGroup)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/groups/order", as.inBudget(apiServer.handleUpdateAccountGroupOrder)).
+		Methods(http.MethodPut)
+	budgetRouter.
+		HandleFunc("/groups/{id}", as.inBudget(apiServer.handleGetAccountGroup)).
+		Methods(http.MethodGet).
+		Name("GetAccountGroup")
+	budgetRouter.
+		HandleFunc("/groups/{id}", as.inBudget(apiServer.handleUpdateAccountGroup)).
+		Methods(http.MethodPatch)
+	budgetRouter.
+		HandleFunc("/groups/{id}/accounts", as.inBudget(apiServer.handleMoveAccountsToGroup)).
+		Methods(http.MethodPut)
+
+	budgetRouter.
+		HandleFunc("/members", as.inBudget(apiServer.handleListBudgetMembers)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/members", as.inBudget(as.ownerOnly(apiServer.handleSetBudgetMember))).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/members/{user}", as.inBudget(as.ownerOnly(apiServer.handleRemoveBudgetMember))).
+		Methods(http.MethodDelete)
+
+	budgetRouter.
+		HandleFunc("/redo", as.inBudget(apiServer.handleRedo)).
+		Methods(http.MethodPost)
+
+	budgetRouter.
+		HandleFunc("/scheduled", as.inBudget(apiServer.handleListScheduledTransactions)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/scheduled", as.inBudget(apiServer.handleCreateScheduledTransaction)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/scheduled/upcoming", as.inBudget(apiServer.handleListUpcomingTransactions)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/scheduled/{id}", as.inBudget(apiServer.handleDeleteScheduledTransaction)).
+		Methods(http.MethodDelete)
+	budgetRouter.
+		HandleFunc("/scheduled/{id}", as.inBudget(apiServer.handleGetScheduledTransaction)).
+		Methods(http.MethodGet).
+		Name("GetScheduledTransaction")
+	budgetRouter.
+		HandleFunc("/scheduled/{id}", as.inBudget(apiServer.handleUpdateScheduledTransaction)).
+		Methods(http.MethodPut)
+
+	budgetRouter.
+		HandleFunc("/transactions", as.inBudget(apiServer.handleListTransactions)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/transactions", as.inBudget(apiServer.handleCreateTransaction)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/transactions/{id}", as.inBudget(apiServer.handleDeleteTransaction)).
+		Methods(http.MethodDelete)
+	budgetRouter.
+		HandleFunc("/transactions/{id}", as.inBudget(apiServer.handleGetTransactionByID)).
 		Methods(http.MethodGet).
 		Name("GetTransactionByID")
-	apiRouter.
-		HandleFunc("/transactions/{id}", as.handleUpdateTransaction).
+	budgetRouter.
+		HandleFunc("/transactions/{id}", as.inBudget(apiServer.handleUpdateTransaction)).
 		Methods(http.MethodPatch)
-	apiRouter.
-		HandleFunc("/transactions/{id}", as.handleOverwriteTransaction).
+	budgetRouter.
+		HandleFunc("/transactions/{id}", as.inBudget(apiServer.handleOverwriteTransaction)).
 		Methods(http.MethodPut)
+
+	budgetRouter.
+		HandleFunc("/trash", as.inBudget(apiServer.handleListTrash)).
+		Methods(http.MethodGet)
+	budgetRouter.
+		HandleFunc("/trash/accounts/{id}/restore", as.inBudget(apiServer.handleRestoreAccount)).
+		Methods(http.MethodPost)
+	budgetRouter.
+		HandleFunc("/trash/transactions/{id}/restore", as.inBudget(apiServer.handleRestoreTransaction)).
+		Methods(http.MethodPost)
+
+	budgetRouter.
+		HandleFunc("/undo", as.inBudget(apiServer.handleUndo)).
+		Methods(http.MethodPost)
+
+	return nil
 }
 
 func (a apiServer) errorResponse(w http.ResponseWriter, err error, desc string, status int) {
@@ -91,6 +327,15 @@ func (a apiServer) errorResponse(w http.ResponseWriter, err error, desc string,
 	}{fmt.Sprintf("%s: %s", desc, err)})
 }
 
+func (apiServer) fileResponse(w http.ResponseWriter, contentType, filename string, body *bytes.Buffer) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = body.WriteTo(w)
+}
+
 func (apiServer) jsonResponse(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-cache")
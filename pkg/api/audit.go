@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleListAllAuditEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilter(r)
+	if err != nil {
+		a.errorResponse(w, err, "parsing filter", http.StatusBadRequest)
+		return
+	}
+
+	e, err := a.dbc.ListAllAuditEntries(filter)
+	if err != nil {
+		a.errorResponse(w, err, "getting audit entries", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, e)
+}
+
+func (a apiServer) handleListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilter(r)
+	if err != nil {
+		a.errorResponse(w, err, "parsing filter", http.StatusBadRequest)
+		return
+	}
+
+	e, err := a.dbc.ListAuditEntries(filter)
+	if err != nil {
+		a.errorResponse(w, err, "getting audit entries", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, e)
+}
+
+// auditFilter reads the optional entity, entity-id, user, since and
+// until parameters
+func auditFilter(r *http.Request) (f database.AuditFilter, err error) {
+	q := r.URL.Query()
+
+	f.Entity = q.Get("entity")
+
+	for param, target := range map[string]*uuid.NullUUID{
+		"entity-id": &f.EntityID,
+		"user":      &f.User,
+	} {
+		if !q.Has(param) {
+			continue
+		}
+
+		if target.UUID, err = uuid.Parse(q.Get(param)); err != nil {
+			return f, fmt.Errorf("parsing %s: %w", param, err)
+		}
+		target.Valid = true
+	}
+
+	for param, target := range map[string]*time.Time{
+		"since": &f.Since,
+		"until": &f.Until,
+	} {
+		if !q.Has(param) {
+			continue
+		}
+
+		if *target, err = time.Parse(time.RFC3339, q.Get(param)); err != nil {
+			return f, fmt.Errorf("parsing %s: %w", param, err)
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+type (
+	contextKey int
+)
+
+const (
+	contextKeyUser contextKey = iota
+)
+
+const (
+	sessionCookie = "accounting_session"
+	sessionTTL    = 30 * 24 * time.Hour
+)
+
+// unauthenticatedRoutes contains the names of the routes which can be
+// accessed without a session or API token
+var unauthenticatedRoutes = []string{"GetLoginOptions", "Healthz", "Login", "OIDCCallback", "OIDCLogin"}
+
+// adminOnly denies access to the handler for users not being an admin
+func (a apiServer) adminOnly(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !userFromRequest(r).Admin {
+			a.errorResponse(w, errors.New("admin required"), "checking permissions", http.StatusForbidden)
+			return
+		}
+
+		fn(w, r)
+	}
+}
+
+// anyBudgetEditor denies access to the handler for users not being an
+// editor in any budget, used for data shared between all budgets
+func (a apiServer) anyBudgetEditor(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, err := a.dbc.HasBudgetRoleAnywhere(userFromRequest(r), database.BudgetRoleEditor)
+		if err != nil {
+			a.errorResponse(w, err, "checking permissions", http.StatusInternalServerError)
+			return
+		}
+
+		if !ok {
+			a.errorResponse(w, fmt.Errorf("role %q required", database.BudgetRoleEditor), "checking permissions", http.StatusForbidden)
+			return
+		}
+
+		fn(w, r)
+	}
+}
+
+// authenticate is a middleware resolving the user from the API token
+// given as bearer token or from the session cookie and rejecting all
+// requests without valid credentials
+func (a apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			for _, name := range unauthenticatedRoutes {
+				if route.GetName() == name {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+
+		var (
+			user database.User
+			err  = database.ErrInvalidCredentials
+		)
+
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			user, err = a.dbc.GetAPITokenUser(token)
+		} else if c, cerr := r.Cookie(sessionCookie); cerr == nil {
+			user, err = a.dbc.GetSessionUser(c.Value)
+		}
+
+		if err != nil {
+			if errors.Is(err, database.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="accounting"`)
+				a.errorResponse(w, err, "authenticating request", http.StatusUnauthorized)
+				return
+			}
+			a.errorResponse(w, err, "authenticating request", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyUser, user)))
+	})
+}
+
+// SessionRequired returns a middleware redirecting browsers without a
+// valid session to the login page of the frontend
+func SessionRequired(dbc *database.Client, logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := r.Cookie(sessionCookie)
+			if err == nil {
+				_, err = dbc.GetSessionUser(c.Value)
+			}
+
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r)
+
+			case errors.Is(err, http.ErrNoCookie), errors.Is(err, database.ErrInvalidCredentials):
+				http.Redirect(w, r, "/login", http.StatusFound)
+
+			default:
+				logger.WithError(err).Error("checking session")
+				http.Error(w, "checking session failed", http.StatusInternalServerError)
+			}
+		})
+	}
+}
+
+func (a apiServer) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	a.jsonResponse(w, http.StatusOK, userFromRequest(r))
+}
+
+func (a apiServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := a.dbc.AuthenticateUser(payload.Username, payload.Password)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCredentials) {
+			a.errorResponse(w, err, "logging in", http.StatusUnauthorized)
+			return
+		}
+		a.errorResponse(w, err, "logging in", http.StatusInternalServerError)
+		return
+	}
+
+	if err = a.startSession(w, r, user); err != nil {
+		a.errorResponse(w, err, "creating session", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, user)
+}
+
+func (a apiServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if err = a.dbc.DeleteSession(c.Value); err != nil {
+			a.errorResponse(w, err, "deleting session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession creates a new session for the user and hands its
+// token to the browser
+func (a apiServer) startSession(w http.ResponseWriter, r *http.Request, user database.User) error {
+	token, err := a.dbc.CreateSession(user.ID, sessionTTL)
+	if err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+
+	// Lax instead of Strict: after the redirect back from the OpenID
+	// Connect provider the browser would not send a strict cookie
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(sessionTTL),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// userFromRequest returns the user authenticated by the authenticate
+// middleware
+func userFromRequest(r *http.Request) database.User {
+	u, _ := r.Context().Value(contextKeyUser).(database.User)
+	return u
+}
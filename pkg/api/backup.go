@@ -0,0 +1,50 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleGetBackup(w http.ResponseWriter, _ *http.Request) {
+	b, err := a.dbc.Backup()
+	if err != nil {
+		a.errorResponse(w, err, "creating backup", http.StatusInternalServerError)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err = json.NewEncoder(buf).Encode(b); err != nil {
+		a.errorResponse(w, err, "encoding backup", http.StatusInternalServerError)
+		return
+	}
+
+	a.fileResponse(w, "application/json", fmt.Sprintf("accounting-%s.json", b.CreatedAt.Format("20060102-150405")), buf)
+}
+
+func (a apiServer) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	var b database.Backup
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	overwrite, _ := strconv.ParseBool(r.URL.Query().Get("overwrite"))
+
+	if err := a.dbc.Restore(b, overwrite); err != nil {
+		if errors.Is(err, database.ErrDatabaseNotEmpty) {
+			a.errorResponse(w, err, "restoring backup", http.StatusConflict)
+			return
+		}
+
+		a.errorResponse(w, err, "restoring backup", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
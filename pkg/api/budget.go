@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (a apiServer) handleGetBudgetSummary(w http.ResponseWriter, r *http.Request) {
+	month, err := time.Parse("2006-01", mux.Vars(r)["month"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing month", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := a.dbc.GetBudgetSummary(month, r.URL.Query().Has("with-hidden"))
+	if err != nil {
+		a.errorResponse(w, err, "getting budget summary", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, summary)
+}
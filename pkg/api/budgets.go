@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleCreateBudget(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Name == "" {
+		a.errorResponse(w, errors.New("empty name"), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	b, err := a.dbc.AsUser(userFromRequest(r).ID).CreateBudget(payload.Name, userFromRequest(r).ID)
+	if err != nil {
+		a.errorResponse(w, err, "creating budget", http.StatusInternalServerError)
+		return
+	}
+
+	u, err := a.router.Get("GetBudget").URL("budget", b.ID.String())
+	if err != nil {
+		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (a apiServer) handleGetBudget(w http.ResponseWriter, _ *http.Request) {
+	a.jsonResponse(w, http.StatusOK, a.dbc.Budget())
+}
+
+func (a apiServer) handleListBudgetMembers(w http.ResponseWriter, _ *http.Request) {
+	m, err := a.dbc.ListBudgetMembers()
+	if err != nil {
+		a.errorResponse(w, err, "getting members", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, m)
+}
+
+func (a apiServer) handleListBudgets(w http.ResponseWriter, r *http.Request) {
+	b, err := a.dbc.ListBudgetsForUser(userFromRequest(r))
+	if err != nil {
+		a.errorResponse(w, err, "getting budgets", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, b)
+}
+
+func (a apiServer) handleRemoveBudgetMember(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["user"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing user id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.RemoveBudgetMember(id); err != nil {
+		if errors.Is(err, database.ErrLastOwner) {
+			a.errorResponse(w, err, "removing member", http.StatusConflict)
+			return
+		}
+		a.errorResponse(w, err, "removing member", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleSetBudgetMember(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Username string              `json:"username"`
+		Role     database.BudgetRole `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if !payload.Role.IsValid() {
+		a.errorResponse(w, fmt.Errorf("invalid role %q", payload.Role), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	u, err := a.dbc.GetUserByUsername(payload.Username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "getting user", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "getting user", http.StatusInternalServerError)
+		return
+	}
+
+	if err = a.dbc.SetBudgetMember(u.ID, payload.Role); err != nil {
+		if errors.Is(err, database.ErrLastOwner) {
+			a.errorResponse(w, err, "setting member", http.StatusConflict)
+			return
+		}
+		a.errorResponse(w, err, "setting member", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleUpdateBudget(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("name") {
+		if err := a.dbc.UpdateBudgetName(a.dbc.Budget().ID, r.URL.Query().Get("name")); err != nil {
+			a.errorResponse(w, err, "renaming budget", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// inBudget resolves the budget given in the route, checks the role of
+// the user in that budget and passes a copy of the apiServer having
+// its client scoped to that budget and recording the user in the audit
+// log to the handler. All changes of the request are grouped into one
+// operation to be reverted by an undo. Viewers may only use GET
+// requests, all other methods require at least an editor.
+func (a apiServer) inBudget(fn func(apiServer, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["budget"])
+		if err != nil {
+			a.errorResponse(w, err, "parsing budget id", http.StatusBadRequest)
+			return
+		}
+
+		dbc, err := a.dbc.ForBudget(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				a.errorResponse(w, err, "getting budget", http.StatusNotFound)
+				return
+			}
+			a.errorResponse(w, err, "getting budget", http.StatusInternalServerError)
+			return
+		}
+
+		// Non-members get the same response as for a missing budget to
+		// not leak its existence
+		role, err := dbc.GetBudgetRole(userFromRequest(r))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				a.errorResponse(w, err, "getting budget", http.StatusNotFound)
+				return
+			}
+			a.errorResponse(w, err, "getting budget role", http.StatusInternalServerError)
+			return
+		}
+
+		required := database.BudgetRoleEditor
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			required = database.BudgetRoleViewer
+		}
+
+		if !role.Allows(required) {
+			a.errorResponse(w, fmt.Errorf("role %q required", required), "checking permissions", http.StatusForbidden)
+			return
+		}
+
+		scoped := a
+		scoped.dbc = dbc.AsUser(userFromRequest(r).ID).InOperation()
+		scoped.role = role
+		fn(scoped, w, r)
+	}
+}
+
+// ownerOnly denies access to the handler for members of the budget not
+// being an owner
+func (apiServer) ownerOnly(fn func(apiServer, http.ResponseWriter, *http.Request)) func(apiServer, http.ResponseWriter, *http.Request) {
+	return func(a apiServer, w http.ResponseWriter, r *http.Request) {
+		if !a.role.Allows(database.BudgetRoleOwner) {
+			a.errorResponse(w, fmt.Errorf("role %q required", database.BudgetRoleOwner), "checking permissions", http.StatusForbidden)
+			return
+		}
+
+		fn(a, w, r)
+	}
+}
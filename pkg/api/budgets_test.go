@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestBudgetRoles(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	users := map[string]database.User{}
+	for _, name := range []string{"owner", "editor", "viewer", "outsider"} {
+		users[name], err = dbc.CreateUser(name, "correct horse", false)
+		require.NoError(t, err)
+	}
+
+	budget, err := dbc.CreateBudget("Household", users["owner"].ID)
+	require.NoError(t, err)
+	bc, err := dbc.ForBudget(budget.ID)
+	require.NoError(t, err)
+	require.NoError(t, bc.SetBudgetMember(users["editor"].ID, database.BudgetRoleEditor))
+	require.NoError(t, bc.SetBudgetMember(users["viewer"].ID, database.BudgetRoleViewer))
+
+	checking, err := bc.CreateAccount("Checking", database.AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := bc.CreateAccount("Savings", database.AccountTypeBudget)
+	require.NoError(t, err)
+
+	// The outsider owns another budget but must not reach into this one
+	other, err := dbc.CreateBudget("Private", users["outsider"].ID)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	require.NoError(t, RegisterHandler(router.PathPrefix("/api").Subrouter(), dbc, logrus.StandardLogger(), OIDCConfig{}))
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+
+	clients := map[string]*http.Client{}
+	for name := range users {
+		clients[name] = testLogin(t, app.URL, name, "correct horse")
+	}
+
+	var (
+		prefix  = fmt.Sprintf("%s/api/budgets/%s", app.URL, budget.ID)
+		account = fmt.Sprintf("%s/accounts/%s", prefix, checking.ID)
+	)
+
+	for _, tc := range []struct {
+		user, method, url, body string
+		status                  int
+	}{
+		{"viewer", http.MethodGet, prefix + "/accounts", "", http.StatusOK},
+		{"viewer", http.MethodPost, prefix + "/accounts", `{"name":"Cash","type":"budget"}`, http.StatusForbidden},
+		{"viewer", http.MethodPatch, account, `{"name":"Giro"}`, http.StatusForbidden},
+		{"viewer", http.MethodPut, account + "/goal", `{"type":"monthly_spending","amount":100}`, http.StatusForbidden},
+		{"viewer", http.MethodDelete, account + "/goal", "", http.StatusForbidden},
+		{"viewer", http.MethodDelete, account, "", http.StatusForbidden},
+
+		{"editor", http.MethodDelete, account, "", http.StatusForbidden},
+		{"editor", http.MethodPost, fmt.Sprintf("%s/merge/%s", account, savings.ID), "", http.StatusForbidden},
+		{"editor", http.MethodPatch, account, `{"name":"Giro"}`, http.StatusNoContent},
+		{"editor", http.MethodPut, account + "/reconcile", "", http.StatusBadRequest},
+		{"editor", http.MethodPut, account + "/reconcile", `{"date":"2024-01-31"}`, http.StatusBadRequest},
+		{"editor", http.MethodPut, account + "/reconcile", `{"balance":0,"date":"2024-01-31"}`, http.StatusOK},
+
+		{"outsider", http.MethodGet, prefix + "/accounts", "", http.StatusNotFound},
+		{"outsider", http.MethodPost, prefix + "/accounts", `{"name":"Cash","type":"budget"}`, http.StatusNotFound},
+		{"outsider", http.MethodGet, fmt.Sprintf("%s/api/budgets/%s/accounts/%s/transactions", app.URL, other.ID, checking.ID), "", http.StatusNotFound},
+
+		{"owner", http.MethodPost, fmt.Sprintf("%s/merge/%s", account, savings.ID), "", http.StatusNoContent},
+	} {
+		req, err := http.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+		require.NoError(t, err)
+
+		resp, err := clients[tc.user].Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, tc.status, resp.StatusCode, "%s %s %s", tc.user, tc.method, tc.url)
+	}
+}
+
+// testLogin returns a client holding the session of the given user
+func testLogin(t *testing.T, baseURL, username, password string) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	require.NoError(t, err)
+
+	resp, err := client.Post(baseURL+"/api/login", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode, "logging in %s", username)
+
+	return client
+}
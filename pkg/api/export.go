@@ -0,0 +1,77 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"git.luzifer.io/luzifer/accounting/pkg/exporter"
+)
+
+func (a apiServer) handleExportBeancount(w http.ResponseWriter, r *http.Request) {
+	since, until := exportRange(r)
+
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		currency = exporter.DefaultCurrency
+	}
+
+	buf := new(bytes.Buffer)
+	if err := exporter.WriteBeancount(buf, a.dbc, since, until, currency); err != nil {
+		a.errorResponse(w, err, "exporting transactions", http.StatusInternalServerError)
+		return
+	}
+
+	a.fileResponse(w, "text/plain; charset=utf-8", "accounting.beancount", buf)
+}
+
+func (a apiServer) handleExportLedger(w http.ResponseWriter, r *http.Request) {
+	since, until := exportRange(r)
+
+	// Export into a buffer first to be able to respond with an error
+	// instead of a partial file
+	buf := new(bytes.Buffer)
+	if err := exporter.WriteLedger(buf, a.dbc, since, until); err != nil {
+		a.errorResponse(w, err, "exporting transactions", http.StatusInternalServerError)
+		return
+	}
+
+	a.fileResponse(w, "text/plain; charset=utf-8", "accounting.journal", buf)
+}
+
+func (a apiServer) handleExportQIF(w http.ResponseWriter, r *http.Request) {
+	acctID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	since, until := exportRange(r)
+
+	buf := new(bytes.Buffer)
+	if err = exporter.WriteQIF(buf, a.dbc, acctID, since, until); err != nil {
+		a.errorResponse(w, err, "exporting transactions", http.StatusInternalServerError)
+		return
+	}
+
+	a.fileResponse(w, "application/qif", fmt.Sprintf("%s.qif", acctID), buf)
+}
+
+// exportRange reads the optional since / until parameters defaulting
+// to all transactions up to now
+func exportRange(r *http.Request) (since, until time.Time) {
+	until = time.Now()
+
+	if v, err := time.Parse(time.RFC3339, r.URL.Query().Get("since")); err == nil {
+		since = v
+	}
+	if v, err := time.Parse(time.RFC3339, r.URL.Query().Get("until")); err == nil {
+		until = v
+	}
+
+	return since, until
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleDeleteCategoryGoal(w http.ResponseWriter, r *http.Request) {
+	acctID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.DeleteCategoryGoal(acctID); err != nil {
+		a.errorResponse(w, err, "deleting goal", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleListUnderfundedCategories(w http.ResponseWriter, r *http.Request) {
+	month, err := time.Parse("2006-01", mux.Vars(r)["month"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing month", http.StatusBadRequest)
+		return
+	}
+
+	cats, err := a.dbc.ListUnderfundedCategories(month)
+	if err != nil {
+		a.errorResponse(w, err, "getting underfunded categories", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, cats)
+}
+
+func (a apiServer) handleSetCategoryGoal(w http.ResponseWriter, r *http.Request) {
+	var (
+		acctID uuid.UUID
+		goal   database.CategoryGoal
+		err    error
+	)
+
+	if acctID, err = uuid.Parse(mux.Vars(r)["id"]); err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	goal.Category = acctID
+	if goal, err = a.dbc.SetCategoryGoal(goal); err != nil {
+		a.errorResponse(w, err, "setting goal", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, goal)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (a apiServer) handleCreateAccountGroup(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Name == "" {
+		a.errorResponse(w, errors.New("empty name"), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	g, err := a.dbc.CreateAccountGroup(payload.Name)
+	if err != nil {
+		a.errorResponse(w, err, "creating account group", http.StatusInternalServerError)
+		return
+	}
+
+	u, err := a.router.Get("GetAccountGroup").URL("budget", a.dbc.Budget().ID.String(), "id", g.ID.String())
+	if err != nil {
+		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (a apiServer) handleGetAccountGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	g, err := a.dbc.GetAccountGroup(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "getting account group", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "getting account group", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, g)
+}
+
+func (a apiServer) handleListAccountGroups(w http.ResponseWriter, _ *http.Request) {
+	g, err := a.dbc.ListAccountGroups()
+	if err != nil {
+		a.errorResponse(w, err, "getting account groups", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, g)
+}
+
+func (a apiServer) handleMoveAccountsToGroup(w http.ResponseWriter, r *http.Request) {
+	var (
+		accounts []uuid.UUID
+		group    uuid.NullUUID
+		err      error
+	)
+
+	if group.UUID, err = uuid.Parse(mux.Vars(r)["id"]); err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+	group.Valid = true
+
+	if err = json.NewDecoder(r.Body).Decode(&accounts); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.MoveAccountsToGroup(group, accounts); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "moving accounts", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "moving accounts", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleUpdateAccountGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Has("name") {
+		if err = a.dbc.UpdateAccountGroupName(id, r.URL.Query().Get("name")); err != nil {
+			a.errorResponse(w, err, "renaming account group", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleUpdateAccountGroupOrder(w http.ResponseWriter, r *http.Request) {
+	var groups []uuid.UUID
+
+	if err := json.NewDecoder(r.Body).Decode(&groups); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.dbc.UpdateAccountGroupOrder(groups); err != nil {
+		a.errorResponse(w, err, "sorting account groups", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
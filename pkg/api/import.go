@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+	"git.luzifer.io/luzifer/accounting/pkg/importer"
+)
+
+func (a apiServer) handleImportCSV(w http.ResponseWriter, r *http.Request) {
+	a.importCSV(w, r, importer.Import)
+}
+
+func (a apiServer) handleImportCAMT(w http.ResponseWriter, r *http.Request) {
+	a.importStatement(w, r, importer.ParseCAMT)
+}
+
+func (a apiServer) handleImportMT940(w http.ResponseWriter, r *http.Request) {
+	a.importStatement(w, r, importer.ParseMT940)
+}
+
+func (a apiServer) handleImportOFX(w http.ResponseWriter, r *http.Request) {
+	a.importStatement(w, r, importer.ParseOFX)
+}
+
+func (a apiServer) handleImportQIF(w http.ResponseWriter, r *http.Request) {
+	a.importStatement(w, r, importer.ParseQIF)
+}
+
+func (a apiServer) handlePreviewImportCSV(w http.ResponseWriter, r *http.Request) {
+	a.importCSV(w, r, importer.Preview)
+}
+
+func (a apiServer) importCSV(
+	w http.ResponseWriter, r *http.Request,
+	fn func(*database.Client, uuid.UUID, importer.Statement) (importer.Result, error),
+) {
+	acctID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	profileID, err := uuid.Parse(r.URL.Query().Get("profile"))
+	if err != nil {
+		a.errorResponse(w, err, "parsing profile id", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := a.dbc.GetImportProfile(profileID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "getting import profile", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "getting import profile", http.StatusInternalServerError)
+		return
+	}
+
+	stmt, err := importer.ParseCSV(r.Body, profile)
+	if err != nil {
+		a.errorResponse(w, err, "parsing statement", http.StatusBadRequest)
+		return
+	}
+
+	res, err := fn(a.dbc, acctID, stmt)
+	if err != nil {
+		a.errorResponse(w, err, "importing statement", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, res)
+}
+
+func (a apiServer) importStatement(w http.ResponseWriter, r *http.Request, parse func(io.Reader) (importer.Statement, error)) {
+	acctID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	stmt, err := parse(r.Body)
+	if err != nil {
+		a.errorResponse(w, err, "parsing statement", http.StatusBadRequest)
+		return
+	}
+
+	res, err := importer.Import(a.dbc, acctID, stmt)
+	if err != nil {
+		a.errorResponse(w, err, "importing statement", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, res)
+}
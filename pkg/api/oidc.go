@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+const (
+	oidcFlowCookie       = "accounting_oidc"
+	oidcFlowCookieMaxAge = 600 // seconds for the user to log in at the provider
+	oidcNonceBytes       = 16
+)
+
+type (
+	// OIDCConfig configures the login through an OpenID Connect
+	// provider. The login is disabled when no Issuer is set.
+	OIDCConfig struct {
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		// RedirectURL is the URL of the callback registered at the
+		// provider. If not set it is derived from the request.
+		RedirectURL string
+		// AllowedGroups restricts the login to users having one of the
+		// groups in their "groups" claim. If empty all users of the
+		// provider may log in.
+		AllowedGroups []string
+	}
+
+	oidcClaims struct {
+		Email             string   `json:"email"`
+		Groups            []string `json:"groups"`
+		PreferredUsername string   `json:"preferred_username"`
+	}
+
+	// oidcFlow contains the state of a running login stored in a
+	// cookie of the browser between redirect and callback
+	oidcFlow struct {
+		State    string `json:"state"`
+		Nonce    string `json:"nonce"`
+		Verifier string `json:"verifier"`
+	}
+
+	oidcLogin struct {
+		cfg      OIDCConfig
+		oauth    oauth2.Config
+		verifier *oidc.IDTokenVerifier
+	}
+)
+
+func newOIDCLogin(ctx context.Context, cfg OIDCConfig) (*oidcLogin, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discovering provider: %w", err)
+	}
+
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	if len(cfg.AllowedGroups) > 0 {
+		// Not all providers know the scope, only request it when needed
+		scopes = append(scopes, "groups")
+	}
+
+	return &oidcLogin{
+		cfg: cfg,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+func (a apiServer) handleGetLoginOptions(w http.ResponseWriter, _ *http.Request) {
+	a.jsonResponse(w, http.StatusOK, struct {
+		OIDC bool `json:"oidc"`
+	}{a.oidc != nil})
+}
+
+func (a apiServer) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	flow, err := a.readOIDCFlow(w, r)
+	if err != nil {
+		a.errorResponse(w, err, "reading login state", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("state") != flow.State {
+		a.errorResponse(w, errors.New("state mismatch"), "validating callback", http.StatusBadRequest)
+		return
+	}
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		a.errorResponse(w, fmt.Errorf("%s: %s", e, r.URL.Query().Get("error_description")), "logging in at provider", http.StatusUnauthorized)
+		return
+	}
+
+	conf, err := a.oidcOAuthConfig(r)
+	if err != nil {
+		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := conf.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		a.errorResponse(w, err, "exchanging code", http.StatusUnauthorized)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		a.errorResponse(w, errors.New("no id_token in response"), "exchanging code", http.StatusUnauthorized)
+		return
+	}
+
+	idToken, err := a.oidc.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		a.errorResponse(w, err, "verifying id_token", http.StatusUnauthorized)
+		return
+	}
+
+	if idToken.Nonce != flow.Nonce {
+		a.errorResponse(w, errors.New("nonce mismatch"), "verifying id_token", http.StatusUnauthorized)
+		return
+	}
+
+	var claims oidcClaims
+	if err = idToken.Claims(&claims); err != nil {
+		a.errorResponse(w, err, "parsing claims", http.StatusUnauthorized)
+		return
+	}
+
+	if len(a.oidc.cfg.AllowedGroups) > 0 && !slices.ContainsFunc(claims.Groups, func(g string) bool {
+		return slices.Contains(a.oidc.cfg.AllowedGroups, g)
+	}) {
+		a.errorResponse(w, errors.New("user is not in any allowed group"), "checking groups", http.StatusForbidden)
+		return
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		username = idToken.Subject
+	}
+
+	user, err := a.dbc.GetOrCreateOIDCUser(idToken.Subject, username)
+	switch {
+	case errors.Is(err, database.ErrUsernameTaken):
+		// Linking the login to an existing local user would allow the
+		// provider to take over that account
+		a.errorResponse(w, err, "username belongs to a local user, ask an admin to rename it", http.StatusConflict)
+		return
+
+	case err != nil:
+		a.errorResponse(w, err, "getting user", http.StatusInternalServerError)
+		return
+	}
+
+	if err = a.startSession(w, r, user); err != nil {
+		a.errorResponse(w, err, "creating session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (a apiServer) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	conf, err := a.oidcOAuthConfig(r)
+	if err != nil {
+		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
+		return
+	}
+
+	flow := oidcFlow{Verifier: oauth2.GenerateVerifier()}
+	for _, v := range []*string{&flow.State, &flow.Nonce} {
+		if *v, err = randomString(); err != nil {
+			a.errorResponse(w, err, "generating login state", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	raw, err := json.Marshal(flow)
+	if err != nil {
+		a.errorResponse(w, err, "encoding login state", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(raw),
+		Path:     "/",
+		MaxAge:   oidcFlowCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, conf.AuthCodeURL(
+		flow.State,
+		oidc.Nonce(flow.Nonce),
+		oauth2.S256ChallengeOption(flow.Verifier),
+	), http.StatusFound)
+}
+
+// oidcOAuthConfig returns the OAuth2 config having the RedirectURL
+// set to the configured one or to the callback on the host the
+// request was made to
+func (a apiServer) oidcOAuthConfig(r *http.Request) (oauth2.Config, error) {
+	conf := a.oidc.oauth
+	conf.RedirectURL = a.oidc.cfg.RedirectURL
+
+	if conf.RedirectURL != "" {
+		return conf, nil
+	}
+
+	u, err := a.router.Get("OIDCCallback").URL()
+	if err != nil {
+		return conf, fmt.Errorf("getting callback route: %w", err)
+	}
+
+	u.Scheme = "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+
+	conf.RedirectURL = u.String()
+	return conf, nil
+}
+
+// readOIDCFlow reads and removes the state of the running login
+func (apiServer) readOIDCFlow(w http.ResponseWriter, r *http.Request) (flow oidcFlow, err error) {
+	c, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return flow, fmt.Errorf("getting cookie: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	raw, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return flow, fmt.Errorf("decoding cookie: %w", err)
+	}
+
+	if err = json.Unmarshal(raw, &flow); err != nil {
+		return flow, fmt.Errorf("parsing cookie: %w", err)
+	}
+
+	return flow, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, oidcNonceBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+type (
+	// mockOIDCProvider is a minimal OpenID Connect provider logging in
+	// every user with the configured claims without asking
+	mockOIDCProvider struct {
+		*httptest.Server
+
+		key    *rsa.PrivateKey
+		claims map[string]any
+
+		lock  sync.Mutex
+		codes map[string]mockOIDCCode
+		scope string
+	}
+
+	mockOIDCCode struct {
+		challenge string
+		nonce     string
+	}
+)
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newMockOIDCProvider(t, map[string]any{
+		"sub":                "jane-sub",
+		"preferred_username": "jane",
+		"groups":             []string{"staff", "accounting"},
+	})
+
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	require.NoError(t, RegisterHandler(router.PathPrefix("/api").Subrouter(), dbc, logrus.StandardLogger(), OIDCConfig{
+		Issuer:        provider.URL,
+		ClientID:      "accounting",
+		ClientSecret:  "secret",
+		AllowedGroups: []string{"accounting"},
+	}))
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(app.URL + "/api/me")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Follows the redirects through the provider back to the app
+	// which finally redirects to the (here not existing) frontend
+	resp, err = client.Get(app.URL + "/api/oidc/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "/", resp.Request.URL.Path)
+
+	resp, err = client.Get(app.URL + "/api/me")
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // Test code
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var me database.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	assert.Equal(t, "jane", me.Username)
+	assert.False(t, me.Admin)
+	assert.Contains(t, strings.Fields(provider.scope), "groups")
+
+	// Users not being in an allowed group are rejected
+	provider.claims = map[string]any{"sub": "john-sub", "preferred_username": "john", "groups": []string{"staff"}}
+
+	resp, err = (&http.Client{}).Get(app.URL + "/api/oidc/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "callback without flow cookie")
+
+	jar, err = cookiejar.New(nil)
+	require.NoError(t, err)
+	resp, err = (&http.Client{Jar: jar}).Get(app.URL + "/api/oidc/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Logins must not take over local users having the same name
+	_, err = dbc.CreateUser("john", "correct horse", false)
+	require.NoError(t, err)
+	provider.claims = map[string]any{"sub": "john-sub", "preferred_username": "john", "groups": []string{"accounting"}}
+
+	jar, err = cookiejar.New(nil)
+	require.NoError(t, err)
+	resp, err = (&http.Client{Jar: jar}).Get(app.URL + "/api/oidc/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestOIDCScopes(t *testing.T) {
+	provider := newMockOIDCProvider(t, nil)
+
+	login, err := newOIDCLogin(t.Context(), OIDCConfig{Issuer: provider.URL, ClientID: "accounting"})
+	require.NoError(t, err)
+	assert.NotContains(t, login.oauth.Scopes, "groups")
+
+	login, err = newOIDCLogin(t.Context(), OIDCConfig{Issuer: provider.URL, ClientID: "accounting", AllowedGroups: []string{"accounting"}})
+	require.NoError(t, err)
+	assert.Contains(t, login.oauth.Scopes, "groups")
+}
+
+func newMockOIDCProvider(t *testing.T, claims map[string]any) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048) //revive:disable-line:add-constant // key size
+	require.NoError(t, err)
+
+	p := &mockOIDCProvider{key: key, claims: claims, codes: map[string]mockOIDCCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/jwks", p.handleJWKS)
+	mux.HandleFunc("/token", p.handleToken)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *mockOIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE required", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+
+	p.lock.Lock()
+	p.codes[code] = mockOIDCCode{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	p.scope = q.Get("scope")
+	p.lock.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{"code": {code}, "state": {q.Get("state")}}
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *mockOIDCProvider) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *mockOIDCProvider) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &p.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+	}})
+}
+
+func (p *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	code, ok := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	p.lock.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown code", http.StatusBadRequest)
+		return
+	}
+
+	verifierHash := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifierHash[:]) != code.challenge {
+		http.Error(w, "PKCE verification failed", http.StatusBadRequest)
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   p.URL,
+		"aud":   "accounting",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": code.nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test"),
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sig, err := signer.Sign(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	idToken, err := sig.CompactSerialize()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600, //revive:disable-line:add-constant // seconds
+		"id_token":     idToken,
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleCreateImportProfile(w http.ResponseWriter, r *http.Request) {
+	var payload database.ImportProfile
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.ID != uuid.Nil {
+		a.errorResponse(w, errors.New("import profile id must be unset"), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	p, err := a.dbc.AsUser(userFromRequest(r).ID).CreateImportProfile(payload)
+	if err != nil {
+		a.errorResponse(w, err, "creating import profile", http.StatusInternalServerError)
+		return
+	}
+
+	u, err := a.router.Get("GetImportProfile").URL("id", p.ID.String())
+	if err != nil {
+		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (a apiServer) handleDeleteImportProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.AsUser(userFromRequest(r).ID).DeleteImportProfile(id); err != nil {
+		a.errorResponse(w, err, "deleting import profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleGetImportProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	p, err := a.dbc.GetImportProfile(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "getting import profile", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "getting import profile", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, p)
+}
+
+func (a apiServer) handleListImportProfiles(w http.ResponseWriter, _ *http.Request) {
+	p, err := a.dbc.ListImportProfiles()
+	if err != nil {
+		a.errorResponse(w, err, "getting import profiles", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, p)
+}
+
+func (a apiServer) handleUpdateImportProfile(w http.ResponseWriter, r *http.Request) {
+	var (
+		id  uuid.UUID
+		p   database.ImportProfile
+		err error
+	)
+
+	if id, err = uuid.Parse(mux.Vars(r)["id"]); err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.AsUser(userFromRequest(r).ID).UpdateImportProfile(id, p); err != nil {
+		a.errorResponse(w, err, "updating import profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
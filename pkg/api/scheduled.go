@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+const defaultUpcomingRange = 30 * 24 * time.Hour
+
+func (a apiServer) handleCreateScheduledTransaction(w http.ResponseWriter, r *http.Request) {
+	var payload database.ScheduledTransaction
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.ID != uuid.Nil {
+		a.errorResponse(w, errors.New("scheduled transaction id must be unset"), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	s, err := a.dbc.CreateScheduledTransaction(payload)
+	if err != nil {
+		a.errorResponse(w, err, "creating scheduled transaction", http.StatusInternalServerError)
+		return
+	}
+
+	u, err := a.router.Get("GetScheduledTransaction").URL("budget", a.dbc.Budget().ID.String(), "id", s.ID.String())
+	if err != nil {
+		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (a apiServer) handleDeleteScheduledTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.DeleteScheduledTransaction(id); err != nil {
+		a.errorResponse(w, err, "deleting scheduled transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleGetScheduledTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	s, err := a.dbc.GetScheduledTransaction(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "getting scheduled transaction", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "getting scheduled transaction", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, s)
+}
+
+func (a apiServer) handleListScheduledTransactions(w http.ResponseWriter, _ *http.Request) {
+	s, err := a.dbc.ListScheduledTransactions()
+	if err != nil {
+		a.errorResponse(w, err, "getting scheduled transactions", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, s)
+}
+
+func (a apiServer) handleListUpcomingTransactions(w http.ResponseWriter, r *http.Request) {
+	until := time.Now().Add(defaultUpcomingRange)
+	if v, err := time.Parse(time.RFC3339, r.URL.Query().Get("until")); err == nil {
+		until = v
+	}
+
+	o, err := a.dbc.ListUpcomingTransactions(until)
+	if err != nil {
+		a.errorResponse(w, err, "getting upcoming transactions", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, o)
+}
+
+func (a apiServer) handleUpdateScheduledTransaction(w http.ResponseWriter, r *http.Request) {
+	var (
+		id  uuid.UUID
+		s   database.ScheduledTransaction
+		err error
+	)
+
+	if id, err = uuid.Parse(mux.Vars(r)["id"]); err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&s); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.UpdateScheduledTransaction(id, s); err != nil {
+		a.errorResponse(w, err, "updating scheduled transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -35,7 +35,7 @@ func (a apiServer) handleCreateTransaction(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	u, err := a.router.Get("GetTransactionByID").URL("id", tx.ID.String())
+	u, err := a.router.Get("GetTransactionByID").URL("budget", a.dbc.Budget().ID.String(), "id", tx.ID.String())
 	if err != nil {
 		a.errorResponse(w, err, "getting redirect url", http.StatusInternalServerError)
 		return
@@ -120,6 +120,10 @@ func (a apiServer) handleListTransactionsByAccount(w http.ResponseWriter, r *htt
 
 	txs, err := a.dbc.ListTransactionsByAccount(accid, since, until)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "getting transactions", http.StatusNotFound)
+			return
+		}
 		a.errorResponse(w, err, "getting transactions", http.StatusInternalServerError)
 		return
 	}
@@ -129,7 +133,12 @@ func (a apiServer) handleListTransactionsByAccount(w http.ResponseWriter, r *htt
 
 func (a apiServer) handleOverwriteTransaction(w http.ResponseWriter, r *http.Request) {
 	var (
-		tx   database.Transaction
+		payload struct {
+			database.Transaction
+			// Splits are only replaced when given: clients not knowing
+			// about splits must not remove them
+			Splits *[]database.TransactionSplit `json:"splits"`
+		}
 		txID uuid.UUID
 		err  error
 	)
@@ -139,11 +148,27 @@ func (a apiServer) handleOverwriteTransaction(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err = json.NewDecoder(r.Body).Decode(&tx); err != nil {
+	if err = json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
 		return
 	}
 
+	tx := payload.Transaction
+	if payload.Splits != nil {
+		tx.Splits = *payload.Splits
+	} else {
+		old, err := a.dbc.GetTransactionByID(txID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				a.errorResponse(w, err, "getting transaction", http.StatusNotFound)
+				return
+			}
+			a.errorResponse(w, err, "getting transaction", http.StatusInternalServerError)
+			return
+		}
+		tx.Splits = old.Splits
+	}
+
 	if err = a.dbc.UpdateTransaction(txID, tx); err != nil {
 		a.errorResponse(w, err, "updating transaction", http.StatusInternalServerError)
 		return
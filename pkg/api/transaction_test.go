@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestTransactionSplits(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	user, err := dbc.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	budget, err := dbc.CreateBudget("Household", user.ID)
+	require.NoError(t, err)
+	bc, err := dbc.ForBudget(budget.ID)
+	require.NoError(t, err)
+
+	checking, err := bc.CreateAccount("Checking", database.AccountTypeBudget)
+	require.NoError(t, err)
+	food, err := bc.CreateAccount("Food", database.AccountTypeCategory)
+	require.NoError(t, err)
+	household, err := bc.CreateAccount("Household", database.AccountTypeCategory)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	require.NoError(t, RegisterHandler(router.PathPrefix("/api").Subrouter(), dbc, logrus.StandardLogger(), OIDCConfig{}))
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+
+	var (
+		client = testLogin(t, app.URL, "alice", "correct horse")
+		prefix = fmt.Sprintf("%s/api/budgets/%s/transactions", app.URL, budget.ID)
+		splits = fmt.Sprintf(`[{"description":"Bread","amount":-12.5,"category":%q},{"description":"Soap","amount":-7.5,"category":%q}]`, food.ID, household.ID)
+	)
+
+	do := func(method, url, body string, expStatus int) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+
+		require.Equal(t, expStatus, resp.StatusCode, "%s %s", method, url)
+		return resp
+	}
+
+	get := func(id string) (tx database.Transaction) {
+		t.Helper()
+
+		resp := do(http.MethodGet, prefix+"/"+id, "", http.StatusOK)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tx))
+		return tx
+	}
+
+	// Create redirects to the created transaction including its splits
+	resp := do(http.MethodPost, prefix, fmt.Sprintf(
+		`{"time":"2024-01-05T12:00:00Z","payee":"Supermarket","amount":-20,"account":%q,"splits":%s}`,
+		checking.ID, splits,
+	), http.StatusOK)
+
+	var tx database.Transaction
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tx))
+	require.Len(t, tx.Splits, 2)
+	assert.Equal(t, database.Money(-1250), tx.Splits[0].Amount)
+
+	id := tx.ID.String()
+	assert.Len(t, get(id).Splits, 2)
+
+	// Overwriting without splits keeps them
+	do(http.MethodPut, prefix+"/"+id, fmt.Sprintf(
+		`{"time":"2024-01-05T12:00:00Z","payee":"Corner Shop","amount":-20,"account":%q}`,
+		checking.ID,
+	), http.StatusNoContent)
+
+	tx = get(id)
+	assert.Equal(t, "Corner Shop", tx.Payee)
+	assert.Len(t, tx.Splits, 2)
+
+	// Patching a split keeps the others
+	do(http.MethodPatch, prefix+"/"+id, `[
+		{"op":"replace","path":"/amount","value":-25},
+		{"op":"replace","path":"/splits/1/amount","value":-12.5}
+	]`, http.StatusNoContent)
+
+	tx = get(id)
+	require.Len(t, tx.Splits, 2)
+	assert.Equal(t, database.Money(-1250), tx.Splits[1].Amount)
+
+	// Given splits replace the existing ones, an empty list removes them
+	do(http.MethodPut, prefix+"/"+id, fmt.Sprintf(
+		`{"time":"2024-01-05T12:00:00Z","payee":"Corner Shop","amount":-25,"account":%q,"category":%q,"splits":[]}`,
+		checking.ID, food.ID,
+	), http.StatusNoContent)
+
+	tx = get(id)
+	assert.Empty(t, tx.Splits)
+	assert.Equal(t, food.ID, tx.Category.UUID)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleListTrash(w http.ResponseWriter, _ *http.Request) {
+	t, err := a.dbc.ListTrash()
+	if err != nil {
+		a.errorResponse(w, err, "listing trash", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, t)
+}
+
+func (a apiServer) handleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.RestoreAccount(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.errorResponse(w, err, "restoring account", http.StatusNotFound)
+			return
+		}
+		a.errorResponse(w, err, "restoring account", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleRestoreTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.RestoreTransaction(id); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			a.errorResponse(w, err, "restoring transaction", http.StatusNotFound)
+		case errors.Is(err, database.ErrDeletedAccount):
+			a.errorResponse(w, err, "restoring transaction", http.StatusConflict)
+		default:
+			a.errorResponse(w, err, "restoring transaction", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleRedo(w http.ResponseWriter, _ *http.Request) {
+	op, err := a.dbc.Redo()
+	if err != nil {
+		if errors.Is(err, database.ErrNothingToRedo) || errors.Is(err, database.ErrUndoConflict) {
+			a.errorResponse(w, err, "redoing operation", http.StatusConflict)
+			return
+		}
+		a.errorResponse(w, err, "redoing operation", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, op)
+}
+
+func (a apiServer) handleUndo(w http.ResponseWriter, _ *http.Request) {
+	op, err := a.dbc.Undo()
+	if err != nil {
+		if errors.Is(err, database.ErrNothingToUndo) || errors.Is(err, database.ErrUndoConflict) {
+			a.errorResponse(w, err, "undoing operation", http.StatusConflict)
+			return
+		}
+		a.errorResponse(w, err, "undoing operation", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, op)
+}
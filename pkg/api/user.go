@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func (a apiServer) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Name == "" {
+		a.errorResponse(w, errors.New("empty name"), "validating request", http.StatusBadRequest)
+		return
+	}
+
+	t, token, err := a.dbc.CreateAPIToken(userFromRequest(r).ID, payload.Name)
+	if err != nil {
+		a.errorResponse(w, err, "creating token", http.StatusInternalServerError)
+		return
+	}
+
+	// The token itself is only returned once and cannot be retrieved
+	// later on as only its hash is stored
+	a.jsonResponse(w, http.StatusCreated, struct {
+		database.APIToken
+		Token string `json:"token"`
+	}{t, token})
+}
+
+func (a apiServer) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Admin    bool   `json:"admin"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	u, err := a.dbc.AsUser(userFromRequest(r).ID).CreateUser(payload.Username, payload.Password, payload.Admin)
+	if err != nil {
+		a.errorResponse(w, err, "creating user", http.StatusBadRequest)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusCreated, u)
+}
+
+func (a apiServer) handleDeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.DeleteAPIToken(userFromRequest(r).ID, id); err != nil {
+		a.errorResponse(w, err, "deleting token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		a.errorResponse(w, err, "parsing id", http.StatusBadRequest)
+		return
+	}
+
+	if id == userFromRequest(r).ID {
+		a.errorResponse(w, errors.New("cannot delete own user"), "deleting user", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.dbc.AsUser(userFromRequest(r).ID).DeleteUser(id); err != nil {
+		if errors.Is(err, database.ErrLastOwner) {
+			a.errorResponse(w, err, "deleting user", http.StatusConflict)
+			return
+		}
+		a.errorResponse(w, err, "deleting user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a apiServer) handleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	t, err := a.dbc.ListAPITokens(userFromRequest(r).ID)
+	if err != nil {
+		a.errorResponse(w, err, "getting tokens", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, t)
+}
+
+func (a apiServer) handleListUsers(w http.ResponseWriter, _ *http.Request) {
+	u, err := a.dbc.ListUsers()
+	if err != nil {
+		a.errorResponse(w, err, "getting users", http.StatusInternalServerError)
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, u)
+}
+
+func (a apiServer) handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		a.errorResponse(w, err, "parsing body", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.dbc.AsUser(userFromRequest(r).ID).UpdateUserPassword(userFromRequest(r).ID, payload.Password); err != nil {
+		a.errorResponse(w, err, "updating password", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
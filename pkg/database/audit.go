@@ -0,0 +1,454 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const auditBeforeKey = "audit:before"
+
+type (
+	// AuditFilter limits the entries returned by ListAuditEntries.
+	// Unset fields do not filter.
+	AuditFilter struct {
+		Entity   string
+		EntityID uuid.NullUUID
+		User     uuid.NullUUID
+		Since    time.Time
+		Until    time.Time
+	}
+
+	auditContextKey int
+
+	// auditRows contains database rows keyed by their ID
+	auditRows map[uuid.UUID]map[string]any
+)
+
+const (
+	auditKeyBudget auditContextKey = iota
+	auditKeyOperation
+	auditKeyOperationKind
+	auditKeyReverts
+	auditKeySkip
+	auditKeyUser
+)
+
+var (
+	// auditSkipTables contain authentication and bookkeeping records
+	// not worth an audit entry
+	auditSkipTables = []string{"api_tokens", "audit_entries", "operations", "schema_migrations", "sessions"}
+	// auditRedactColumns contain secrets not to be copied into the
+	// audit log
+	auditRedactColumns = []string{"password_hash"}
+	// auditBudgetChildTables contain records belonging to the budget
+	// of the client without having a budget column themselves
+	auditBudgetChildTables = []string{"category_goals", "transaction_splits"}
+)
+
+// AsUser returns a client recording the given user as author of all
+// changes in the audit log
+func (c *Client) AsUser(user uuid.UUID) *Client {
+	return &Client{db: withContextValue(c.db, auditKeyUser, user), budget: c.budget}
+}
+
+// InOperation returns a client grouping all changes into one Operation
+// of its budget, which can be reverted through Undo. Use one client for
+// each change requested by the user.
+func (c *Client) InOperation() *Client {
+	db := withContextValue(c.db, auditKeyOperation, uuid.New())
+	return &Client{db: withContextValue(db, auditKeyOperationKind, OperationKindChange), budget: c.budget}
+}
+
+// ListAuditEntries returns the audit entries of the budget of the
+// client matching the filter, newest first
+func (c *Client) ListAuditEntries(filter AuditFilter) (e []AuditEntry, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return filter.apply(db.Where("budget = ?", c.budget.ID)).Find(&e).Error
+	}); err != nil {
+		return e, fmt.Errorf("listing audit entries: %w", err)
+	}
+
+	return e, nil
+}
+
+// ListAllAuditEntries returns the audit entries of all budgets and
+// those not belonging to a budget matching the filter, newest first
+func (c *Client) ListAllAuditEntries(filter AuditFilter) (e []AuditEntry, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return filter.apply(db).Find(&e).Error
+	}); err != nil {
+		return e, fmt.Errorf("listing audit entries: %w", err)
+	}
+
+	return e, nil
+}
+
+// withoutAudit returns a client not writing audit entries, used for
+// migrations, restores and copies
+func (c *Client) withoutAudit() *Client {
+	return &Client{db: withContextValue(c.db, auditKeySkip, true), budget: c.budget}
+}
+
+func (f AuditFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.Entity != "" {
+		db = db.Where("entity = ?", f.Entity)
+	}
+
+	if f.EntityID.Valid {
+		db = db.Where("entity_id = ?", f.EntityID.UUID)
+	}
+
+	if f.User.Valid {
+		db = db.Where("user_id = ?", f.User.UUID)
+	}
+
+	if !f.Since.IsZero() {
+		db = db.Where("created_at >= ?", f.Since)
+	}
+
+	if !f.Until.IsZero() {
+		db = db.Where("created_at < ?", f.Until)
+	}
+
+	return db.Order("created_at DESC, id")
+}
+
+// registerAuditCallbacks hooks into all create, update and delete
+// statements to write the AuditEntry records for the changed rows
+// within the same transaction
+func registerAuditCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+
+	return errors.Join(
+		cb.Create().After("gorm:create").Register("audit:after_create", auditAfter(AuditActionCreate)),
+		cb.Update().Before("gorm:update").Register("audit:before_update", auditBefore),
+		cb.Update().After("gorm:update").Register("audit:after_update", auditAfter(AuditActionUpdate)),
+		cb.Delete().Before("gorm:delete").Register("audit:before_delete", auditBefore),
+		cb.Delete().After("gorm:delete").Register("audit:after_delete", auditAfter(AuditActionDelete)),
+	)
+}
+
+// auditAfter returns a callback reading the changed rows and writing
+// the audit entries comparing them to the rows read before
+func auditAfter(action AuditAction) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || !auditEnabled(db) {
+			return
+		}
+
+		before := auditRows{}
+		if v, ok := db.InstanceGet(auditBeforeKey); ok {
+			before = v.(auditRows) //nolint:forcetypeassert // Only set by auditBefore
+		}
+
+		ids := make([]uuid.UUID, 0, len(before))
+		for id := range before {
+			ids = append(ids, id)
+		}
+
+		if action == AuditActionCreate {
+			ids = auditCreatedIDs(db)
+		}
+
+		if len(ids) == 0 {
+			return
+		}
+
+		after, err := auditReadRows(auditQuery(db).Where("id IN ?", ids))
+		if err != nil {
+			db.AddError(fmt.Errorf("reading changed rows for audit: %w", err))
+			return
+		}
+
+		entries := make([]AuditEntry, 0, len(ids))
+		for _, id := range ids {
+			if action == AuditActionUpdate && !auditChanged(before[id], after[id]) {
+				continue
+			}
+
+			entries = append(entries, newAuditEntry(db, action, id, before[id], after[id]))
+		}
+
+		if len(entries) == 0 {
+			return
+		}
+
+		if err = db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+			db.AddError(fmt.Errorf("writing audit entries: %w", err))
+			return
+		}
+
+		if err = auditRecordOperation(db); err != nil {
+			db.AddError(fmt.Errorf("recording operation: %w", err))
+		}
+	}
+}
+
+// auditBefore reads the rows affected by an update or delete statement
+// before it is executed
+func auditBefore(db *gorm.DB) {
+	if db.Error != nil || !auditEnabled(db) {
+		return
+	}
+
+	stmt := db.Statement
+	q := auditQuery(db)
+
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			q = q.Clauses(where)
+		}
+	}
+
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		// Saving or deleting a loaded record addresses it by its ID
+		if id, isZero := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, stmt.ReflectValue); !isZero {
+			q = q.Where("id = ?", id)
+		}
+	}
+
+	if stmt.Schema.LookUpField("DeletedAt") != nil && !stmt.Unscoped {
+		q = q.Where("deleted_at IS NULL")
+	}
+
+	rows, err := auditReadRows(q)
+	if err != nil {
+		db.AddError(fmt.Errorf("reading rows for audit: %w", err))
+		return
+	}
+
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+// auditChanged compares two states of a row ignoring the update
+// timestamp which changes even if nothing else did
+func auditChanged(before, after map[string]any) bool {
+	if len(before) != len(after) {
+		return true
+	}
+
+	for k, v := range before {
+		if k != "updated_at" && !reflect.DeepEqual(v, after[k]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// auditCreatedIDs returns the IDs of the records passed into a create
+// statement
+func auditCreatedIDs(db *gorm.DB) (ids []uuid.UUID) {
+	stmt := db.Statement
+	field := stmt.Schema.PrioritizedPrimaryField
+
+	add := func(rv reflect.Value) {
+		if v, isZero := field.ValueOf(stmt.Context, reflect.Indirect(rv)); !isZero {
+			if id, ok := auditUUID(v); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range stmt.ReflectValue.Len() {
+			add(stmt.ReflectValue.Index(i))
+		}
+
+	case reflect.Struct:
+		add(stmt.ReflectValue)
+
+	case reflect.Map:
+		// Records created from column maps when reverting a delete
+		if row, ok := stmt.ReflectValue.Interface().(map[string]any); ok {
+			if id, ok := auditUUID(row["id"]); ok {
+				ids = append(ids, id)
+			}
+		}
+
+	default:
+		// Nothing we could audit
+	}
+
+	return ids
+}
+
+// auditEnabled checks whether changes of the statement are recorded
+func auditEnabled(db *gorm.DB) bool {
+	stmt := db.Statement
+
+	if stmt.Context != nil {
+		if skip, _ := stmt.Context.Value(auditKeySkip).(bool); skip {
+			return false
+		}
+	}
+
+	return stmt.Schema != nil &&
+		stmt.Schema.PrioritizedPrimaryField != nil &&
+		stmt.Schema.PrioritizedPrimaryField.DBName == "id" &&
+		!slices.Contains(auditSkipTables, stmt.Table)
+}
+
+// auditQuery returns a new query on the table of the statement
+// sharing its transaction
+func auditQuery(db *gorm.DB) *gorm.DB {
+	q := db.Session(&gorm.Session{NewDB: true})
+	if db.Statement.Table == "" {
+		return q
+	}
+
+	return q.Table(db.Statement.Table)
+}
+
+// auditRecordOperation creates the Operation the entries of the
+// statement belong to if the changes are grouped through InOperation
+// and the Operation does not exist yet
+func auditRecordOperation(db *gorm.DB) error {
+	ctx := db.Statement.Context
+
+	kind, ok := ctx.Value(auditKeyOperationKind).(OperationKind)
+	if !ok {
+		return nil
+	}
+
+	op := Operation{CreatedAt: time.Now().UTC(), Kind: kind}
+	op.ID, _ = ctx.Value(auditKeyOperation).(uuid.UUID)
+	op.Budget, _ = ctx.Value(auditKeyBudget).(uuid.UUID)
+
+	if op.ID == uuid.Nil || op.Budget == uuid.Nil {
+		return nil
+	}
+
+	if user, ok := ctx.Value(auditKeyUser).(uuid.UUID); ok {
+		op.User = uuid.NullUUID{UUID: user, Valid: true}
+	}
+
+	if reverts, ok := ctx.Value(auditKeyReverts).(uuid.UUID); ok {
+		op.Reverts = uuid.NullUUID{UUID: reverts, Valid: true}
+	}
+
+	//nolint:wrapcheck // is wrapped in the callback
+	return db.Session(&gorm.Session{NewDB: true}).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&op).
+		Error
+}
+
+// auditReadRows reads the rows of the query as plain column maps to
+// record all fields including those hidden in the API
+func auditReadRows(q *gorm.DB) (auditRows, error) {
+	var rows []map[string]any
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err //nolint:wrapcheck // is wrapped in the callback
+	}
+
+	res := auditRows{}
+	for _, row := range rows {
+		for _, col := range auditRedactColumns {
+			delete(row, col)
+		}
+
+		for col, v := range row {
+			if id, ok := v.([16]byte); ok {
+				// Postgres returns UUIDs as raw bytes
+				row[col] = uuid.UUID(id).String()
+			}
+		}
+
+		if id, ok := auditUUID(row["id"]); ok {
+			res[id] = row
+		}
+	}
+
+	return res, nil
+}
+
+// auditUUID converts the representations of an UUID returned by the
+// database drivers
+func auditUUID(v any) (uuid.UUID, bool) {
+	switch id := v.(type) {
+	case uuid.UUID:
+		return id, id != uuid.Nil
+
+	case [16]byte:
+		return uuid.UUID(id), true
+
+	case []byte:
+		if u, err := uuid.ParseBytes(id); err == nil {
+			return u, true
+		}
+		if u, err := uuid.FromBytes(id); err == nil {
+			return u, true
+		}
+
+	case string:
+		if u, err := uuid.Parse(id); err == nil {
+			return u, true
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+func newAuditEntry(db *gorm.DB, action AuditAction, id uuid.UUID, before, after map[string]any) AuditEntry {
+	ctx := db.Statement.Context
+	table := db.Statement.Table
+
+	e := AuditEntry{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		Entity:    table,
+		EntityID:  id,
+		Action:    action,
+		Before:    before,
+		After:     after,
+	}
+
+	if op, ok := ctx.Value(auditKeyOperation).(uuid.UUID); ok {
+		e.Operation = op
+	}
+
+	if user, ok := ctx.Value(auditKeyUser).(uuid.UUID); ok {
+		e.User = uuid.NullUUID{UUID: user, Valid: true}
+	}
+
+	row := after
+	if row == nil {
+		row = before
+	}
+
+	switch budget, ok := auditUUID(row["budget"]); {
+	case ok:
+		e.Budget = uuid.NullUUID{UUID: budget, Valid: true}
+
+	case table == "budgets":
+		e.Budget = uuid.NullUUID{UUID: id, Valid: true}
+
+	case slices.Contains(auditBudgetChildTables, table):
+		if budget, ok := ctx.Value(auditKeyBudget).(uuid.UUID); ok {
+			e.Budget = uuid.NullUUID{UUID: budget, Valid: true}
+		}
+	}
+
+	return e
+}
+
+// withContextValue returns the database handle carrying the value in
+// its context
+func withContextValue(db *gorm.DB, key auditContextKey, value any) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return db.WithContext(context.WithValue(ctx, key, value))
+}
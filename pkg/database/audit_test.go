@@ -0,0 +1,109 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	user, err := dbc.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	adbc := dbc.AsUser(user.ID)
+
+	start := time.Now().Add(-time.Second)
+
+	checking, err := adbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := adbc.CreateAccount("Savings", AccountTypeBudget)
+	require.NoError(t, err)
+
+	require.NoError(t, adbc.UpdateAccountName(checking.ID, "Giro"))
+
+	entries, err := adbc.ListAuditEntries(AuditFilter{Entity: "accounts", EntityID: uuid.NullUUID{UUID: checking.ID, Valid: true}})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// Newest first
+	assert.Equal(t, AuditActionUpdate, entries[0].Action)
+	assert.Equal(t, "Checking", entries[0].Before["name"])
+	assert.Equal(t, "Giro", entries[0].After["name"])
+	assert.Equal(t, uuid.NullUUID{UUID: user.ID, Valid: true}, entries[0].User)
+	assert.Equal(t, uuid.NullUUID{UUID: DefaultBudget, Valid: true}, entries[0].Budget)
+
+	assert.Equal(t, AuditActionCreate, entries[1].Action)
+	assert.Nil(t, entries[1].Before)
+	assert.Equal(t, "Checking", entries[1].After["name"])
+
+	// No-op updates are not recorded
+	require.NoError(t, adbc.UpdateAccountName(checking.ID, "Giro"))
+	entries, err = adbc.ListAuditEntries(AuditFilter{EntityID: uuid.NullUUID{UUID: checking.ID, Valid: true}})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// Both parts of a transfer are deleted in one operation
+	require.NoError(t, adbc.TransferMoney(checking.ID, savings.ID, 1000, "savings"))
+	txs, err := adbc.ListTransactionsByAccount(savings.ID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.NoError(t, adbc.DeleteTransaction(txs[0].ID))
+
+	entries, err = adbc.ListAuditEntries(AuditFilter{Entity: "transactions"})
+	require.NoError(t, err)
+	require.Len(t, entries, 4) //nolint:mnd // 2 created, 2 deleted
+
+	assert.Equal(t, AuditActionDelete, entries[0].Action)
+	assert.Equal(t, AuditActionDelete, entries[1].Action)
+	assert.Equal(t, entries[0].Operation, entries[1].Operation)
+	assert.Nil(t, entries[0].Before["deleted_at"])
+	assert.NotNil(t, entries[0].After["deleted_at"])
+
+	// Splits are recorded as separate entities in the same operation
+	tx, err := adbc.CreateTransaction(Transaction{
+		Time:     time.Now(),
+		Payee:    "Supermarket",
+		Amount:   -500,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+
+	tx.Category = uuid.NullUUID{}
+	tx.Splits = []TransactionSplit{
+		{Amount: -200, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		{Amount: -300, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+	}
+	require.NoError(t, adbc.UpdateTransaction(tx.ID, tx))
+
+	entries, err = adbc.ListAuditEntries(AuditFilter{Entity: "transaction_splits"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	txEntries, err := adbc.ListAuditEntries(AuditFilter{EntityID: uuid.NullUUID{UUID: tx.ID, Valid: true}})
+	require.NoError(t, err)
+	require.Len(t, txEntries, 2)
+	assert.Equal(t, entries[0].Operation, txEntries[0].Operation)
+
+	// Filters by user and time
+	entries, err = adbc.ListAuditEntries(AuditFilter{User: uuid.NullUUID{UUID: uuid.New(), Valid: true}})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = adbc.ListAuditEntries(AuditFilter{Until: start})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Users do not belong to a budget
+	entries, err = dbc.ListAllAuditEntries(AuditFilter{Entity: "users"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.False(t, entries[0].Budget.Valid)
+	assert.NotContains(t, entries[0].After, "password_hash")
+}
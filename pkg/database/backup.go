@@ -0,0 +1,418 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BackupVersion is the version of the Backup format written by this
+// version of the software. Restoring newer versions is refused,
+// version 1 backups (written before budgets were introduced) are
+// restored into the DefaultBudget. Backups before version 3 do not
+// contain budget members: the members of restored budgets are kept.
+const BackupVersion = 3
+
+const backupVersionMembers = 3
+
+const backupBatchSize = 100
+
+// ErrDatabaseNotEmpty signals a restore was refused as it would
+// overwrite existing data
+var ErrDatabaseNotEmpty = errors.New("database is not empty")
+
+type (
+	// Backup contains all records stored in the database including
+	// deleted ones and those fields not exposed through the API. Users
+	// are not part of the backup, budget members therefore reference
+	// them by their username.
+	Backup struct {
+		Version   int       `json:"version"`
+		CreatedAt time.Time `json:"createdAt"`
+
+		Budgets               []BackupBudget               `json:"budgets"`
+		BudgetMembers         []BackupBudgetMember         `json:"budgetMembers"`
+		Accounts              []BackupAccount              `json:"accounts"`
+		AccountGroups         []BackupAccountGroup         `json:"accountGroups"`
+		CategoryGoals         []BackupCategoryGoal         `json:"categoryGoals"`
+		ImportProfiles        []BackupImportProfile        `json:"importProfiles"`
+		Reconciliations       []BackupReconciliation       `json:"reconciliations"`
+		ScheduledTransactions []BackupScheduledTransaction `json:"scheduledTransactions"`
+		Transactions          []BackupTransaction          `json:"transactions"`
+		TransactionImportIDs  []BackupTransactionImportID  `json:"transactionImportIds"`
+	}
+
+	// BackupMeta contains the BaseModel fields hidden in the API
+	BackupMeta struct {
+		CreatedAt time.Time      `json:"createdAt"`
+		UpdatedAt time.Time      `json:"updatedAt"`
+		DeletedAt gorm.DeletedAt `json:"deletedAt"`
+	}
+
+	// BackupAccount wraps an Account for the Backup and exposes its
+	// Budget
+	BackupAccount struct {
+		Account
+		BackupMeta
+		Budget uuid.UUID `json:"budget"`
+	}
+
+	// BackupAccountGroup wraps an AccountGroup for the Backup and
+	// exposes its Budget
+	BackupAccountGroup struct {
+		AccountGroup
+		BackupMeta
+		Budget uuid.UUID `json:"budget"`
+	}
+
+	// BackupBudget wraps a Budget for the Backup
+	BackupBudget struct {
+		Budget
+		BackupMeta
+	}
+
+	// BackupBudgetMember wraps a BudgetMember for the Backup and
+	// exposes its Budget
+	BackupBudgetMember struct {
+		BudgetMember
+		BackupMeta
+		Budget uuid.UUID `json:"budget"`
+	}
+
+	// BackupCategoryGoal wraps a CategoryGoal for the Backup
+	BackupCategoryGoal struct {
+		CategoryGoal
+		BackupMeta
+	}
+
+	// BackupImportProfile wraps an ImportProfile for the Backup
+	BackupImportProfile struct {
+		ImportProfile
+		BackupMeta
+	}
+
+	// BackupReconciliation wraps a Reconciliation for the Backup and
+	// exposes its Budget
+	BackupReconciliation struct {
+		Reconciliation
+		BackupMeta
+		Budget uuid.UUID `json:"budget"`
+	}
+
+	// BackupScheduledTransaction wraps a ScheduledTransaction for the
+	// Backup and exposes its Budget
+	BackupScheduledTransaction struct {
+		ScheduledTransaction
+		BackupMeta
+		Budget uuid.UUID `json:"budget"`
+	}
+
+	// BackupTransaction wraps a Transaction for the Backup and exposes
+	// its Budget and PairKey
+	BackupTransaction struct {
+		Transaction
+		BackupMeta
+		Budget  uuid.UUID     `json:"budget"`
+		PairKey uuid.NullUUID `json:"pairKey"`
+	}
+
+	// BackupTransactionImportID wraps a TransactionImportID for the
+	// Backup and exposes its TransactionID
+	BackupTransactionImportID struct {
+		TransactionImportID
+		BackupMeta
+		TransactionID uuid.UUID `json:"transactionId"`
+	}
+)
+
+// Backup reads all records of all budgets from the database
+func (c *Client) Backup() (b Backup, err error) {
+	b = Backup{Version: BackupVersion, CreatedAt: time.Now().UTC()}
+
+	var (
+		budgets      []Budget
+		members      []BudgetMember
+		accounts     []Account
+		groups       []AccountGroup
+		goals        []CategoryGoal
+		profiles     []ImportProfile
+		reconciled   []Reconciliation
+		scheduled    []ScheduledTransaction
+		transactions []Transaction
+		importIDs    []TransactionImportID
+	)
+
+	if err = c.retryRead(func(db *gorm.DB) error {
+		for _, list := range []any{&budgets, &accounts, &groups, &goals, &profiles, &reconciled, &scheduled, &importIDs} {
+			if err := db.Unscoped().Order("created_at, id").Find(list).Error; err != nil {
+				return fmt.Errorf("reading %T: %w", list, err)
+			}
+		}
+
+		if err := db.
+			Model(&BudgetMember{}).
+			Select("budget_members.*, users.username").
+			Joins("JOIN users ON users.id = budget_members.user_id").
+			Order("budget_members.created_at, budget_members.id").
+			Find(&members).
+			Error; err != nil {
+			return fmt.Errorf("reading budget members: %w", err)
+		}
+
+		return db.Unscoped().Order("created_at, id").Preload("Splits").Find(&transactions).Error
+	}); err != nil {
+		return b, fmt.Errorf("reading records: %w", err)
+	}
+
+	for _, bu := range budgets {
+		b.Budgets = append(b.Budgets, BackupBudget{bu, backupMeta(bu.BaseModel)})
+	}
+	for _, m := range members {
+		b.BudgetMembers = append(b.BudgetMembers, BackupBudgetMember{m, backupMeta(m.BaseModel), m.Budget})
+	}
+	for _, a := range accounts {
+		b.Accounts = append(b.Accounts, BackupAccount{a, backupMeta(a.BaseModel), a.Budget})
+	}
+	for _, g := range groups {
+		b.AccountGroups = append(b.AccountGroups, BackupAccountGroup{g, backupMeta(g.BaseModel), g.Budget})
+	}
+	for _, g := range goals {
+		b.CategoryGoals = append(b.CategoryGoals, BackupCategoryGoal{g, backupMeta(g.BaseModel)})
+	}
+	for _, p := range profiles {
+		b.ImportProfiles = append(b.ImportProfiles, BackupImportProfile{p, backupMeta(p.BaseModel)})
+	}
+	for _, r := range reconciled {
+		b.Reconciliations = append(b.Reconciliations, BackupReconciliation{r, backupMeta(r.BaseModel), r.Budget})
+	}
+	for _, s := range scheduled {
+		b.ScheduledTransactions = append(b.ScheduledTransactions, BackupScheduledTransaction{s, backupMeta(s.BaseModel), s.Budget})
+	}
+	for _, tx := range transactions {
+		b.Transactions = append(b.Transactions, BackupTransaction{tx, backupMeta(tx.BaseModel), tx.Budget, tx.PairKey})
+	}
+	for _, id := range importIDs {
+		b.TransactionImportIDs = append(b.TransactionImportIDs, BackupTransactionImportID{id, backupMeta(id.BaseModel), id.TransactionID})
+	}
+
+	return b, nil
+}
+
+// Restore loads the records of the backup into the database inside
+// one transaction keeping their IDs. If the database already contains
+// data, the restore is refused with ErrDatabaseNotEmpty unless
+// overwrite is set: in that case all existing data is deleted. Budget
+// members are assigned to the existing user having their username,
+// members without such user are not restored.
+//
+//revive:disable-next-line:flag-parameter // explicit consent to delete data
+func (c *Client) Restore(b Backup, overwrite bool) (err error) {
+	if b.Version < 1 || b.Version > BackupVersion {
+		return fmt.Errorf("unsupported backup version %d (expected %d)", b.Version, BackupVersion)
+	}
+
+	var (
+		budgets      = make([]Budget, 0, len(b.Budgets))
+		accounts     = make([]Account, 0, len(b.Accounts))
+		groups       = make([]AccountGroup, 0, len(b.AccountGroups))
+		goals        = make([]CategoryGoal, 0, len(b.CategoryGoals))
+		profiles     = make([]ImportProfile, 0, len(b.ImportProfiles))
+		reconciled   = make([]Reconciliation, 0, len(b.Reconciliations))
+		scheduled    = make([]ScheduledTransaction, 0, len(b.ScheduledTransactions))
+		transactions = make([]Transaction, 0, len(b.Transactions))
+		importIDs    = make([]TransactionImportID, 0, len(b.TransactionImportIDs))
+	)
+
+	for _, bu := range b.Budgets {
+		bu.Budget.BaseModel = bu.baseModel(bu.ID)
+		budgets = append(budgets, bu.Budget)
+	}
+	for _, a := range b.Accounts {
+		a.Account.BaseModel = a.baseModel(a.ID)
+		a.Account.Budget = backupBudget(a.Budget)
+		accounts = append(accounts, a.Account)
+	}
+	for _, g := range b.AccountGroups {
+		g.AccountGroup.BaseModel = g.baseModel(g.ID)
+		g.AccountGroup.Budget = backupBudget(g.Budget)
+		groups = append(groups, g.AccountGroup)
+	}
+	for _, g := range b.CategoryGoals {
+		g.CategoryGoal.BaseModel = g.baseModel(g.ID)
+		goals = append(goals, g.CategoryGoal)
+	}
+	for _, p := range b.ImportProfiles {
+		p.ImportProfile.BaseModel = p.baseModel(p.ID)
+		profiles = append(profiles, p.ImportProfile)
+	}
+	for _, r := range b.Reconciliations {
+		r.Reconciliation.BaseModel = r.baseModel(r.ID)
+		r.Reconciliation.Budget = backupBudget(r.Budget)
+		reconciled = append(reconciled, r.Reconciliation)
+	}
+	for _, s := range b.ScheduledTransactions {
+		s.ScheduledTransaction.BaseModel = s.baseModel(s.ID)
+		s.ScheduledTransaction.Budget = backupBudget(s.Budget)
+		scheduled = append(scheduled, s.ScheduledTransaction)
+	}
+	for _, tx := range b.Transactions {
+		tx.Transaction.BaseModel = tx.baseModel(tx.ID)
+		tx.Transaction.Budget = backupBudget(tx.Budget)
+		tx.Transaction.PairKey = tx.PairKey
+		transactions = append(transactions, tx.Transaction)
+	}
+	for _, id := range b.TransactionImportIDs {
+		id.TransactionImportID.BaseModel = id.baseModel(id.ID)
+		id.TransactionImportID.TransactionID = id.TransactionID
+		importIDs = append(importIDs, id.TransactionImportID)
+	}
+
+	// Restoring thousands of records would flood the audit log
+	if err = c.withoutAudit().retryTx(func(db *gorm.DB) error {
+		empty, err := isEmpty(db)
+		if err != nil {
+			return fmt.Errorf("checking for existing data: %w", err)
+		}
+
+		if !empty && !overwrite {
+			return backoff.NewErrCannotRetry(ErrDatabaseNotEmpty)
+		}
+
+		for _, model := range []any{&Budget{}, &Account{}, &AccountGroup{}, &CategoryGoal{}, &ImportProfile{}, &Reconciliation{}, &ScheduledTransaction{}, &TransactionImportID{}, &TransactionSplit{}, &Transaction{}} {
+			if err = db.Unscoped().Where("1 = 1").Delete(model).Error; err != nil {
+				return fmt.Errorf("deleting %T: %w", model, err)
+			}
+		}
+
+		if b.Version >= backupVersionMembers {
+			if err = db.Unscoped().Where("1 = 1").Delete(&BudgetMember{}).Error; err != nil {
+				return fmt.Errorf("deleting budget members: %w", err)
+			}
+		}
+
+		members, err := restoreBudgetMembers(db, b.BudgetMembers)
+		if err != nil {
+			return fmt.Errorf("resolving budget members: %w", err)
+		}
+
+		// Hooks would assign new IDs to the records
+		db = db.Session(&gorm.Session{SkipHooks: true})
+
+		for _, list := range []any{budgets, members, accounts, groups, goals, profiles, reconciled, scheduled, transactions, importIDs} {
+			if err = db.CreateInBatches(list, backupBatchSize).Error; err != nil {
+				return fmt.Errorf("restoring %T: %w", list, err)
+			}
+		}
+
+		// Members of older backups are kept, those of budgets no longer
+		// existing must go
+		if err = db.Unscoped().
+			Where("budget NOT IN (?)", db.Unscoped().Model(&Budget{}).Select("id")).
+			Delete(&BudgetMember{}).Error; err != nil {
+			return fmt.Errorf("deleting members of removed budgets: %w", err)
+		}
+
+		// Backups should always contain them but the software relies on
+		// the default budget and its accounts to exist
+		b := defaultBudget
+		if err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&b).Error; err != nil {
+			return fmt.Errorf("ensuring default budget: %w", err)
+		}
+
+		for i := range migrateCreateAccounts {
+			a := migrateCreateAccounts[i]
+			if err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&a).Error; err != nil {
+				return fmt.Errorf("ensuring default account %q: %w", a.Name, err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("restoring backup: %w", err)
+	}
+
+	return nil
+}
+
+// isEmpty checks whether the database contains any records besides
+// the default budget and its accounts
+func isEmpty(db *gorm.DB) (bool, error) {
+	defaultIDs := make([]uuid.UUID, 0, len(migrateCreateAccounts))
+	for _, a := range migrateCreateAccounts {
+		defaultIDs = append(defaultIDs, a.ID)
+	}
+
+	var n int64
+	if err := db.Model(&Account{}).Unscoped().Where("id NOT IN ?", defaultIDs).Count(&n).Error; err != nil || n > 0 {
+		return false, err
+	}
+
+	if err := db.Model(&Budget{}).Unscoped().Where("id <> ?", DefaultBudget).Count(&n).Error; err != nil || n > 0 {
+		return false, err
+	}
+
+	for _, model := range []any{&AccountGroup{}, &CategoryGoal{}, &ImportProfile{}, &Reconciliation{}, &ScheduledTransaction{}, &Transaction{}} {
+		if err := db.Model(model).Unscoped().Count(&n).Error; err != nil || n > 0 {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// restoreBudgetMembers returns the members of the backup assigned to
+// the users having their username and drops those without such user
+func restoreBudgetMembers(db *gorm.DB, bm []BackupBudgetMember) ([]BudgetMember, error) {
+	names := make([]string, 0, len(bm))
+	for _, m := range bm {
+		names = append(names, m.Username)
+	}
+
+	var users []User
+	if err := db.Where("username IN ?", names).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("fetching users: %w", err)
+	}
+
+	userIDs := make(map[string]uuid.UUID, len(users))
+	for _, u := range users {
+		userIDs[u.Username] = u.ID
+	}
+
+	members := make([]BudgetMember, 0, len(bm))
+	for _, m := range bm {
+		id, ok := userIDs[m.Username]
+		if !ok {
+			continue
+		}
+
+		m.BudgetMember.BaseModel = m.baseModel(m.ID)
+		m.BudgetMember.Budget = m.Budget
+		m.BudgetMember.User = id
+		members = append(members, m.BudgetMember)
+	}
+
+	return members, nil
+}
+
+// backupBudget moves records of version 1 backups not having a budget
+// into the DefaultBudget
+func backupBudget(id uuid.UUID) uuid.UUID {
+	if id == uuid.Nil {
+		return DefaultBudget
+	}
+
+	return id
+}
+
+func backupMeta(b BaseModel) BackupMeta {
+	return BackupMeta{CreatedAt: b.CreatedAt, UpdatedAt: b.UpdatedAt, DeletedAt: b.DeletedAt}
+}
+
+func (m BackupMeta) baseModel(id uuid.UUID) BaseModel {
+	return BaseModel{ID: id, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt, DeletedAt: m.DeletedAt}
+}
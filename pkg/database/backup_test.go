@@ -0,0 +1,157 @@
+package database
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRestore(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "source.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := dbc.CreateAccount("Savings", AccountTypeBudget)
+	require.NoError(t, err)
+	require.NoError(t, dbc.UpdateAccountHidden(savings.ID, true))
+	groceries, err := dbc.CreateAccount("Groceries", AccountTypeCategory)
+	require.NoError(t, err)
+
+	group, err := dbc.CreateAccountGroup("Banks")
+	require.NoError(t, err)
+	require.NoError(t, dbc.UpdateAccountGroup(checking.ID, uuid.NullUUID{UUID: group.ID, Valid: true}))
+
+	tx, err := dbc.CreateTransaction(Transaction{
+		Time:    time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Payee:   "Supermarket",
+		Amount:  -12000,
+		Account: uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Splits: []TransactionSplit{
+			{Amount: -10000, Category: uuid.NullUUID{UUID: groceries.ID, Valid: true}},
+			{Amount: -2000, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, dbc.TransferMoney(checking.ID, savings.ID, 5000, "savings"))
+
+	deleted, err := dbc.CreateTransaction(Transaction{
+		Time:     time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+		Payee:    "Mistake",
+		Amount:   -100,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: groceries.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, dbc.DeleteTransaction(deleted.ID))
+
+	alice, err := dbc.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	club, err := dbc.CreateBudget("Sports Club", alice.ID)
+	require.NoError(t, err)
+
+	b, err := dbc.Backup()
+	require.NoError(t, err)
+	assert.Equal(t, BackupVersion, b.Version)
+	assert.Len(t, b.Budgets, 2)
+	require.Len(t, b.BudgetMembers, 1)
+	assert.Equal(t, "alice", b.BudgetMembers[0].Username)
+	assert.Len(t, b.Accounts, 7) // 2 default accounts per budget + 3 created
+	assert.Len(t, b.Transactions, 4)
+
+	// Backup must survive the JSON round trip
+	raw, err := json.Marshal(b)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"pairKey":"`)
+	assert.Contains(t, string(raw), `"deletedAt":"`)
+
+	var restored Backup
+	require.NoError(t, json.Unmarshal(raw, &restored))
+
+	target, err := New("sqlite", filepath.Join(t.TempDir(), "target.db"))
+	require.NoError(t, err)
+	require.NoError(t, target.Restore(restored, false))
+
+	// Members are assigned to the users by their name, unknown users
+	// are skipped
+	bob, err := target.CreateUser("bob", "correct horse", false)
+	require.NoError(t, err)
+	targetAlice, err := target.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	tc, err := target.ForBudget(club.ID)
+	require.NoError(t, err)
+	require.NoError(t, tc.SetBudgetMember(bob.ID, BudgetRoleOwner))
+
+	// Restoring again must be refused unless forced
+	assert.ErrorIs(t, target.Restore(restored, false), ErrDatabaseNotEmpty)
+	require.NoError(t, target.Restore(restored, true))
+
+	members, err := tc.ListBudgetMembers()
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, targetAlice.ID, members[0].User)
+	assert.Equal(t, BudgetRoleOwner, members[0].Role)
+
+	bals, err := target.ListAccountBalances(true)
+	require.NoError(t, err)
+
+	for id, bal := range map[uuid.UUID]Money{
+		checking.ID:      -17000,
+		savings.ID:       5000,
+		groceries.ID:     -10000,
+		UnallocatedMoney: -2000,
+	} {
+		testCheckAcctBal(t, bals, id, bal)
+	}
+
+	acc, err := target.GetAccount(savings.ID)
+	require.NoError(t, err)
+	assert.True(t, acc.Hidden)
+	assert.Equal(t, savings.CreatedAt.Unix(), acc.CreatedAt.Unix())
+
+	acc, err = target.GetAccount(checking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.NullUUID{UUID: group.ID, Valid: true}, acc.Group)
+
+	rtx, err := target.GetTransactionByID(tx.ID)
+	require.NoError(t, err)
+	assert.Len(t, rtx.Splits, 2)
+
+	_, err = target.GetTransactionByID(deleted.ID)
+	assert.Error(t, err)
+
+	// Paired transactions must still be coupled
+	txs, err := target.ListTransactionsByAccount(savings.ID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.NoError(t, target.DeleteTransaction(txs[0].ID))
+
+	bal, err := target.GetAccountBalance(checking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(-12000), bal.Balance)
+
+	cc, err := target.ForBudget(club.ID)
+	require.NoError(t, err)
+	_, err = cc.GetAccount(club.UnallocatedMoney)
+	require.NoError(t, err)
+
+	// Version 1 backups predate budgets and are restored into the
+	// default budget
+	v1 := Backup{Version: 1, Accounts: []BackupAccount{{Account: Account{BaseModel: BaseModel{ID: checking.ID}, Name: "Checking", Type: AccountTypeBudget}}}}
+	require.NoError(t, target.Restore(v1, true))
+	_, err = target.GetAccount(checking.ID)
+	require.NoError(t, err)
+
+	budgets, err := target.ListBudgetsForUser(targetAlice)
+	require.NoError(t, err)
+	assert.Empty(t, budgets)
+
+	restored.Version = BackupVersion + 1
+	assert.Error(t, target.Restore(restored, true))
+}
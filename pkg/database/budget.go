@@ -0,0 +1,138 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	// BudgetSummary contains the envelope numbers for all categories
+	// in a given month
+	BudgetSummary struct {
+		Month            time.Time               `json:"month"`
+		Categories       []BudgetCategorySummary `json:"categories"`
+		UnallocatedMoney Money                   `json:"unallocatedMoney"`
+	}
+
+	// BudgetCategorySummary wraps a category Account and adds the
+	// envelope numbers for the month
+	BudgetCategorySummary struct {
+		Account
+		// Allocated is the money moved into (or out of) the category
+		// through transfers between categories during the month
+		Allocated Money `json:"allocated"`
+		// Activity is the money spent or earned on budget accounts
+		// using the category during the month
+		Activity Money `json:"activity"`
+		// CarryOver is the balance of the category at the start of the
+		// month
+		CarryOver Money `json:"carryOver"`
+		// Available is the balance of the category at the end of the
+		// month (CarryOver + Allocated + Activity)
+		Available Money `json:"available"`
+		// Goal contains the goal of the category and its status in the
+		// month if a goal is set
+		Goal *GoalStatus `json:"goal,omitempty"`
+	}
+)
+
+// GetBudgetSummary calculates the BudgetSummary for the month
+// containing the given time
+//
+//revive:disable-next-line:flag-parameter // not a behavior switch but a filter
+func (c *Client) GetBudgetSummary(month time.Time, showHidden bool) (s BudgetSummary, err error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	end := start.AddDate(0, 1, 0)
+
+	s = BudgetSummary{Month: start}
+
+	cats, err := c.ListAccountsByType(AccountTypeCategory, showHidden)
+	if err != nil {
+		return s, fmt.Errorf("listing categories: %w", err)
+	}
+
+	var sums, splitSums []struct {
+		Category  uuid.UUID
+		CarryOver int64
+		Allocated int64
+		Activity  int64
+	}
+
+	if err = c.retryRead(func(db *gorm.DB) error {
+		if err := db.
+			Model(&Transaction{}).
+			Select(
+				"category, "+
+					"CAST(SUM(CASE WHEN time < @start THEN amount_cents ELSE 0 END) AS BIGINT) AS carry_over, "+
+					"CAST(SUM(CASE WHEN time >= @start AND account IS NULL THEN amount_cents ELSE 0 END) AS BIGINT) AS allocated, "+
+					"CAST(SUM(CASE WHEN time >= @start AND account IS NOT NULL THEN amount_cents ELSE 0 END) AS BIGINT) AS activity",
+				map[string]any{"start": start},
+			).
+			Scopes(c.inBudget).
+			Where("category IS NOT NULL").
+			Where("time < ?", end).
+			Group("category").
+			Scan(&sums).
+			Error; err != nil {
+			return fmt.Errorf("summing transactions: %w", err)
+		}
+
+		// Splits always belong to a budget account transaction and
+		// therefore only count as activity
+		if err := db.
+			Model(&TransactionSplit{}).
+			Select(
+				"transaction_splits.category, "+
+					"CAST(SUM(CASE WHEN transactions.time < @start THEN transaction_splits.amount_cents ELSE 0 END) AS BIGINT) AS carry_over, "+
+					"CAST(SUM(CASE WHEN transactions.time >= @start THEN transaction_splits.amount_cents ELSE 0 END) AS BIGINT) AS activity",
+				map[string]any{"start": start},
+			).
+			Joins("JOIN transactions ON transactions.id = transaction_splits.transaction_id AND transactions.deleted_at IS NULL").
+			Where("transactions.budget = ?", c.budget.ID).
+			Where("transactions.time < ?", end).
+			Group("transaction_splits.category").
+			Scan(&splitSums).
+			Error; err != nil {
+			return fmt.Errorf("summing splits: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return s, fmt.Errorf("getting category sums: %w", err)
+	}
+
+	byCategory := make(map[uuid.UUID]BudgetCategorySummary, len(sums))
+	for _, sum := range append(sums, splitSums...) {
+		cs := byCategory[sum.Category]
+		cs.Allocated += Money(sum.Allocated)
+		cs.Activity += Money(sum.Activity)
+		cs.CarryOver += Money(sum.CarryOver)
+		cs.Available += Money(sum.CarryOver + sum.Allocated + sum.Activity)
+		byCategory[sum.Category] = cs
+	}
+
+	goals, err := c.listCategoryGoals()
+	if err != nil {
+		return s, fmt.Errorf("getting goals: %w", err)
+	}
+
+	s.UnallocatedMoney = byCategory[c.budget.UnallocatedMoney].Available
+
+	for _, cat := range cats {
+		if cat.ID == c.budget.UnallocatedMoney {
+			continue
+		}
+
+		cs := byCategory[cat.ID]
+		cs.Account = cat
+		if g, ok := goals[cat.ID]; ok {
+			cs.Goal = g.status(start, cs)
+		}
+		s.Categories = append(s.Categories, cs)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBudgetSummary(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	var (
+		lastMonth = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+		thisMonth = time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC)
+		nextMonth = time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	)
+
+	tb, err := dbc.CreateAccount("test", AccountTypeBudget)
+	require.NoError(t, err)
+	tc, err := dbc.CreateAccount("test", AccountTypeCategory)
+	require.NoError(t, err)
+
+	for _, tx := range []Transaction{
+		// Last month: earn 1000, allocate 300, spend 100
+		{Time: lastMonth, Amount: 1000, Account: uuid.NullUUID{UUID: tb.ID, Valid: true}, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		{Time: lastMonth, Amount: -300, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		{Time: lastMonth, Amount: 300, Category: uuid.NullUUID{UUID: tc.ID, Valid: true}},
+		{Time: lastMonth, Amount: -100, Account: uuid.NullUUID{UUID: tb.ID, Valid: true}, Category: uuid.NullUUID{UUID: tc.ID, Valid: true}},
+		// This month: allocate 50, spend 75 + 25 (split)
+		{Time: thisMonth, Amount: -50, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		{Time: thisMonth, Amount: 50, Category: uuid.NullUUID{UUID: tc.ID, Valid: true}},
+		{Time: thisMonth, Amount: -75, Account: uuid.NullUUID{UUID: tb.ID, Valid: true}, Category: uuid.NullUUID{UUID: tc.ID, Valid: true}},
+		{Time: thisMonth, Amount: -40, Account: uuid.NullUUID{UUID: tb.ID, Valid: true}, Splits: []TransactionSplit{
+			{Amount: -25, Category: uuid.NullUUID{UUID: tc.ID, Valid: true}},
+			{Amount: -15, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		}},
+		// Next month: must not show up
+		{Time: nextMonth, Amount: -20, Account: uuid.NullUUID{UUID: tb.ID, Valid: true}, Category: uuid.NullUUID{UUID: tc.ID, Valid: true}},
+	} {
+		_, err = dbc.CreateTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	s, err := dbc.GetBudgetSummary(thisMonth, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), s.Month)
+	assert.Equal(t, Money(635), s.UnallocatedMoney)
+	require.Len(t, s.Categories, 1)
+	assert.Equal(t, tc.ID, s.Categories[0].ID)
+	assert.Equal(t, Money(200), s.Categories[0].CarryOver)
+	assert.Equal(t, Money(50), s.Categories[0].Allocated)
+	assert.Equal(t, Money(-100), s.Categories[0].Activity)
+	assert.Equal(t, Money(150), s.Categories[0].Available)
+}
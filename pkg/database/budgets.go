@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Budget returns the Budget the client is scoped to
+func (c *Client) Budget() Budget { return c.budget }
+
+// CreateBudget creates and returns a new budget together with its
+// Unallocated Money and Starting Balance categories. If an owner is
+// given, that user becomes the first member of the budget.
+func (c *Client) CreateBudget(name string, owner uuid.UUID) (b Budget, err error) {
+	b = Budget{
+		BaseModel:        BaseModel{ID: uuid.Must(uuid.NewRandom())},
+		Name:             name,
+		UnallocatedMoney: uuid.Must(uuid.NewRandom()),
+		StartingBalance:  uuid.Must(uuid.NewRandom()),
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&b).Error; err != nil {
+			return fmt.Errorf("saving budget: %w", err)
+		}
+
+		for _, a := range migrateCreateAccounts {
+			a.Budget = b.ID
+			if a.ID == UnallocatedMoney {
+				a.ID = b.UnallocatedMoney
+			} else {
+				a.ID = b.StartingBalance
+			}
+
+			if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&a).Error; err != nil {
+				return fmt.Errorf("creating category %q: %w", a.Name, err)
+			}
+		}
+
+		if owner == uuid.Nil {
+			return nil
+		}
+
+		bc := &Client{db: c.db, budget: b}
+		return bc.setBudgetMember(db, owner, BudgetRoleOwner)
+	}); err != nil {
+		return b, fmt.Errorf("creating budget: %w", err)
+	}
+
+	return b, nil
+}
+
+// ForBudget returns a client scoped to the Budget with the given ID
+func (c *Client) ForBudget(id uuid.UUID) (*Client, error) {
+	b, err := c.GetBudget(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{db: withContextValue(c.db, auditKeyBudget, b.ID), budget: b}, nil
+}
+
+// GetBudget retrieves a Budget using its ID
+func (c *Client) GetBudget(id uuid.UUID) (b Budget, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.First(&b, "id = ?", id).Error
+	}); err != nil {
+		return b, fmt.Errorf("fetching budget: %w", err)
+	}
+
+	return b, nil
+}
+
+// ListBudgets returns a list of all budgets
+func (c *Client) ListBudgets() (b []Budget, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Order("name").Find(&b).Error
+	}); err != nil {
+		return b, fmt.Errorf("listing budgets: %w", err)
+	}
+
+	return b, nil
+}
+
+// UpdateBudgetName sets a new name for the given budget ID
+func (c *Client) UpdateBudgetName(id uuid.UUID, name string) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.
+			Model(&Budget{}).
+			Where("id = ?", id).
+			Update("name", name).
+			Error
+	}); err != nil {
+		return fmt.Errorf("updating budget: %w", err)
+	}
+
+	return nil
+}
+
+// inBudget is a gorm scope limiting queries to the budget of the
+// client
+func (c *Client) inBudget(db *gorm.DB) *gorm.DB {
+	return db.Where("budget = ?", c.budget.ID)
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBudgetIsolation(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBudget, dbc.Budget().ID)
+	assert.Equal(t, UnallocatedMoney, dbc.Budget().UnallocatedMoney)
+
+	club, err := dbc.CreateBudget("Sports Club", uuid.Nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, UnallocatedMoney, club.UnallocatedMoney)
+
+	budgets, err := dbc.ListBudgets()
+	require.NoError(t, err)
+	assert.Len(t, budgets, 2)
+
+	cc, err := dbc.ForBudget(club.ID)
+	require.NoError(t, err)
+
+	// Each budget has its own default categories
+	accs, err := cc.ListAccounts(true)
+	require.NoError(t, err)
+	require.Len(t, accs, 2)
+	um, err := cc.GetAccount(club.UnallocatedMoney)
+	require.NoError(t, err)
+	assert.Equal(t, "Unallocated Money", um.Name)
+	sb, err := cc.GetAccount(club.StartingBalance)
+	require.NoError(t, err)
+	assert.True(t, sb.Hidden)
+
+	household, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	treasury, err := cc.CreateAccount("Treasury", AccountTypeBudget)
+	require.NoError(t, err)
+
+	// Accounts of other budgets are not visible
+	_, err = cc.GetAccount(household.ID)
+	assert.Error(t, err)
+	_, err = dbc.GetAccount(treasury.ID)
+	assert.Error(t, err)
+	_, err = cc.GetAccount(UnallocatedMoney)
+	assert.Error(t, err)
+
+	// Transactions can not reference accounts of other budgets
+	_, err = cc.CreateTransaction(Transaction{
+		Time:     time.Now(),
+		Amount:   1000,
+		Account:  uuid.NullUUID{UUID: treasury.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	assert.Error(t, err)
+
+	tx, err := cc.CreateTransaction(Transaction{
+		Time:     time.Now(),
+		Amount:   1000,
+		Account:  uuid.NullUUID{UUID: treasury.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: club.UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, club.ID, tx.Budget)
+
+	txs, err := dbc.ListTransactions(time.Time{}, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, txs)
+	txs, err = cc.ListTransactions(time.Time{}, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, txs, 1)
+
+	_, err = dbc.GetTransactionByID(tx.ID)
+	assert.Error(t, err)
+
+	// Transactions of accounts in other budgets are not readable
+	cash, err := cc.CreateAccount("Cash", AccountTypeBudget)
+	require.NoError(t, err)
+	require.NoError(t, cc.TransferMoney(treasury.ID, cash.ID, 100, ""))
+	_, err = dbc.ListTransactionsByAccount(treasury.ID, time.Time{}, time.Now())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = dbc.ListTransactionsByAccount(club.UnallocatedMoney, time.Time{}, time.Now())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = dbc.ListPairedTransactions(treasury.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Transfers can not take money from categories of other budgets
+	savings, err := dbc.CreateAccount("Savings", AccountTypeBudget)
+	require.NoError(t, err)
+	assert.ErrorIs(t, cc.TransferMoneyWithCategory(treasury.ID, cash.ID, 500, "", UnallocatedMoney), gorm.ErrRecordNotFound)
+	assert.Error(t, dbc.TransferMoneyWithCategory(household.ID, savings.ID, 500, "", household.ID))
+
+	bal, err := dbc.GetAccountBalance(UnallocatedMoney)
+	require.NoError(t, err)
+	assert.Equal(t, Money(0), bal.Balance)
+
+	txs, err = dbc.ListTransactionsByAccount(household.ID, time.Time{}, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, txs)
+
+	txs, err = cc.ListTransactionsByAccount(treasury.ID, time.Time{}, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Len(t, txs, 2)
+
+	txs, err = cc.ListPairedTransactions(treasury.ID)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, cash.ID, txs[0].Account.UUID)
+
+	s, err := cc.GetBudgetSummary(time.Now(), false)
+	require.NoError(t, err)
+	assert.Equal(t, Money(1000), s.UnallocatedMoney)
+
+	s, err = dbc.GetBudgetSummary(time.Now(), false)
+	require.NoError(t, err)
+	assert.Equal(t, Money(0), s.UnallocatedMoney)
+
+	require.NoError(t, dbc.UpdateBudgetName(club.ID, "Club"))
+	club, err = dbc.GetBudget(club.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Club", club.Name)
+}
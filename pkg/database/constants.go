@@ -11,24 +11,42 @@ import (
 const constAcctIDNamespace = "00000000-0000-0000-0000-%012s"
 
 var (
-	// UnallocatedMoney is a category UUID which is automatically created
-	// during database migration phase and therefore always available
+	// UnallocatedMoney is the category UUID of the DefaultBudget which
+	// is automatically created during database migration phase and
+	// therefore always available. Other budgets use the category given
+	// in Budget.UnallocatedMoney.
 	UnallocatedMoney = makeConstAcctID(1)
-	// StartingBalance is a category UUID which is automatically created
-	// and hidden during database migration and used in frontend as constant
+	// StartingBalance is the category UUID of the DefaultBudget which is
+	// automatically created and hidden during database migration and
+	// used in frontend as constant. Other budgets use the category given
+	// in Budget.StartingBalance.
 	StartingBalance = makeConstAcctID(2)
 
 	invalidAcc = makeConstAcctID(math.MaxUint32)
 
+	// DefaultBudget is the budget UUID created during database
+	// migration owning the UnallocatedMoney and StartingBalance
+	// categories and all data created before budgets were introduced
+	DefaultBudget = uuid.MustParse("00000000-0000-0000-0001-000000000001")
+
+	defaultBudget = Budget{
+		BaseModel:        BaseModel{ID: DefaultBudget},
+		Name:             "Budget",
+		UnallocatedMoney: UnallocatedMoney,
+		StartingBalance:  StartingBalance,
+	}
+
 	migrateCreateAccounts = []Account{
 		{
 			BaseModel: BaseModel{ID: UnallocatedMoney},
+			Budget:    DefaultBudget,
 			Hidden:    false,
 			Name:      "Unallocated Money",
 			Type:      AccountTypeCategory,
 		},
 		{
 			BaseModel: BaseModel{ID: StartingBalance},
+			Budget:    DefaultBudget,
 			Hidden:    true,
 			Name:      "Starting Balance",
 			Type:      AccountTypeCategory,
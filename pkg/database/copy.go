@@ -0,0 +1,144 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CopyTo streams all records of the database including deleted ones
+// into the target database keeping their IDs. The target must not
+// contain any data besides the default accounts. After copying the
+// row counts and account balances of both databases are compared.
+// Sessions are not copied: users need to log in again.
+func (c *Client) CopyTo(target *Client) (err error) {
+	if err = target.withoutAudit().retryTx(func(dst *gorm.DB) error {
+		empty, err := isEmpty(dst)
+		if err != nil {
+			return fmt.Errorf("checking for existing data: %w", err)
+		}
+
+		if !empty {
+			return backoff.NewErrCannotRetry(ErrDatabaseNotEmpty)
+		}
+
+		for _, fn := range []func(src, dst *gorm.DB) error{
+			copyTable[Budget],
+			copyTable[Account],
+			copyTable[AccountGroup],
+			copyTable[CategoryGoal],
+			copyTable[ImportProfile],
+			copyTable[Reconciliation],
+			copyTable[ScheduledTransaction],
+			copyTable[Transaction],
+			copyTable[TransactionSplit],
+			copyTable[TransactionImportID],
+			copyTable[User],
+			copyTable[APIToken],
+			copyTable[BudgetMember],
+			copyTable[AuditEntry],
+			copyTable[Operation],
+		} {
+			if err = fn(c.db, dst); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("copying records: %w", err)
+	}
+
+	if err = c.verifyCopy(target); err != nil {
+		return fmt.Errorf("verifying copy: %w", err)
+	}
+
+	return nil
+}
+
+// verifyCopy compares the row counts of all tables and the balances
+// of all accounts of all budgets with the target database
+func (c *Client) verifyCopy(target *Client) error {
+	for _, model := range []any{&Budget{}, &Account{}, &AccountGroup{}, &CategoryGoal{}, &ImportProfile{}, &Reconciliation{}, &ScheduledTransaction{}, &Transaction{}, &TransactionSplit{}, &TransactionImportID{}, &User{}, &APIToken{}, &BudgetMember{}, &AuditEntry{}, &Operation{}} {
+		var srcCount, dstCount int64
+
+		if err := c.db.Model(model).Unscoped().Count(&srcCount).Error; err != nil {
+			return fmt.Errorf("counting source %T: %w", model, err)
+		}
+
+		if err := target.db.Model(model).Unscoped().Count(&dstCount).Error; err != nil {
+			return fmt.Errorf("counting target %T: %w", model, err)
+		}
+
+		if srcCount != dstCount {
+			return fmt.Errorf("row count of %T differs: %d != %d", model, srcCount, dstCount)
+		}
+	}
+
+	budgets, err := c.ListBudgets()
+	if err != nil {
+		return fmt.Errorf("listing budgets: %w", err)
+	}
+
+	for _, b := range budgets {
+		if err = verifyCopyBalances(&Client{db: c.db, budget: b}, &Client{db: target.db, budget: b}); err != nil {
+			return fmt.Errorf("budget %s: %w", b.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyCopyBalances compares the balances of all accounts of the
+// budget the clients are scoped to
+func verifyCopyBalances(src, dst *Client) error {
+	srcBals, err := src.ListAccountBalances(true)
+	if err != nil {
+		return fmt.Errorf("listing source balances: %w", err)
+	}
+
+	dstBals, err := dst.ListAccountBalances(true)
+	if err != nil {
+		return fmt.Errorf("listing target balances: %w", err)
+	}
+
+	dstBalByID := make(map[uuid.UUID]Money, len(dstBals))
+	for _, b := range dstBals {
+		dstBalByID[b.ID] = b.Balance
+	}
+
+	for _, b := range srcBals {
+		if dstBal, ok := dstBalByID[b.ID]; !ok || dstBal != b.Balance {
+			return fmt.Errorf("balance of account %s differs: %s != %s", b.ID, b.Balance, dstBal)
+		}
+	}
+
+	return nil
+}
+
+// copyTable streams all records of one table in batches from src into
+// dst. Existing records (the default accounts) are overwritten.
+func copyTable[T any](src, dst *gorm.DB) error {
+	var batch []T
+
+	// Hooks would assign new IDs to the records
+	dst = dst.Session(&gorm.Session{SkipHooks: true})
+
+	if err := src.
+		Unscoped().
+		FindInBatches(&batch, backupBatchSize, func(*gorm.DB, int) error {
+			return dst.
+				Clauses(clause.OnConflict{UpdateAll: true}).
+				Omit(clause.Associations).
+				Create(&batch).
+				Error
+		}).
+		Error; err != nil {
+		return fmt.Errorf("copying %T: %w", batch, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyTo(t *testing.T) {
+	src, err := New("sqlite", filepath.Join(t.TempDir(), "source.db"))
+	require.NoError(t, err)
+
+	checking, err := src.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := src.CreateAccount("Savings", AccountTypeBudget)
+	require.NoError(t, err)
+	require.NoError(t, src.UpdateAccountHidden(savings.ID, true))
+
+	_, err = src.CreateTransaction(Transaction{
+		Time:     time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Payee:    "Employer",
+		Amount:   150000,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, src.TransferMoney(checking.ID, savings.ID, 5000, "savings"))
+
+	deleted, err := src.CreateTransaction(Transaction{
+		Time:     time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+		Payee:    "Mistake",
+		Amount:   -100,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, src.DeleteTransaction(deleted.ID))
+
+	dst, err := New("sqlite", filepath.Join(t.TempDir(), "target.db"))
+	require.NoError(t, err)
+	require.NoError(t, src.CopyTo(dst))
+
+	bals, err := dst.ListAccountBalances(true)
+	require.NoError(t, err)
+	testCheckAcctBal(t, bals, checking.ID, 145000)
+	testCheckAcctBal(t, bals, savings.ID, 5000)
+
+	var n int64
+	require.NoError(t, dst.db.Model(&Transaction{}).Unscoped().Where("id = ?", deleted.ID).Count(&n).Error)
+	assert.Equal(t, int64(1), n)
+
+	txs, err := dst.ListTransactionsByAccount(savings.ID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.True(t, txs[0].PairKey.Valid)
+
+	// Copying into a database with data must be refused
+	assert.ErrorIs(t, src.CopyTo(dst), ErrDatabaseNotEmpty)
+}
@@ -6,7 +6,6 @@ package database
 import (
 	"errors"
 	"fmt"
-	"math"
 	"time"
 
 	"github.com/Luzifer/go_helpers/backoff"
@@ -15,20 +14,55 @@ import (
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 const dbMaxRetries = 5
 
+var (
+	// ErrAccountNotEmpty signals an account cannot be deleted without
+	// a replacement as it still holds money
+	ErrAccountNotEmpty = errors.New("account balance is not zero")
+	// ErrAccountProtected signals the account is one of the categories
+	// every budget needs and cannot be deleted
+	ErrAccountProtected = errors.New("account is required by the budget")
+	// ErrAccountScheduled signals an account cannot be deleted without
+	// a replacement as scheduled transactions still use it
+	ErrAccountScheduled = errors.New("account is used by scheduled transactions")
+	// ErrInvalidReplacement signals the account to move the
+	// transactions into is the account itself or of another type
+	ErrInvalidReplacement = errors.New("replacement must be another account of the same type")
+)
+
 type (
-	// Client is the database client
+	// Client is the database client. It is scoped to one Budget, use
+	// ForBudget to access another one.
 	Client struct {
-		db *gorm.DB
+		db     *gorm.DB
+		budget Budget
 	}
 )
 
-// New creates a new database client for the given DSN
+// New creates a new database client for the given DSN, applies all
+// pending migrations and scopes the client to the DefaultBudget
 func New(dbtype, dsn string) (*Client, error) {
+	c, err := Open(dbtype, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = c.MigrateUp(); err != nil {
+		return nil, fmt.Errorf("migrating database schema: %w", err)
+	}
+
+	return c.ForBudget(DefaultBudget)
+}
+
+// Open creates a new database client for the given DSN without
+// touching the database schema. The client is scoped to the
+// DefaultBudget.
+func Open(dbtype, dsn string) (*Client, error) {
 	var conn gorm.Dialector
 	switch dbtype {
 	case "cockroach", "crdb", "postgres", "postgresql":
@@ -54,30 +88,22 @@ func New(dbtype, dsn string) (*Client, error) {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
 
-	if err = db.AutoMigrate(
-		&Account{},
-		&Transaction{},
-	); err != nil {
-		return nil, fmt.Errorf("migrating database schema: %w", err)
-	}
-
-	for i := range migrateCreateAccounts {
-		a := migrateCreateAccounts[i]
-		if err = db.Save(&a).Error; err != nil {
-			return nil, fmt.Errorf("ensuring default account %q: %w", a.Name, err)
-		}
+	if err = registerAuditCallbacks(db); err != nil {
+		return nil, fmt.Errorf("registering audit callbacks: %w", err)
 	}
 
 	return &Client{
-		db: db,
+		db:     db,
+		budget: defaultBudget,
 	}, nil
 }
 
 // CreateAccount creates and returns a new account of the given type
 func (c *Client) CreateAccount(name string, accType AccountType) (a Account, err error) {
 	a = Account{
-		Name: name,
-		Type: accType,
+		Budget: c.budget.ID,
+		Name:   name,
+		Type:   accType,
 	}
 
 	if !accType.IsValid() {
@@ -99,6 +125,8 @@ func (c *Client) CreateTransaction(tx Transaction) (ntx Transaction, err error)
 		return tx, fmt.Errorf("validating transaction: %w", err)
 	}
 
+	tx.Budget = c.budget.ID
+
 	if err = c.retryTx(func(db *gorm.DB) error {
 		return db.Save(&tx).Error
 	}); err != nil {
@@ -108,6 +136,49 @@ func (c *Client) CreateTransaction(tx Transaction) (ntx Transaction, err error)
 	return tx, nil
 }
 
+// DeleteAccount deletes an account. Accounts still holding money or
+// being used by scheduled transactions can only be deleted if a
+// replacement is given: all transactions, splits, scheduled
+// transactions and reconciliations are then moved into the
+// replacement.
+func (c *Client) DeleteAccount(id uuid.UUID, replacement uuid.NullUUID) (err error) {
+	acc, err := c.GetAccount(id)
+	if err != nil {
+		return err
+	}
+
+	if acc.ID == c.budget.UnallocatedMoney || acc.ID == c.budget.StartingBalance {
+		return ErrAccountProtected
+	}
+
+	var into Account
+	if replacement.Valid {
+		if into, err = c.GetAccount(replacement.UUID); err != nil {
+			return fmt.Errorf("getting replacement: %w", err)
+		}
+
+		if into.ID == acc.ID || into.Type != acc.Type {
+			return ErrInvalidReplacement
+		}
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		if replacement.Valid {
+			if err := c.moveAccountRecords(db, acc, into); err != nil {
+				return err
+			}
+		} else if err := c.ensureAccountUnused(db, acc); err != nil {
+			return err
+		}
+
+		return db.Delete(&Account{}, "id = ?", acc.ID).Error
+	}); err != nil {
+		return fmt.Errorf("deleting account: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteTransaction deletes a transaction
 func (c *Client) DeleteTransaction(id uuid.UUID) (err error) {
 	if err = c.retryTx(func(db *gorm.DB) error {
@@ -134,7 +205,7 @@ func (c *Client) DeleteTransaction(id uuid.UUID) (err error) {
 // GetAccount retrieves an Account using its ID
 func (c *Client) GetAccount(id uuid.UUID) (a Account, err error) {
 	if err = c.retryRead(func(db *gorm.DB) error {
-		return db.First(&a, "id = ?", id).Error
+		return db.Scopes(c.inBudget).First(&a, "id = ?", id).Error
 	}); err != nil {
 		return a, fmt.Errorf("fetching account: %w", err)
 	}
@@ -142,10 +213,24 @@ func (c *Client) GetAccount(id uuid.UUID) (a Account, err error) {
 	return a, nil
 }
 
+// GetAccountBalance retrieves an Account using its ID together with
+// its balance
+func (c *Client) GetAccountBalance(id uuid.UUID) (ab AccountBalance, err error) {
+	if ab.Account, err = c.GetAccount(id); err != nil {
+		return ab, err
+	}
+
+	if ab.Balance, err = c.accountBalance(ab.Account); err != nil {
+		return ab, fmt.Errorf("getting account balance: %w", err)
+	}
+
+	return ab, nil
+}
+
 // GetTransactionByID returns a single transaction by its ID
 func (c *Client) GetTransactionByID(id uuid.UUID) (tx Transaction, err error) {
 	if err = c.retryRead(func(db *gorm.DB) error {
-		return db.First(&tx, "id = ?", id).Error
+		return db.Scopes(c.inBudget).Preload("Splits").First(&tx, "id = ?", id).Error
 	}); err != nil {
 		return tx, fmt.Errorf("getting transaction: %w", err)
 	}
@@ -154,47 +239,30 @@ func (c *Client) GetTransactionByID(id uuid.UUID) (tx Transaction, err error) {
 }
 
 // ListAccountBalances returns a list of accounts with their
-// corresponding balance
+// corresponding balance and the status of their goal in the current
+// month if one is set
 func (c *Client) ListAccountBalances(showHidden bool) (a []AccountBalance, err error) {
 	accs, err := c.ListAccounts(showHidden)
 	if err != nil {
 		return nil, fmt.Errorf("listing accounts: %w", err)
 	}
 
-	for _, acc := range accs {
-		if err = c.retryRead(func(db *gorm.DB) error {
-			q := db.
-				Model(&Transaction{})
-
-			if acc.Type == AccountTypeCategory {
-				q = q.Where("category = ?", acc.ID)
-			} else {
-				q = q.Where("account = ?", acc.ID)
-			}
-
-			ab := AccountBalance{
-				Account: acc,
-				Balance: 0,
-			}
-
-			var v *float64
-			if err = q.
-				Select("sum(amount)").
-				Scan(&v).
-				Error; err != nil {
-				return fmt.Errorf("getting sum: %w", err)
-			}
-
-			if v != nil {
-				// Fix database doing e-15 stuff by rounding to full cents
-				ab.Balance = math.Round(*v*100) / 100 //revive:disable-line:add-constant // clear from code
-			}
+	goals, err := c.listGoalStatus(time.Now(), showHidden)
+	if err != nil {
+		return nil, fmt.Errorf("getting goals: %w", err)
+	}
 
-			a = append(a, ab)
-			return nil
-		}); err != nil {
+	for _, acc := range accs {
+		ab := AccountBalance{Account: acc}
+		if ab.Balance, err = c.accountBalance(acc); err != nil {
 			return nil, fmt.Errorf("getting account balance for %s: %w", acc.ID, err)
 		}
+
+		if acc.Type == AccountTypeCategory {
+			ab.Goal = goals[acc.ID]
+		}
+
+		a = append(a, ab)
 	}
 
 	return a, nil
@@ -205,13 +273,13 @@ func (c *Client) ListAccountBalances(showHidden bool) (a []AccountBalance, err e
 //revive:disable-next-line:flag-parameter // not a behavior switch but a filter
 func (c *Client) ListAccounts(showHidden bool) (a []Account, err error) {
 	if err = c.retryRead(func(db *gorm.DB) error {
-		q := db.Model(&Account{})
+		q := db.Model(&Account{}).Scopes(c.inBudget)
 
 		if !showHidden {
 			q = q.Where("hidden = ?", false)
 		}
 
-		return q.Find(&a).Error
+		return q.Order("sort_order, name").Find(&a).Error
 	}); err != nil {
 		return a, fmt.Errorf("listing accounts: %w", err)
 	}
@@ -224,13 +292,13 @@ func (c *Client) ListAccounts(showHidden bool) (a []Account, err error) {
 //revive:disable-next-line:flag-parameter // not a behavior switch but a filter
 func (c *Client) ListAccountsByType(at AccountType, showHidden bool) (a []Account, err error) {
 	if err = c.retryRead(func(db *gorm.DB) error {
-		q := db.Where("type = ?", at)
+		q := db.Scopes(c.inBudget).Where("type = ?", at)
 
 		if !showHidden {
 			q = q.Where("hidden = ?", false)
 		}
 
-		return q.Find(&a).Error
+		return q.Order("sort_order, name").Find(&a).Error
 	}); err != nil {
 		return a, fmt.Errorf("listing accounts: %w", err)
 	}
@@ -238,10 +306,36 @@ func (c *Client) ListAccountsByType(at AccountType, showHidden bool) (a []Accoun
 	return a, nil
 }
 
+// ListPairedTransactions retrieves the counterparts of all paired
+// transactions of the given account
+func (c *Client) ListPairedTransactions(acc uuid.UUID) (txs []Transaction, err error) {
+	if _, err = c.GetAccount(acc); err != nil {
+		return nil, err
+	}
+
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.
+			Scopes(c.inBudget).
+			Where("account IS NULL OR account <> ?", acc).
+			Find(
+				&txs,
+				"pair_key IN (?)",
+				db.Model(&Transaction{}).Scopes(c.inBudget).Select("pair_key").Where("account = ? AND pair_key IS NOT NULL", acc),
+			).
+			Error
+	}); err != nil {
+		return txs, fmt.Errorf("listing transactions: %w", err)
+	}
+
+	return txs, nil
+}
+
 // ListTransactions retrieves all transactions
 func (c *Client) ListTransactions(since, until time.Time) (txs []Transaction, err error) {
 	if err = c.retryRead(func(db *gorm.DB) error {
 		return db.
+			Scopes(c.inBudget).
+			Preload("Splits").
 			Where("time >= ? and time <= ?", since, until).
 			Find(&txs).
 			Error
@@ -255,10 +349,25 @@ func (c *Client) ListTransactions(since, until time.Time) (txs []Transaction, er
 // ListTransactionsByAccount retrieves all transactions for an account
 // or category
 func (c *Client) ListTransactionsByAccount(acc uuid.UUID, since, until time.Time) (txs []Transaction, err error) {
+	if _, err = c.GetAccount(acc); err != nil {
+		return nil, err
+	}
+
 	if err = c.retryRead(func(db *gorm.DB) error {
 		return db.
+			Scopes(c.inBudget).
+			Preload("Splits").
 			Where("time >= ? and time <= ?", since, until).
-			Find(&txs, "account = ? OR category = ?", acc, acc).
+			Find(
+				&txs,
+				"account = ? OR category = ? OR id IN (?)",
+				acc, acc,
+				db.Model(&TransactionSplit{}).
+					Joins("JOIN transactions ON transactions.id = transaction_splits.transaction_id").
+					Where("transactions.budget = ?", c.budget.ID).
+					Where("transaction_splits.category = ?", acc).
+					Select("transaction_splits.transaction_id"),
+			).
 			Error
 	}); err != nil {
 		return txs, fmt.Errorf("listing transactions: %w", err)
@@ -267,27 +376,18 @@ func (c *Client) ListTransactionsByAccount(acc uuid.UUID, since, until time.Time
 	return txs, nil
 }
 
-// MarkAccountReconciled marks all cleared transactions as reconciled.
-// The account balance is NOT checked in this method.
-func (c *Client) MarkAccountReconciled(acc uuid.UUID) (err error) {
-	if err = c.retryTx(func(db *gorm.DB) error {
-		return db.
-			Model(&Transaction{}).
-			Where("account = ?", acc).
-			Where("cleared = ?", true).
-			Update("reconciled", true).
-			Error
-	}); err != nil {
-		return fmt.Errorf("updating transactions: %w", err)
-	}
-
-	return nil
+// MergeAccounts moves all transactions, splits and scheduled
+// transactions of an account into another account of the same type and
+// deletes the now empty account afterwards. Transfers between both
+// accounts are removed as they would no longer move any money.
+func (c *Client) MergeAccounts(id, into uuid.UUID) error {
+	return c.DeleteAccount(id, uuid.NullUUID{UUID: into, Valid: true})
 }
 
 // TransferMoney creates new Transactions for the given account
 // transfer. The account type of the from and to account must match
 // for this to work.
-func (c *Client) TransferMoney(from, to uuid.UUID, amount float64, description string) (err error) {
+func (c *Client) TransferMoney(from, to uuid.UUID, amount Money, description string) (err error) {
 	var fromAcc, toAcc Account
 
 	if fromAcc, err = c.GetAccount(from); err != nil {
@@ -318,6 +418,7 @@ func (c *Client) TransferMoney(from, to uuid.UUID, amount float64, description s
 				Category:    uuid.NullUUID{},
 				Cleared:     false,
 				PairKey:     uuid.NullUUID{UUID: pairKey, Valid: true},
+				Budget:      c.budget.ID,
 			},
 			{
 				Time:        time.Now().UTC(),
@@ -328,6 +429,7 @@ func (c *Client) TransferMoney(from, to uuid.UUID, amount float64, description s
 				Category:    uuid.NullUUID{},
 				Cleared:     false,
 				PairKey:     uuid.NullUUID{UUID: pairKey, Valid: true},
+				Budget:      c.budget.ID,
 			},
 		}
 
@@ -343,6 +445,7 @@ func (c *Client) TransferMoney(from, to uuid.UUID, amount float64, description s
 				Category:    uuid.NullUUID{UUID: from, Valid: true},
 				Cleared:     true,
 				PairKey:     uuid.NullUUID{UUID: pairKey, Valid: true},
+				Budget:      c.budget.ID,
 			},
 			{
 				Time:        time.Now().UTC(),
@@ -353,6 +456,7 @@ func (c *Client) TransferMoney(from, to uuid.UUID, amount float64, description s
 				Category:    uuid.NullUUID{UUID: to, Valid: true},
 				Cleared:     true,
 				PairKey:     uuid.NullUUID{UUID: pairKey, Valid: true},
+				Budget:      c.budget.ID,
 			},
 		}
 	}
@@ -374,7 +478,7 @@ func (c *Client) TransferMoney(from, to uuid.UUID, amount float64, description s
 
 // TransferMoneyWithCategory creates new Transactions for the given
 // account transfer. This is not possible for category type accounts.
-func (c *Client) TransferMoneyWithCategory(from, to uuid.UUID, amount float64, description string, category uuid.UUID) (err error) {
+func (c *Client) TransferMoneyWithCategory(from, to uuid.UUID, amount Money, description string, category uuid.UUID) (err error) {
 	var fromAcc, toAcc Account
 
 	if fromAcc, err = c.GetAccount(from); err != nil {
@@ -389,6 +493,17 @@ func (c *Client) TransferMoneyWithCategory(from, to uuid.UUID, amount float64, d
 		return fmt.Errorf("transfer contained category-type account")
 	}
 
+	if fromAcc.Type == AccountTypeBudget || toAcc.Type == AccountTypeBudget {
+		cat, err := c.GetAccount(category)
+		if err != nil {
+			return fmt.Errorf("getting category: %w", err)
+		}
+
+		if cat.Type != AccountTypeCategory {
+			return fmt.Errorf("transfer category is no category-type account")
+		}
+	}
+
 	pairKey := uuid.Must(uuid.NewRandom())
 
 	if err = c.retryTx(func(tx *gorm.DB) (err error) {
@@ -401,6 +516,7 @@ func (c *Client) TransferMoneyWithCategory(from, to uuid.UUID, amount float64, d
 			Category:    uuid.NullUUID{},
 			Cleared:     false,
 			PairKey:     uuid.NullUUID{UUID: pairKey, Valid: true},
+			Budget:      c.budget.ID,
 		}
 
 		if fromAcc.Type == AccountTypeBudget {
@@ -416,6 +532,7 @@ func (c *Client) TransferMoneyWithCategory(from, to uuid.UUID, amount float64, d
 			Category:    uuid.NullUUID{},
 			Cleared:     false,
 			PairKey:     uuid.NullUUID{UUID: pairKey, Valid: true},
+			Budget:      c.budget.ID,
 		}
 
 		if toAcc.Type == AccountTypeBudget {
@@ -441,6 +558,7 @@ func (c *Client) UpdateAccountHidden(id uuid.UUID, hidden bool) (err error) {
 	if err = c.retryTx(func(db *gorm.DB) error {
 		return db.
 			Model(&Account{}).
+			Scopes(c.inBudget).
 			Where("id = ?", id).
 			Update("hidden", hidden).
 			Error
@@ -456,6 +574,7 @@ func (c *Client) UpdateAccountName(id uuid.UUID, name string) (err error) {
 	if err = c.retryTx(func(db *gorm.DB) error {
 		return db.
 			Model(&Account{}).
+			Scopes(c.inBudget).
 			Where("id = ?", id).
 			Update("name", name).
 			Error
@@ -471,7 +590,7 @@ func (c *Client) UpdateAccountName(id uuid.UUID, name string) (err error) {
 func (c *Client) UpdateTransaction(txID uuid.UUID, tx Transaction) (err error) {
 	if err = c.retryTx(func(db *gorm.DB) error {
 		var oldTX Transaction
-		if err := db.First(&oldTX, "id = ?", txID).Error; err != nil {
+		if err := db.Scopes(c.inBudget).First(&oldTX, "id = ?", txID).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return backoff.NewErrCannotRetry(fmt.Errorf("fetching old transaction: %w", err))
 			}
@@ -479,17 +598,32 @@ func (c *Client) UpdateTransaction(txID uuid.UUID, tx Transaction) (err error) {
 		}
 
 		tx.ID = txID
-		tx.Account = oldTX.Account // Changing that would create chaos
-		tx.PairKey = oldTX.PairKey // Updating a paired tx should not decouple it
+		tx.Budget = oldTX.Budget
+		tx.Account = oldTX.Account   // Changing that would create chaos
+		tx.PairKey = oldTX.PairKey   // Updating a paired tx should not decouple it
+		tx.ImportID = oldTX.ImportID // Needed to detect already imported transactions
 
 		if err = tx.Validate(c); err != nil {
 			return fmt.Errorf("validating transaction: %w", err)
 		}
 
-		if err = db.Save(&tx).Error; err != nil {
+		if err = db.Omit(clause.Associations).Save(&tx).Error; err != nil {
 			return fmt.Errorf("saving transaction: %w", err)
 		}
 
+		// Splits are owned by the transaction and therefore replaced
+		// as a whole instead of being updated one by one
+		if err = db.Unscoped().Delete(&TransactionSplit{}, "transaction_id = ?", txID).Error; err != nil {
+			return fmt.Errorf("removing old splits: %w", err)
+		}
+
+		for i := range tx.Splits {
+			tx.Splits[i].TransactionID = txID
+			if err = db.Create(&tx.Splits[i]).Error; err != nil {
+				return fmt.Errorf("saving split: %w", err)
+			}
+		}
+
 		if !oldTX.PairKey.Valid || tx.Amount == oldTX.Amount {
 			// is not a paired transaction or amount did not change: skip rest
 			return nil
@@ -501,7 +635,7 @@ func (c *Client) UpdateTransaction(txID uuid.UUID, tx Transaction) (err error) {
 		if err = db.Model(&Transaction{}).
 			Where("pair_key = ?", oldTX.PairKey.UUID).
 			Where("id <> ?", oldTX.ID).
-			Update("amount", -tx.Amount).
+			Update("amount_cents", -tx.Amount).
 			Error; err != nil {
 			return fmt.Errorf("updating amount for paired transaction: %w", err)
 		}
@@ -519,7 +653,7 @@ func (c *Client) UpdateTransaction(txID uuid.UUID, tx Transaction) (err error) {
 func (c *Client) UpdateTransactionCategory(id uuid.UUID, cat uuid.UUID) (err error) {
 	if err = c.retryTx(func(db *gorm.DB) error {
 		var tx Transaction
-		if err = db.First(&tx, "id = ?", id).Error; err != nil {
+		if err = db.Scopes(c.inBudget).Preload("Splits").First(&tx, "id = ?", id).Error; err != nil {
 			return fmt.Errorf("fetching transaction: %w", err)
 		}
 
@@ -529,6 +663,7 @@ func (c *Client) UpdateTransactionCategory(id uuid.UUID, cat uuid.UUID) (err err
 		}
 
 		if err = db.
+			Omit(clause.Associations).
 			Save(&tx).
 			Error; err != nil {
 			return fmt.Errorf("saving transaction: %w", err)
@@ -548,6 +683,7 @@ func (c *Client) UpdateTransactionCleared(id uuid.UUID, cleared bool) (err error
 	if err = c.retryTx(func(db *gorm.DB) error {
 		return db.
 			Model(&Transaction{}).
+			Scopes(c.inBudget).
 			Where("id = ?", id).
 			Update("cleared", cleared).
 			Error
@@ -558,6 +694,168 @@ func (c *Client) UpdateTransactionCleared(id uuid.UUID, cleared bool) (err error
 	return nil
 }
 
+// accountBalance sums up all transactions of the given account. For
+// categories this includes the parts of split transactions.
+func (c *Client) accountBalance(acc Account) (bal Money, err error) {
+	err = c.retryRead(func(db *gorm.DB) (err error) {
+		bal, err = sumAccountBalance(db, acc)
+		return err
+	})
+
+	return bal, err
+}
+
+// ensureAccountUnused checks the account has a zero balance and no
+// active scheduled transactions referencing it
+func (c *Client) ensureAccountUnused(db *gorm.DB, acc Account) error {
+	bal, err := sumAccountBalance(db, acc)
+	if err != nil {
+		return fmt.Errorf("getting balance: %w", err)
+	}
+
+	if bal != 0 {
+		return backoff.NewErrCannotRetry(ErrAccountNotEmpty)
+	}
+
+	var n int64
+	if err = db.Model(&ScheduledTransaction{}).
+		Scopes(c.inBudget).
+		Where("account = ? OR category = ?", acc.ID, acc.ID).
+		Where("next_due IS NOT NULL").
+		Count(&n).Error; err != nil {
+		return fmt.Errorf("counting scheduled transactions: %w", err)
+	}
+
+	if n > 0 {
+		return backoff.NewErrCannotRetry(ErrAccountScheduled)
+	}
+
+	return nil
+}
+
+// moveAccountRecords points all transactions (including deleted ones),
+// splits, scheduled transactions and reconciliations of the account to
+// the other account of the same type and removes transfers which now
+// start and end in that account
+func (c *Client) moveAccountRecords(db *gorm.DB, from, into Account) error {
+	col := "account"
+	if from.Type == AccountTypeCategory {
+		col = "category"
+	}
+
+	if err := db.Unscoped().Model(&Transaction{}).Scopes(c.inBudget).
+		Where(col+" = ?", from.ID).
+		Update(col, into.ID).Error; err != nil {
+		return fmt.Errorf("moving transactions: %w", err)
+	}
+
+	if from.Type == AccountTypeCategory {
+		if err := db.Model(&TransactionSplit{}).
+			Where("category = ?", from.ID).
+			Update("category", into.ID).Error; err != nil {
+			return fmt.Errorf("moving splits: %w", err)
+		}
+	}
+
+	if err := db.Model(&ScheduledTransaction{}).Scopes(c.inBudget).
+		Where(col+" = ?", from.ID).
+		Update(col, into.ID).Error; err != nil {
+		return fmt.Errorf("moving scheduled transactions: %w", err)
+	}
+
+	if err := db.Model(&Reconciliation{}).Scopes(c.inBudget).
+		Where("account = ?", from.ID).
+		Update("account", into.ID).Error; err != nil {
+		return fmt.Errorf("moving reconciliations: %w", err)
+	}
+
+	selfTransfers := db.Model(&Transaction{}).Scopes(c.inBudget).
+		Select("pair_key").
+		Where("pair_key IS NOT NULL").
+		Where(col+" = ?", into.ID).
+		Group("pair_key").
+		Having("COUNT(*) > 1")
+
+	if err := db.Scopes(c.inBudget).Delete(&Transaction{}, "pair_key IN (?)", selfTransfers).Error; err != nil {
+		return fmt.Errorf("removing transfers within the account: %w", err)
+	}
+
+	return nil
+}
+
+// sumAccountBalance executes the queries of accountBalance on the
+// given database handle to be usable inside a transaction
+func sumAccountBalance(db *gorm.DB, acc Account) (bal Money, err error) {
+	q := db.
+		Model(&Transaction{})
+
+	if acc.Type == AccountTypeCategory {
+		q = q.Where("category = ?", acc.ID)
+	} else {
+		q = q.Where("account = ?", acc.ID)
+	}
+
+	var v *int64
+	if err = q.
+		Select("CAST(SUM(amount_cents) AS BIGINT)").
+		Scan(&v).
+		Error; err != nil {
+		return 0, fmt.Errorf("getting sum: %w", err)
+	}
+
+	if v != nil {
+		bal = Money(*v)
+	}
+
+	if acc.Type != AccountTypeCategory {
+		return bal, nil
+	}
+
+	// Categories additionally hold the parts of split transactions
+	v = nil
+	if err = db.
+		Model(&TransactionSplit{}).
+		Joins("JOIN transactions ON transactions.id = transaction_splits.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transaction_splits.category = ?", acc.ID).
+		Select("CAST(SUM(transaction_splits.amount_cents) AS BIGINT)").
+		Scan(&v).
+		Error; err != nil {
+		return 0, fmt.Errorf("getting split sum: %w", err)
+	}
+
+	if v != nil {
+		bal += Money(*v)
+	}
+
+	return bal, nil
+}
+
+// migrateAmountsToCents converts the legacy float "amount" column into
+// the integer "amount_cents" column and drops the legacy column
+// afterwards. If the legacy column does not exist nothing is done.
+func migrateAmountsToCents(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("transactions", "amount") {
+		return nil
+	}
+
+	//nolint:wrapcheck // is wrapped in the caller
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Table("transactions").
+			Where("amount IS NOT NULL").
+			UpdateColumn("amount_cents", gorm.Expr("CAST(ROUND(amount * 100) AS BIGINT)")).
+			Error; err != nil {
+			return fmt.Errorf("converting amounts: %w", err)
+		}
+
+		if err := tx.Migrator().DropColumn(&Transaction{}, "amount"); err != nil {
+			return fmt.Errorf("dropping legacy column: %w", err)
+		}
+
+		return nil
+	})
+}
+
 func (c *Client) retryRead(fn func(db *gorm.DB) error) error {
 	//nolint:wrapcheck // inner error is from this lib and shall not be tainted
 	return backoff.NewBackoff().
@@ -571,11 +869,19 @@ func (c *Client) retryRead(fn func(db *gorm.DB) error) error {
 		})
 }
 
+// retryTx executes fn in a transaction, all audit entries written in
+// that transaction share one operation ID unless the client already
+// groups its changes through InOperation
 func (c *Client) retryTx(fn func(db *gorm.DB) error) error {
 	//nolint:wrapcheck // inner error is from this lib and shall not be tainted
 	return backoff.NewBackoff().
 		WithMaxIterations(dbMaxRetries).
 		Retry(func() error {
-			return c.db.Transaction(fn)
+			db := c.db
+			if _, ok := db.Statement.Context.Value(auditKeyOperation).(uuid.UUID); !ok {
+				db = withContextValue(db, auditKeyOperation, uuid.New())
+			}
+
+			return db.Transaction(fn)
 		})
 }
@@ -1,12 +1,15 @@
 package database
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 const testDSN = "file::memory:?cache=shared"
@@ -77,6 +80,94 @@ func TestAccountManagement(t *testing.T) {
 	assert.Equal(t, "renamed", act.Name)
 }
 
+func TestDeleteAndMergeAccounts(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	giro, err := dbc.CreateAccount("Giro", AccountTypeBudget)
+	require.NoError(t, err)
+	cash, err := dbc.CreateAccount("Cash", AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := dbc.CreateAccount("Groceries", AccountTypeCategory)
+	require.NoError(t, err)
+
+	_, err = dbc.CreateTransaction(Transaction{
+		Time:     time.Now(),
+		Payee:    "Employer",
+		Amount:   10000,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, dbc.TransferMoney(checking.ID, giro.ID, 2500, ""))
+
+	assert.ErrorIs(t, dbc.DeleteAccount(UnallocatedMoney, uuid.NullUUID{}), ErrAccountProtected)
+	assert.ErrorIs(t, dbc.DeleteAccount(checking.ID, uuid.NullUUID{}), ErrAccountNotEmpty)
+	assert.ErrorIs(t, dbc.MergeAccounts(checking.ID, checking.ID), ErrInvalidReplacement)
+	assert.ErrorIs(t, dbc.MergeAccounts(checking.ID, groceries.ID), ErrInvalidReplacement)
+
+	// Empty accounts can be deleted without replacement
+	require.NoError(t, dbc.DeleteAccount(cash.ID, uuid.NullUUID{}))
+	_, err = dbc.GetAccount(cash.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Merging removes the transfer between both accounts
+	require.NoError(t, dbc.MergeAccounts(checking.ID, giro.ID))
+
+	bal, err := dbc.GetAccountBalance(giro.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(10000), bal.Balance)
+
+	txs, err := dbc.ListTransactionsByAccount(giro.ID, time.Time{}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, "Employer", txs[0].Payee)
+
+	_, err = dbc.GetAccount(checking.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Deleting with replacement moves the remaining balance
+	require.NoError(t, dbc.TransferMoney(UnallocatedMoney, groceries.ID, 3000, ""))
+	require.NoError(t, dbc.DeleteAccount(groceries.ID, uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}))
+
+	bal, err = dbc.GetAccountBalance(UnallocatedMoney)
+	require.NoError(t, err)
+	assert.Equal(t, Money(10000), bal.Balance)
+}
+
+func TestMigrateAmountsToCents(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+
+	type legacyTransaction struct {
+		BaseModel
+		Time     time.Time
+		Amount   float64
+		Category uuid.NullUUID `gorm:"type:uuid"`
+	}
+
+	// Create a database using the legacy float amount column
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, legacy.Table("transactions").AutoMigrate(&legacyTransaction{}))
+	for _, amount := range []float64{0.1, 0.2, -12.34} {
+		require.NoError(t, legacy.Table("transactions").Create(&legacyTransaction{
+			Time:     time.Now(),
+			Amount:   amount,
+			Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+		}).Error)
+	}
+
+	dbc, err := New("sqlite", dsn)
+	require.NoError(t, err)
+	assert.False(t, dbc.db.Migrator().HasColumn(&Transaction{}, "amount"))
+
+	bals, err := dbc.ListAccountBalances(false)
+	require.NoError(t, err)
+	testCheckAcctBal(t, bals, UnallocatedMoney, -1204)
+}
+
 func TestPairKeyRemoval(t *testing.T) {
 	dbc, err := New("sqlite", testDSN)
 	require.NoError(t, err)
@@ -263,15 +354,85 @@ func TestTransactions(t *testing.T) {
 	assert.Len(t, txs, 3)
 }
 
-func testCheckAcctBal(t *testing.T, bals []AccountBalance, act uuid.UUID, bal float64) {
+func testCheckAcctBal(t *testing.T, bals []AccountBalance, act uuid.UUID, bal Money) {
 	t.Helper()
 
 	for _, b := range bals {
 		if b.ID == act {
-			assert.InDelta(t, bal, b.Balance, 0)
+			assert.Equal(t, bal, b.Balance)
 			return
 		}
 	}
 
 	t.Errorf("account %s balance not found", act)
 }
+
+func TestSplitTransactions(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	tb, err := dbc.CreateAccount("test", AccountTypeBudget)
+	require.NoError(t, err)
+	tc1, err := dbc.CreateAccount("groceries", AccountTypeCategory)
+	require.NoError(t, err)
+	tc2, err := dbc.CreateAccount("household", AccountTypeCategory)
+	require.NoError(t, err)
+
+	// Buy some stuff in the supermarket
+	tx, err := dbc.CreateTransaction(Transaction{
+		Time:    time.Now(),
+		Payee:   "Supermarket",
+		Amount:  -100,
+		Account: uuid.NullUUID{UUID: tb.ID, Valid: true},
+		Splits: []TransactionSplit{
+			{Description: "Food", Amount: -70, Category: uuid.NullUUID{UUID: tc1.ID, Valid: true}},
+			{Description: "Detergent", Amount: -30, Category: uuid.NullUUID{UUID: tc2.ID, Valid: true}},
+		},
+	})
+	require.NoError(t, err)
+
+	bals, err := dbc.ListAccountBalances(false)
+	require.NoError(t, err)
+	testCheckAcctBal(t, bals, tb.ID, -100)
+	testCheckAcctBal(t, bals, tc1.ID, -70)
+	testCheckAcctBal(t, bals, tc2.ID, -30)
+
+	// Splits are returned with the transaction and it is listed for
+	// the split categories
+	tx, err = dbc.GetTransactionByID(tx.ID)
+	require.NoError(t, err)
+	assert.Len(t, tx.Splits, 2)
+
+	txs, err := dbc.ListTransactionsByAccount(tc2.ID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, tx.ID, txs[0].ID)
+
+	// Splits can not be mixed with a category
+	require.Error(t, dbc.UpdateTransactionCategory(tx.ID, tc1.ID))
+
+	// Re-split the transaction
+	tx.Splits = []TransactionSplit{
+		{Description: "Food", Amount: -90, Category: uuid.NullUUID{UUID: tc1.ID, Valid: true}},
+		{Description: "Detergent", Amount: -10, Category: uuid.NullUUID{UUID: tc2.ID, Valid: true}},
+	}
+	require.NoError(t, dbc.UpdateTransaction(tx.ID, tx))
+
+	bals, err = dbc.ListAccountBalances(false)
+	require.NoError(t, err)
+	testCheckAcctBal(t, bals, tb.ID, -100)
+	testCheckAcctBal(t, bals, tc1.ID, -90)
+	testCheckAcctBal(t, bals, tc2.ID, -10)
+
+	// Splits not adding up are rejected
+	tx.Splits[0].Amount = -50
+	require.Error(t, dbc.UpdateTransaction(tx.ID, tx))
+
+	// Deleted transactions do not count for their splits
+	require.NoError(t, dbc.DeleteTransaction(tx.ID))
+	bals, err = dbc.ListAccountBalances(false)
+	require.NoError(t, err)
+	testCheckAcctBal(t, bals, tb.ID, 0)
+	testCheckAcctBal(t, bals, tc1.ID, 0)
+	testCheckAcctBal(t, bals, tc2.ID, 0)
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const monthsPerYear = 12
+
+type (
+	// GoalStatus wraps a CategoryGoal and adds the amount still to be
+	// allocated in the current month to stay on track
+	GoalStatus struct {
+		CategoryGoal
+		Underfunded Money `json:"underfunded"`
+	}
+)
+
+// DeleteCategoryGoal removes the goal from the given category
+func (c *Client) DeleteCategoryGoal(category uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.
+			Unscoped().
+			Delete(
+				&CategoryGoal{},
+				"category = ? AND category IN (?)",
+				category,
+				db.Model(&Account{}).Scopes(c.inBudget).Select("id"),
+			).
+			Error
+	}); err != nil {
+		return fmt.Errorf("deleting goal: %w", err)
+	}
+
+	return nil
+}
+
+// ListUnderfundedCategories returns the categories having a goal which
+// is not yet fulfilled in the month containing the given time
+func (c *Client) ListUnderfundedCategories(month time.Time) (cats []BudgetCategorySummary, err error) {
+	s, err := c.GetBudgetSummary(month, true)
+	if err != nil {
+		return nil, fmt.Errorf("getting budget summary: %w", err)
+	}
+
+	for _, cs := range s.Categories {
+		if cs.Goal != nil && cs.Goal.Underfunded > 0 {
+			cats = append(cats, cs)
+		}
+	}
+
+	return cats, nil
+}
+
+// SetCategoryGoal sets (or replaces) the goal of the category
+// given in the goal
+func (c *Client) SetCategoryGoal(g CategoryGoal) (ng CategoryGoal, err error) {
+	if err = g.Validate(c); err != nil {
+		return g, fmt.Errorf("validating goal: %w", err)
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		if err := db.Unscoped().Delete(&CategoryGoal{}, "category = ?", g.Category).Error; err != nil {
+			return fmt.Errorf("removing old goal: %w", err)
+		}
+
+		return db.Create(&g).Error
+	}); err != nil {
+		return g, fmt.Errorf("setting goal: %w", err)
+	}
+
+	return g, nil
+}
+
+func (c *Client) listCategoryGoals() (goals map[uuid.UUID]CategoryGoal, err error) {
+	var gs []CategoryGoal
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Find(&gs).Error
+	}); err != nil {
+		return nil, fmt.Errorf("listing goals: %w", err)
+	}
+
+	goals = make(map[uuid.UUID]CategoryGoal, len(gs))
+	for _, g := range gs {
+		goals[g.Category] = g
+	}
+
+	return goals, nil
+}
+
+// listGoalStatus returns the status of all goals in the given month
+// indexed by their category
+//
+//revive:disable-next-line:flag-parameter // not a behavior switch but a filter
+func (c *Client) listGoalStatus(month time.Time, showHidden bool) (status map[uuid.UUID]*GoalStatus, err error) {
+	goals, err := c.listCategoryGoals()
+	if err != nil {
+		return nil, err
+	}
+
+	status = make(map[uuid.UUID]*GoalStatus, len(goals))
+	if len(goals) == 0 {
+		// Skip calculating the summary if there is nothing to do
+		return status, nil
+	}
+
+	s, err := c.GetBudgetSummary(month, showHidden)
+	if err != nil {
+		return nil, fmt.Errorf("getting budget summary: %w", err)
+	}
+
+	for _, cs := range s.Categories {
+		if cs.Goal != nil {
+			status[cs.ID] = cs.Goal
+		}
+	}
+
+	return status, nil
+}
+
+// status calculates how much money still needs to be allocated to the
+// category in the given month to stay on track
+func (g CategoryGoal) status(month time.Time, cs BudgetCategorySummary) *GoalStatus {
+	var needed Money
+
+	switch g.Type {
+	case GoalTypeMonthlyFunding:
+		needed = g.Amount - cs.Allocated
+
+	case GoalTypeMonthlySpending:
+		needed = g.Amount - cs.CarryOver - cs.Allocated
+
+	case GoalTypeTargetBalance:
+		// Spread the missing money evenly over the remaining months
+		// including the current one
+		months := Money(max(1, (g.TargetDate.Year()-month.Year())*monthsPerYear+int(g.TargetDate.Month()-month.Month())+1))
+		if missing := g.Amount - cs.CarryOver; missing > 0 {
+			needed = (missing+months-1)/months - cs.Allocated
+		}
+	}
+
+	return &GoalStatus{CategoryGoal: g, Underfunded: max(0, needed)}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryGoals(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	var (
+		lastMonth  = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+		thisMonth  = time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC)
+		targetDate = time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)
+	)
+
+	tb, err := dbc.CreateAccount("test", AccountTypeBudget)
+	require.NoError(t, err)
+	rent, err := dbc.CreateAccount("rent", AccountTypeCategory)
+	require.NoError(t, err)
+	food, err := dbc.CreateAccount("food", AccountTypeCategory)
+	require.NoError(t, err)
+	vacation, err := dbc.CreateAccount("vacation", AccountTypeCategory)
+	require.NoError(t, err)
+
+	for _, tx := range []Transaction{
+		{Time: lastMonth, Amount: 10000, Account: uuid.NullUUID{UUID: tb.ID, Valid: true}, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		// Last month: 100 to food, 200 to vacation
+		{Time: lastMonth, Amount: 100, Category: uuid.NullUUID{UUID: food.ID, Valid: true}},
+		{Time: lastMonth, Amount: 200, Category: uuid.NullUUID{UUID: vacation.ID, Valid: true}},
+		// This month: 300 to rent
+		{Time: thisMonth, Amount: 300, Category: uuid.NullUUID{UUID: rent.ID, Valid: true}},
+	} {
+		_, err = dbc.CreateTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	// Goals can only be set on categories
+	_, err = dbc.SetCategoryGoal(CategoryGoal{Category: tb.ID, Type: GoalTypeMonthlyFunding, Amount: 500})
+	require.Error(t, err)
+
+	// Target balance needs a date
+	_, err = dbc.SetCategoryGoal(CategoryGoal{Category: vacation.ID, Type: GoalTypeTargetBalance, Amount: 1000})
+	require.Error(t, err)
+
+	_, err = dbc.SetCategoryGoal(CategoryGoal{Category: rent.ID, Type: GoalTypeMonthlyFunding, Amount: 500})
+	require.NoError(t, err)
+	_, err = dbc.SetCategoryGoal(CategoryGoal{Category: food.ID, Type: GoalTypeMonthlySpending, Amount: 80})
+	require.NoError(t, err)
+	_, err = dbc.SetCategoryGoal(CategoryGoal{Category: vacation.ID, Type: GoalTypeTargetBalance, Amount: 1000, TargetDate: &targetDate})
+	require.NoError(t, err)
+
+	s, err := dbc.GetBudgetSummary(thisMonth, false)
+	require.NoError(t, err)
+
+	for _, cs := range s.Categories {
+		require.NotNil(t, cs.Goal, cs.Name)
+
+		switch cs.ID {
+		case rent.ID:
+			// 500 per month, 300 allocated
+			assert.Equal(t, Money(200), cs.Goal.Underfunded)
+		case food.ID:
+			// Spend up to 80 per month, 100 available
+			assert.Equal(t, Money(0), cs.Goal.Underfunded)
+		case vacation.ID:
+			// 800 missing in 4 months (Feb, Mar, Apr, May)
+			assert.Equal(t, Money(200), cs.Goal.Underfunded)
+		}
+	}
+
+	under, err := dbc.ListUnderfundedCategories(thisMonth)
+	require.NoError(t, err)
+	assert.Len(t, under, 2)
+
+	// Replacing the goal works
+	_, err = dbc.SetCategoryGoal(CategoryGoal{Category: rent.ID, Type: GoalTypeMonthlyFunding, Amount: 300})
+	require.NoError(t, err)
+	require.NoError(t, dbc.DeleteCategoryGoal(vacation.ID))
+
+	under, err = dbc.ListUnderfundedCategories(thisMonth)
+	require.NoError(t, err)
+	assert.Empty(t, under)
+}
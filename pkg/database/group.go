@@ -0,0 +1,227 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	// AccountGroupBalance wraps an AccountGroup and adds the balances
+	// of the accounts in it together with their subtotals per account
+	// type as budget, tracking and category balances must not be summed
+	AccountGroupBalance struct {
+		AccountGroup
+		Accounts []AccountBalance      `json:"accounts"`
+		Balances map[AccountType]Money `json:"balances"`
+	}
+)
+
+// CreateAccountGroup creates and returns a new account group sorted
+// after all existing groups
+func (c *Client) CreateAccountGroup(name string) (g AccountGroup, err error) {
+	g = AccountGroup{Budget: c.budget.ID, Name: name}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		var maxOrder *int
+		if err := db.Model(&AccountGroup{}).Scopes(c.inBudget).Select("MAX(sort_order)").Scan(&maxOrder).Error; err != nil {
+			return fmt.Errorf("getting sort order: %w", err)
+		}
+
+		if maxOrder != nil {
+			g.SortOrder = *maxOrder + 1
+		}
+
+		return db.Save(&g).Error
+	}); err != nil {
+		return g, fmt.Errorf("creating account group: %w", err)
+	}
+
+	return g, nil
+}
+
+// GetAccountGroup retrieves an AccountGroup using its ID
+func (c *Client) GetAccountGroup(id uuid.UUID) (g AccountGroup, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Scopes(c.inBudget).First(&g, "id = ?", id).Error
+	}); err != nil {
+		return g, fmt.Errorf("fetching account group: %w", err)
+	}
+
+	return g, nil
+}
+
+// ListAccountGroupBalances returns the account balances grouped by
+// their AccountGroup together with a subtotal per group and account
+// type. Accounts not
+// being in any group are returned in a trailing group having a nil ID.
+//
+//revive:disable-next-line:flag-parameter // not a behavior switch but a filter
+func (c *Client) ListAccountGroupBalances(showHidden bool) (gb []AccountGroupBalance, err error) {
+	groups, err := c.ListAccountGroups()
+	if err != nil {
+		return nil, fmt.Errorf("listing groups: %w", err)
+	}
+
+	bals, err := c.ListAccountBalances(showHidden)
+	if err != nil {
+		return nil, fmt.Errorf("listing balances: %w", err)
+	}
+
+	gb = make([]AccountGroupBalance, len(groups)+1)
+	idx := make(map[uuid.UUID]int, len(groups))
+	for i, g := range groups {
+		gb[i].AccountGroup = g
+		idx[g.ID] = i
+	}
+
+	for i := range gb {
+		gb[i].Balances = map[AccountType]Money{}
+	}
+
+	for _, b := range bals {
+		i, ok := idx[b.Group.UUID]
+		if !b.Group.Valid || !ok {
+			i = len(groups)
+		}
+
+		gb[i].Accounts = append(gb[i].Accounts, b)
+		gb[i].Balances[b.Type] += b.Balance
+	}
+
+	return gb, nil
+}
+
+// ListAccountGroups returns a list of all account groups in their
+// sort order
+func (c *Client) ListAccountGroups() (g []AccountGroup, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Scopes(c.inBudget).Order("sort_order, name").Find(&g).Error
+	}); err != nil {
+		return g, fmt.Errorf("listing account groups: %w", err)
+	}
+
+	return g, nil
+}
+
+// MoveAccountsToGroup moves the given accounts into the group (or out
+// of any group if the group is null) and sorts them in the given order
+func (c *Client) MoveAccountsToGroup(group uuid.NullUUID, accounts []uuid.UUID) (err error) {
+	if group.Valid {
+		if _, err = c.GetAccountGroup(group.UUID); err != nil {
+			return fmt.Errorf("fetching group: %w", err)
+		}
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		for i, id := range accounts {
+			res := db.
+				Model(&Account{}).
+				Scopes(c.inBudget).
+				Where("id = ?", id).
+				Updates(map[string]any{"account_group": group, "sort_order": i})
+			if res.Error != nil {
+				return fmt.Errorf("moving account %s: %w", id, res.Error)
+			}
+
+			if res.RowsAffected == 0 {
+				return backoff.NewErrCannotRetry(fmt.Errorf("moving account %s: %w", id, gorm.ErrRecordNotFound))
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("updating accounts: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateAccountGroup moves the given account into the group (or out
+// of any group if the group is null) and sorts it after all accounts
+// already in that group
+func (c *Client) UpdateAccountGroup(id uuid.UUID, group uuid.NullUUID) (err error) {
+	if group.Valid {
+		if _, err = c.GetAccountGroup(group.UUID); err != nil {
+			return fmt.Errorf("fetching group: %w", err)
+		}
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		q := db.Model(&Account{}).Scopes(c.inBudget).Select("MAX(sort_order)")
+		if group.Valid {
+			q = q.Where("account_group = ?", group.UUID)
+		} else {
+			q = q.Where("account_group IS NULL")
+		}
+
+		var maxOrder *int
+		if err := q.Scan(&maxOrder).Error; err != nil {
+			return fmt.Errorf("getting sort order: %w", err)
+		}
+
+		sortOrder := 0
+		if maxOrder != nil {
+			sortOrder = *maxOrder + 1
+		}
+
+		res := db.
+			Model(&Account{}).
+			Scopes(c.inBudget).
+			Where("id = ?", id).
+			Updates(map[string]any{"account_group": group, "sort_order": sortOrder})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return backoff.NewErrCannotRetry(gorm.ErrRecordNotFound)
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("updating account: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateAccountGroupName sets a new name for the given group ID
+func (c *Client) UpdateAccountGroupName(id uuid.UUID, name string) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.
+			Model(&AccountGroup{}).
+			Scopes(c.inBudget).
+			Where("id = ?", id).
+			Update("name", name).
+			Error
+	}); err != nil {
+		return fmt.Errorf("updating account group: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateAccountGroupOrder sorts the given groups in the given order
+func (c *Client) UpdateAccountGroupOrder(groups []uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		for i, id := range groups {
+			if err := db.
+				Model(&AccountGroup{}).
+				Scopes(c.inBudget).
+				Where("id = ?", id).
+				Update("sort_order", i).
+				Error; err != nil {
+				return fmt.Errorf("sorting group %s: %w", id, err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("updating account groups: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAccountGroups(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	tb, err := dbc.CreateAccount("test", AccountTypeBudget)
+	require.NoError(t, err)
+	rent, err := dbc.CreateAccount("rent", AccountTypeCategory)
+	require.NoError(t, err)
+	power, err := dbc.CreateAccount("power", AccountTypeCategory)
+	require.NoError(t, err)
+	food, err := dbc.CreateAccount("food", AccountTypeCategory)
+	require.NoError(t, err)
+
+	_, err = dbc.CreateTransaction(Transaction{
+		Time:     time.Now(),
+		Amount:   1000,
+		Account:  uuid.NullUUID{UUID: tb.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, dbc.TransferMoney(UnallocatedMoney, rent.ID, 500, ""))
+	require.NoError(t, dbc.TransferMoney(UnallocatedMoney, power.ID, 100, ""))
+	require.NoError(t, dbc.TransferMoney(UnallocatedMoney, food.ID, 200, ""))
+
+	living, err := dbc.CreateAccountGroup("Living")
+	require.NoError(t, err)
+	daily, err := dbc.CreateAccountGroup("Daily")
+	require.NoError(t, err)
+	assert.Equal(t, 1, daily.SortOrder)
+
+	// Moving to unknown group fails
+	require.Error(t, dbc.UpdateAccountGroup(food.ID, uuid.NullUUID{UUID: uuid.New(), Valid: true}))
+
+	// Moving unknown accounts fails and leaves the others untouched
+	assert.ErrorIs(t, dbc.UpdateAccountGroup(uuid.New(), uuid.NullUUID{UUID: living.ID, Valid: true}), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, dbc.MoveAccountsToGroup(uuid.NullUUID{UUID: living.ID, Valid: true}, []uuid.UUID{rent.ID, uuid.New()}), gorm.ErrRecordNotFound)
+	acc, err := dbc.GetAccount(rent.ID)
+	require.NoError(t, err)
+	assert.False(t, acc.Group.Valid)
+
+	require.NoError(t, dbc.MoveAccountsToGroup(uuid.NullUUID{UUID: living.ID, Valid: true}, []uuid.UUID{rent.ID, power.ID}))
+	require.NoError(t, dbc.UpdateAccountGroup(food.ID, uuid.NullUUID{UUID: daily.ID, Valid: true}))
+	require.NoError(t, dbc.UpdateAccountGroupName(daily.ID, "Everyday"))
+	require.NoError(t, dbc.UpdateAccountGroupOrder([]uuid.UUID{daily.ID, living.ID}))
+
+	gb, err := dbc.ListAccountGroupBalances(false)
+	require.NoError(t, err)
+	require.Len(t, gb, 3)
+
+	assert.Equal(t, "Everyday", gb[0].Name)
+	assert.Equal(t, map[AccountType]Money{AccountTypeCategory: 200}, gb[0].Balances)
+	require.Len(t, gb[0].Accounts, 1)
+
+	assert.Equal(t, living.ID, gb[1].ID)
+	assert.Equal(t, map[AccountType]Money{AccountTypeCategory: 600}, gb[1].Balances)
+	require.Len(t, gb[1].Accounts, 2)
+	assert.Equal(t, rent.ID, gb[1].Accounts[0].ID)
+	assert.Equal(t, power.ID, gb[1].Accounts[1].ID)
+
+	// Ungrouped accounts: budget account and unallocated money, kept
+	// apart as the money is already contained in the budget account
+	assert.Equal(t, uuid.Nil, gb[2].ID)
+	assert.Equal(t, map[AccountType]Money{AccountTypeBudget: 1000, AccountTypeCategory: 200}, gb[2].Balances)
+	assert.Len(t, gb[2].Accounts, 2)
+
+	// Move account out of the group
+	require.NoError(t, dbc.UpdateAccountGroup(power.ID, uuid.NullUUID{}))
+	gb, err = dbc.ListAccountGroupBalances(false)
+	require.NoError(t, err)
+	assert.Equal(t, Money(500), gb[1].Balances[AccountTypeCategory])
+	assert.Len(t, gb[2].Accounts, 3)
+}
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Known values of the ImportStatus enum
+const (
+	// ImportStatusCreated marks a transaction which was newly created
+	ImportStatusCreated ImportStatus = "created"
+	// ImportStatusDuplicate marks a transaction which was already
+	// imported before and therefore skipped
+	ImportStatusDuplicate ImportStatus = "duplicate"
+	// ImportStatusMatched marks a transaction which was matched to an
+	// existing, manually entered transaction
+	ImportStatusMatched ImportStatus = "matched"
+)
+
+var errDryRun = errors.New("dry-run")
+
+type (
+	// ImportCategories maps placeholder IDs used as Category of imported
+	// transactions and their splits to the names of categories to
+	// create during the import
+	ImportCategories map[uuid.UUID]string
+
+	// ImportResult contains the outcome of an ImportTransactions call
+	ImportResult struct {
+		Created      int                   `json:"created"`
+		Matched      int                   `json:"matched"`
+		Skipped      int                   `json:"skipped"`
+		Transactions []ImportedTransaction `json:"transactions"`
+	}
+
+	// ImportStatus describes what happened to a transaction on import
+	ImportStatus string
+
+	// ImportTransfers maps the index of an imported transaction to the
+	// account the money was transferred from / to
+	ImportTransfers map[int]uuid.UUID
+
+	// ImportedTransaction wraps a Transaction given to the import and
+	// adds its status
+	ImportedTransaction struct {
+		Transaction
+		Status ImportStatus `json:"status"`
+	}
+)
+
+// ImportTransactions stores the given transactions in the given
+// account inside one database transaction:
+//
+//   - Transactions having an ImportID already known for that account
+//     (including deleted ones and IDs replaced by a later import) are
+//     skipped.
+//   - Transactions matching a manually entered transaction without
+//     ImportID (same amount, same day) are not created but the existing
+//     transaction gets the ImportID assigned and is marked cleared if
+//     the imported transaction is.
+//     The same applies to cleared transactions matching an uncleared
+//     one (i.e. a pending entry imported before) whose previous
+//     ImportID is kept as TransactionImportID.
+//   - Categories given as placeholders are created and the
+//     placeholders replaced with their IDs.
+//   - Transactions for budget accounts without category are assigned
+//     to the UnallocatedMoney category.
+//   - Transactions listed in the transfers are created together with
+//     their counterpart in the other account sharing a PairKey. The
+//     counterpart has no ImportID so importing the statement of the
+//     other account later on matches it.
+func (c *Client) ImportTransactions(acc uuid.UUID, txs []Transaction, transfers ImportTransfers, categories ImportCategories) (res ImportResult, err error) {
+	return c.importTransactions(acc, txs, transfers, categories, false)
+}
+
+// PreviewImportTransactions executes the same steps as
+// ImportTransactions but does not store anything
+func (c *Client) PreviewImportTransactions(acc uuid.UUID, txs []Transaction, transfers ImportTransfers, categories ImportCategories) (res ImportResult, err error) {
+	return c.importTransactions(acc, txs, transfers, categories, true)
+}
+
+//nolint:funlen,gocognit // single flow of import steps
+//revive:disable-next-line:flag-parameter // rolls back the same steps
+func (c *Client) importTransactions(acc uuid.UUID, txs []Transaction, transfers ImportTransfers, categories ImportCategories, dryRun bool) (res ImportResult, err error) {
+	account, err := c.GetAccount(acc)
+	if err != nil {
+		return res, fmt.Errorf("fetching account: %w", err)
+	}
+
+	if account.Type == AccountTypeCategory {
+		return res, errors.New("transactions can not be imported into categories")
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		res = ImportResult{}
+
+		// Validation must see the categories created in this transaction
+		tc := &Client{db: db, budget: c.budget}
+
+		txs, counterparts, err := tc.prepareImport(account, txs, transfers, categories)
+		if err != nil {
+			return backoff.NewErrCannotRetry(err)
+		}
+
+		for i := range txs {
+			tx := txs[i]
+
+			if tx.ImportID != "" {
+				var n int64
+				if err := db.
+					Model(&Transaction{}).
+					Unscoped().
+					Where("account = ?", acc).
+					Where(
+						"import_id = ? OR id IN (?)", tx.ImportID,
+						db.Model(&TransactionImportID{}).Select("transaction_id").Where("import_id = ?", tx.ImportID),
+					).
+					Count(&n).
+					Error; err != nil {
+					return fmt.Errorf("checking for duplicate: %w", err)
+				}
+
+				if n > 0 {
+					res.Skipped++
+					res.Transactions = append(res.Transactions, ImportedTransaction{tx, ImportStatusDuplicate})
+					continue
+				}
+			}
+
+			day := time.Date(tx.Time.Year(), tx.Time.Month(), tx.Time.Day(), 0, 0, 0, 0, tx.Time.Location())
+
+			q := db.Where("import_id = ? OR import_id IS NULL", "")
+			if tx.Cleared {
+				q = q.Or("cleared = ?", false)
+			}
+
+			var match Transaction
+			err := db.
+				Where("account = ?", acc).
+				Where(q).
+				Where("amount_cents = ?", tx.Amount).
+				Where("time >= ? AND time < ?", day, day.AddDate(0, 0, 1)).
+				First(&match).
+				Error
+
+			switch {
+			case err == nil:
+				if match.ImportID != "" {
+					// Keep the ID of the pending entry to detect it on
+					// importing the earlier statement again
+					if err = db.Create(&TransactionImportID{TransactionID: match.ID, ImportID: match.ImportID}).Error; err != nil {
+						return fmt.Errorf("keeping previous import id: %w", err)
+					}
+				}
+
+				match.ImportID = tx.ImportID
+				match.Cleared = match.Cleared || tx.Cleared
+
+				if err = db.
+					Model(&Transaction{}).
+					Where("id = ?", match.ID).
+					Updates(map[string]any{"import_id": match.ImportID, "cleared": match.Cleared}).
+					Error; err != nil {
+					return fmt.Errorf("updating matched transaction: %w", err)
+				}
+
+				res.Matched++
+				res.Transactions = append(res.Transactions, ImportedTransaction{match, ImportStatusMatched})
+
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if err = db.Save(&tx).Error; err != nil {
+					return fmt.Errorf("saving transaction: %w", err)
+				}
+
+				if ctx, ok := counterparts[i]; ok {
+					if err = db.Save(&ctx).Error; err != nil {
+						return fmt.Errorf("saving transfer counterpart: %w", err)
+					}
+				}
+
+				res.Created++
+				res.Transactions = append(res.Transactions, ImportedTransaction{tx, ImportStatusCreated})
+
+			default:
+				return fmt.Errorf("searching matching transaction: %w", err)
+			}
+		}
+
+		if dryRun {
+			return backoff.NewErrCannotRetry(errDryRun)
+		}
+
+		return nil
+	}); err != nil && !errors.Is(err, errDryRun) {
+		return res, fmt.Errorf("importing transactions: %w", err)
+	}
+
+	return res, nil
+}
+
+// prepareImport returns a copy of the transactions assigned to the
+// account with the placeholder categories created and replaced, the
+// transfer counterparts and the default category set and validates
+// them
+func (c *Client) prepareImport(acc Account, txs []Transaction, transfers ImportTransfers, categories ImportCategories) ([]Transaction, map[int]Transaction, error) {
+	created := make(map[uuid.UUID]uuid.UUID, len(categories))
+	for placeholder, name := range categories {
+		cat, err := c.CreateAccount(name, AccountTypeCategory)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating category %q: %w", name, err)
+		}
+		created[placeholder] = cat.ID
+	}
+
+	resolve := func(cat *uuid.NullUUID) {
+		if id, ok := created[cat.UUID]; ok && cat.Valid {
+			cat.UUID = id
+		}
+	}
+
+	txs = slices.Clone(txs)
+	counterparts := make(map[int]Transaction, len(transfers))
+	for i := range txs {
+		tx := &txs[i]
+
+		tx.Budget = c.budget.ID
+		tx.Account = uuid.NullUUID{UUID: acc.ID, Valid: true}
+
+		resolve(&tx.Category)
+		tx.Splits = slices.Clone(tx.Splits)
+		for j := range tx.Splits {
+			resolve(&tx.Splits[j].Category)
+		}
+
+		if to, ok := transfers[i]; ok {
+			var err error
+			if counterparts[i], err = c.importTransferCounterpart(acc, tx, to); err != nil {
+				return nil, nil, fmt.Errorf("preparing transfer %d: %w", i, err)
+			}
+		}
+
+		if acc.Type == AccountTypeBudget && !tx.Category.Valid && !tx.PairKey.Valid && len(tx.Splits) == 0 {
+			tx.Category = uuid.NullUUID{UUID: c.budget.UnallocatedMoney, Valid: true}
+		}
+
+		if err := tx.Validate(c); err != nil {
+			return nil, nil, fmt.Errorf("validating transaction %d: %w", i, err)
+		}
+	}
+
+	return txs, counterparts, nil
+}
+
+// importTransferCounterpart turns the given transaction into a
+// transfer to / from the given account and returns its counterpart.
+// The category is kept where money leaves or enters the budget (see
+// TransferMoneyWithCategory) and removed otherwise.
+func (c *Client) importTransferCounterpart(acc Account, tx *Transaction, to uuid.UUID) (ctx Transaction, err error) {
+	toAcc, err := c.GetAccount(to)
+	if err != nil {
+		return ctx, fmt.Errorf("getting transfer account: %w", err)
+	}
+
+	switch {
+	case toAcc.Type == AccountTypeCategory:
+		return ctx, errors.New("transfer to category-type account")
+	case toAcc.ID == acc.ID:
+		return ctx, errors.New("transfer to the same account")
+	case len(tx.Splits) > 0:
+		return ctx, errors.New("transfers can not be split")
+	}
+
+	tx.PairKey = uuid.NullUUID{UUID: uuid.Must(uuid.NewRandom()), Valid: true}
+
+	ctx = Transaction{
+		Time:        tx.Time,
+		Payee:       tx.Payee,
+		Description: tx.Description,
+		Amount:      -tx.Amount,
+		Account:     uuid.NullUUID{UUID: to, Valid: true},
+		PairKey:     tx.PairKey,
+		Budget:      c.budget.ID,
+	}
+
+	switch {
+	case acc.Type == toAcc.Type:
+		tx.Category = uuid.NullUUID{}
+
+	case acc.Type == AccountTypeBudget && !tx.Category.Valid:
+		tx.Category = uuid.NullUUID{UUID: c.budget.UnallocatedMoney, Valid: true}
+
+	case toAcc.Type == AccountTypeBudget:
+		ctx.Category, tx.Category = tx.Category, uuid.NullUUID{}
+		if !ctx.Category.Valid {
+			ctx.Category = uuid.NullUUID{UUID: c.budget.UnallocatedMoney, Valid: true}
+		}
+	}
+
+	if err = ctx.Validate(c); err != nil {
+		return ctx, fmt.Errorf("validating counterpart: %w", err)
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrLastOwner signals the change would leave the budget without an
+// owner
+var ErrLastOwner = errors.New("budget must keep at least one owner")
+
+// GetBudgetRole returns the role of the user in the budget of the
+// client. Admins are owners of all budgets. Users not being a member
+// get a gorm.ErrRecordNotFound.
+func (c *Client) GetBudgetRole(user User) (role BudgetRole, err error) {
+	if user.Admin {
+		return BudgetRoleOwner, nil
+	}
+
+	if err = c.retryRead(func(db *gorm.DB) error {
+		var m BudgetMember
+		if err := db.Scopes(c.inBudget).First(&m, "user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		role = m.Role
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("fetching membership: %w", err)
+	}
+
+	return role, nil
+}
+
+// HasBudgetRoleAnywhere checks whether the user has at least the given
+// role in any budget
+func (c *Client) HasBudgetRoleAnywhere(user User, role BudgetRole) (ok bool, err error) {
+	if user.Admin {
+		return true, nil
+	}
+
+	var roles []BudgetRole
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Model(&BudgetMember{}).Where("user_id = ?", user.ID).Distinct().Pluck("role", &roles).Error
+	}); err != nil {
+		return false, fmt.Errorf("listing roles: %w", err)
+	}
+
+	return slices.ContainsFunc(roles, func(r BudgetRole) bool { return r.Allows(role) }), nil
+}
+
+// ListBudgetMembers returns a list of all members of the budget
+func (c *Client) ListBudgetMembers() (m []BudgetMember, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.
+			Model(&BudgetMember{}).
+			Select("budget_members.*, users.username").
+			Joins("JOIN users ON users.id = budget_members.user_id").
+			Where("budget_members.budget = ?", c.budget.ID).
+			Order("users.username").
+			Find(&m).
+			Error
+	}); err != nil {
+		return m, fmt.Errorf("listing members: %w", err)
+	}
+
+	return m, nil
+}
+
+// ListBudgetsForUser returns a list of all budgets the user has access
+// to
+func (c *Client) ListBudgetsForUser(user User) (b []Budget, err error) {
+	if user.Admin {
+		return c.ListBudgets()
+	}
+
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.
+			Where("id IN (?)", db.Model(&BudgetMember{}).Select("budget").Where("user_id = ?", user.ID)).
+			Order("name").
+			Find(&b).
+			Error
+	}); err != nil {
+		return b, fmt.Errorf("listing budgets: %w", err)
+	}
+
+	return b, nil
+}
+
+// RemoveBudgetMember revokes the access of the user to the budget
+func (c *Client) RemoveBudgetMember(user uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		if err := c.ensureOtherOwner(db, user); err != nil {
+			return err
+		}
+
+		return db.Unscoped().Scopes(c.inBudget).Delete(&BudgetMember{}, "user_id = ?", user).Error
+	}); err != nil {
+		return fmt.Errorf("removing member: %w", err)
+	}
+
+	return nil
+}
+
+// SetBudgetMember grants the user the given role in the budget or
+// changes the role of an existing member
+func (c *Client) SetBudgetMember(user uuid.UUID, role BudgetRole) (err error) {
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role %q", role)
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		if err := db.First(&User{}, "id = ?", user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return backoff.NewErrCannotRetry(fmt.Errorf("fetching user: %w", err))
+			}
+			return fmt.Errorf("fetching user: %w", err)
+		}
+
+		if role != BudgetRoleOwner {
+			if err := c.ensureOtherOwner(db, user); err != nil {
+				return err
+			}
+		}
+
+		return c.setBudgetMember(db, user, role)
+	}); err != nil {
+		return fmt.Errorf("setting member: %w", err)
+	}
+
+	return nil
+}
+
+// ensureOtherOwner refuses changes to the user if they are the last
+// owner of the budget
+func (c *Client) ensureOtherOwner(db *gorm.DB, user uuid.UUID) error {
+	var owners []uuid.UUID
+	if err := db.Model(&BudgetMember{}).Scopes(c.inBudget).
+		Where("role = ?", BudgetRoleOwner).
+		Pluck("user_id", &owners).Error; err != nil {
+		return fmt.Errorf("listing owners: %w", err)
+	}
+
+	if len(owners) == 1 && owners[0] == user {
+		return backoff.NewErrCannotRetry(ErrLastOwner)
+	}
+
+	return nil
+}
+
+func (c *Client) setBudgetMember(db *gorm.DB, user uuid.UUID, role BudgetRole) error {
+	var m BudgetMember
+	err := db.Scopes(c.inBudget).First(&m, "user_id = ?", user).Error
+	switch {
+	case err == nil:
+		m.Role = role
+		return db.Save(&m).Error
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return db.Create(&BudgetMember{Budget: c.budget.ID, User: user, Role: role}).Error
+
+	default:
+		return fmt.Errorf("fetching member: %w", err)
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBudgetMembers(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	admin, err := dbc.CreateUser("admin", "correct horse", true)
+	require.NoError(t, err)
+	alice, err := dbc.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	bob, err := dbc.CreateUser("bob", "correct horse", false)
+	require.NoError(t, err)
+
+	b, err := dbc.CreateBudget("Household", alice.ID)
+	require.NoError(t, err)
+	bc, err := dbc.ForBudget(b.ID)
+	require.NoError(t, err)
+
+	role, err := bc.GetBudgetRole(alice)
+	require.NoError(t, err)
+	assert.Equal(t, BudgetRoleOwner, role)
+
+	role, err = bc.GetBudgetRole(admin)
+	require.NoError(t, err)
+	assert.Equal(t, BudgetRoleOwner, role)
+
+	_, err = bc.GetBudgetRole(bob)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	budgets, err := dbc.ListBudgetsForUser(bob)
+	require.NoError(t, err)
+	assert.Empty(t, budgets)
+
+	require.NoError(t, bc.SetBudgetMember(bob.ID, BudgetRoleViewer))
+	assert.Error(t, bc.SetBudgetMember(bob.ID, "superuser"))
+	assert.Error(t, bc.SetBudgetMember(uuid.New(), BudgetRoleViewer))
+
+	role, err = bc.GetBudgetRole(bob)
+	require.NoError(t, err)
+	assert.Equal(t, BudgetRoleViewer, role)
+
+	ok, err := dbc.HasBudgetRoleAnywhere(bob, BudgetRoleEditor)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = dbc.HasBudgetRoleAnywhere(alice, BudgetRoleEditor)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	budgets, err = dbc.ListBudgetsForUser(bob)
+	require.NoError(t, err)
+	require.Len(t, budgets, 1)
+	assert.Equal(t, b.ID, budgets[0].ID)
+
+	members, err := bc.ListBudgetMembers()
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "alice", members[0].Username)
+	assert.Equal(t, "bob", members[1].Username)
+
+	// The last owner must stay
+	assert.ErrorIs(t, bc.SetBudgetMember(alice.ID, BudgetRoleEditor), ErrLastOwner)
+	assert.ErrorIs(t, bc.RemoveBudgetMember(alice.ID), ErrLastOwner)
+
+	require.NoError(t, bc.SetBudgetMember(bob.ID, BudgetRoleOwner))
+	require.NoError(t, bc.RemoveBudgetMember(alice.ID))
+
+	_, err = bc.GetBudgetRole(alice)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Deleting the user must not leave the budget without owner
+	assert.ErrorIs(t, dbc.DeleteUser(bob.ID), ErrLastOwner)
+	require.NoError(t, bc.SetBudgetMember(alice.ID, BudgetRoleOwner))
+	require.NoError(t, dbc.DeleteUser(bob.ID))
+
+	members, err = bc.ListBudgetMembers()
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "alice", members[0].Username)
+
+	assert.True(t, BudgetRoleOwner.Allows(BudgetRoleEditor))
+	assert.True(t, BudgetRoleEditor.Allows(BudgetRoleEditor))
+	assert.False(t, BudgetRoleViewer.Allows(BudgetRoleEditor))
+	assert.False(t, BudgetRole("").Allows(BudgetRoleViewer))
+}
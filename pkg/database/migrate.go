@@ -0,0 +1,385 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// migrationLockID is an arbitrary number identifying the advisory
+	// lock taken in postgres while migrating
+	migrationLockID = 7_202_401
+
+	createMigrationsTable = "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)"
+)
+
+var (
+	// ErrNoMigrationApplied signals there is nothing to roll back
+	ErrNoMigrationApplied = errors.New("no migration applied")
+
+	//go:embed migrations
+	migrationFS embed.FS
+
+	migrationFilePattern = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+	// migrationLocks contain the statements creating the
+	// schema_migrations table and taking an exclusive lock on it for the
+	// current transaction per dialect to prevent multiple instances
+	// from migrating at once
+	migrationLocks = map[string][]string{
+		// The advisory lock does not need the table and must be taken
+		// first: concurrent CREATE TABLE IF NOT EXISTS statements fail
+		"postgres": {
+			fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", migrationLockID),
+			createMigrationsTable,
+		},
+		// Writing to the table takes the database write-lock, creating
+		// it before is safe as SQLite serializes writers anyway
+		"sqlite": {
+			createMigrationsTable,
+			"DELETE FROM schema_migrations WHERE 1 = 0",
+		},
+	}
+)
+
+type (
+	// Migration describes one version of the database schema
+	Migration struct {
+		Version   int        `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"appliedAt"`
+
+		up, down string
+	}
+
+	schemaMigration struct {
+		Version   int `gorm:"primaryKey;autoIncrement:false"`
+		Name      string
+		AppliedAt time.Time
+	}
+)
+
+// TableName implements the gorm.Tabler interface
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// ListMigrations returns all known migrations in order together with
+// the time they were applied to the database
+func (c *Client) ListMigrations() (migrations []Migration, err error) {
+	if err = c.withMigrationLock(func(_ *gorm.DB, m []Migration) error {
+		migrations = m
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("listing migrations: %w", err)
+	}
+
+	return migrations, nil
+}
+
+// MigrateDown reverts the latest applied migration
+func (c *Client) MigrateDown() (m Migration, err error) {
+	if err = c.withMigrationLock(func(db *gorm.DB, migrations []Migration) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if migrations[i].AppliedAt == nil {
+				continue
+			}
+
+			m = migrations[i]
+			if err := execMigration(db, m.down); err != nil {
+				return fmt.Errorf("reverting migration %d: %w", m.Version, err)
+			}
+
+			if err := db.Delete(&schemaMigration{Version: m.Version}).Error; err != nil {
+				return fmt.Errorf("removing migration %d: %w", m.Version, err)
+			}
+
+			m.AppliedAt = nil
+			return nil
+		}
+
+		return backoff.NewErrCannotRetry(ErrNoMigrationApplied)
+	}); err != nil {
+		return m, fmt.Errorf("migrating down: %w", err)
+	}
+
+	return m, nil
+}
+
+// MigrateUp applies all pending migrations in order and returns them.
+//
+// Databases created before the introduction of versioned migrations
+// (using AutoMigrate) are brought to the state of the first migration
+// and marked as migrated to it.
+func (c *Client) MigrateUp() (applied []Migration, err error) {
+	if err = c.withMigrationLock(func(db *gorm.DB, migrations []Migration) error {
+		applied = nil
+
+		if len(migrations) > 0 && migrations[0].AppliedAt == nil && hasLegacySchema(db) {
+			if err := migrateLegacySchema(db); err != nil {
+				return fmt.Errorf("adopting legacy schema: %w", err)
+			}
+
+			if err := markMigrationApplied(db, &migrations[0]); err != nil {
+				return err
+			}
+		}
+
+		for i := range migrations {
+			m := &migrations[i]
+			if m.AppliedAt != nil {
+				continue
+			}
+
+			if err := execMigration(db, m.up); err != nil {
+				return fmt.Errorf("applying migration %d: %w", m.Version, err)
+			}
+
+			if err := markMigrationApplied(db, m); err != nil {
+				return err
+			}
+
+			applied = append(applied, *m)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("migrating up: %w", err)
+	}
+
+	return applied, nil
+}
+
+// withMigrationLock executes fn inside a transaction holding the
+// migration lock and passes the known migrations with their state
+func (c *Client) withMigrationLock(fn func(db *gorm.DB, migrations []Migration) error) error {
+	dialect := c.db.Name()
+
+	lock, ok := migrationLocks[dialect]
+	if !ok {
+		return fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+
+	//nolint:wrapcheck // is wrapped in the caller
+	return c.withoutAudit().retryTx(func(db *gorm.DB) error {
+		migrations, err := loadMigrations(dialect)
+		if err != nil {
+			return backoff.NewErrCannotRetry(err)
+		}
+
+		for _, stmt := range lock {
+			if err = db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("locking migrations table: %w", err)
+			}
+		}
+
+		var applied []schemaMigration
+		if err = db.Order("version").Find(&applied).Error; err != nil {
+			return fmt.Errorf("reading applied migrations: %w", err)
+		}
+
+		for _, a := range applied {
+			i := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version >= a.Version })
+			if i == len(migrations) || migrations[i].Version != a.Version {
+				return backoff.NewErrCannotRetry(fmt.Errorf("database has unknown migration %d applied", a.Version))
+			}
+
+			appliedAt := a.AppliedAt
+			migrations[i].AppliedAt = &appliedAt
+		}
+
+		return fn(db, migrations)
+	})
+}
+
+// execMigration executes the statements of one migration file one by
+// one as not all drivers support multiple statements in one call
+func execMigration(db *gorm.DB, sql string) error {
+	for _, stmt := range strings.Split(sql, ";\n") {
+		if stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";"); stmt == "" {
+			continue
+		}
+
+		if err := db.Exec(stmt).Error; err != nil {
+			// Broken SQL won't get better by retrying
+			return backoff.NewErrCannotRetry(err)
+		}
+	}
+
+	return nil
+}
+
+// loadMigrations reads the up- and down-migrations for the given
+// dialect from the embedded migrations directory
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		match := migrationFilePattern.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration filename %q", e.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("parsing version of %q: %w", e.Name(), err)
+		}
+
+		content, err := fs.ReadFile(migrationFS, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading %q: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d is missing up or down statements", m.Version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func markMigrationApplied(db *gorm.DB, m *Migration) error {
+	now := time.Now().UTC()
+
+	if err := db.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: now}).Error; err != nil {
+		return fmt.Errorf("recording migration %d: %w", m.Version, err)
+	}
+
+	m.AppliedAt = &now
+	return nil
+}
+
+// hasLegacySchema checks for tables created by AutoMigrate before
+// the versioned migrations were introduced
+func hasLegacySchema(db *gorm.DB) bool {
+	return db.Migrator().HasTable("accounts") || db.Migrator().HasTable("transactions")
+}
+
+// migrateLegacySchema brings a database created through AutoMigrate
+// to the state of the first versioned migration. The models are frozen
+// in that state as the current ones contain the changes of later
+// migrations.
+func migrateLegacySchema(db *gorm.DB) error {
+	type (
+		account struct {
+			BaseModel
+			Name      string
+			Type      AccountType
+			Hidden    bool
+			Group     uuid.NullUUID `gorm:"column:account_group;type:uuid"`
+			SortOrder int
+		}
+
+		accountGroup struct {
+			BaseModel
+			Name      string
+			SortOrder int
+		}
+
+		scheduledTransaction struct {
+			BaseModel
+			Payee       string
+			Description string
+			Amount      Money         `gorm:"column:amount_cents"`
+			Account     uuid.NullUUID `gorm:"type:uuid"`
+			Category    uuid.NullUUID `gorm:"type:uuid"`
+			Frequency   Frequency
+			Interval    int
+			DayOfMonth  int
+			Start       time.Time
+			End         *time.Time
+			LastDue     *time.Time
+			NextDue     *time.Time `gorm:"index"`
+		}
+
+		transactionSplit struct {
+			BaseModel
+			TransactionID uuid.UUID `gorm:"type:uuid;index"`
+			Description   string
+			Amount        Money         `gorm:"column:amount_cents"`
+			Category      uuid.NullUUID `gorm:"type:uuid"`
+		}
+
+		transaction struct {
+			BaseModel
+			Time        time.Time
+			Payee       string
+			Description string
+			Amount      Money         `gorm:"column:amount_cents"`
+			Account     uuid.NullUUID `gorm:"type:uuid"`
+			Category    uuid.NullUUID `gorm:"type:uuid"`
+			Cleared     bool
+			Reconciled  bool
+			ImportID    string        `gorm:"index"`
+			PairKey     uuid.NullUUID `gorm:"type:uuid"`
+
+			Splits []transactionSplit `gorm:"foreignKey:TransactionID"`
+		}
+	)
+
+	for table, model := range map[string]any{
+		"accounts":               &account{},
+		"account_groups":         &accountGroup{},
+		"category_goals":         &CategoryGoal{},
+		"import_profiles":        &ImportProfile{},
+		"scheduled_transactions": &scheduledTransaction{},
+		"transactions":           &transaction{},
+		"transaction_splits":     &transactionSplit{},
+	} {
+		if err := db.Table(table).AutoMigrate(model); err != nil {
+			return fmt.Errorf("migrating %s schema: %w", table, err)
+		}
+	}
+
+	if err := migrateAmountsToCents(db); err != nil {
+		return fmt.Errorf("migrating amounts: %w", err)
+	}
+
+	for _, a := range migrateCreateAccounts {
+		if err := db.Table("accounts").Save(&account{
+			BaseModel: BaseModel{ID: a.ID},
+			Name:      a.Name,
+			Type:      a.Type,
+			Hidden:    a.Hidden,
+		}).Error; err != nil {
+			return fmt.Errorf("ensuring default account %q: %w", a.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	sqliteMigrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	require.NotEmpty(t, sqliteMigrations)
+
+	postgresMigrations, err := loadMigrations("postgres")
+	require.NoError(t, err)
+
+	// Both dialects must provide the same versions
+	require.Len(t, postgresMigrations, len(sqliteMigrations))
+	for i := range sqliteMigrations {
+		assert.Equal(t, sqliteMigrations[i].Version, postgresMigrations[i].Version)
+		assert.Equal(t, sqliteMigrations[i].Name, postgresMigrations[i].Name)
+
+		if i > 0 {
+			assert.Greater(t, sqliteMigrations[i].Version, sqliteMigrations[i-1].Version)
+		}
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	dbc, err := Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	migrations, err := dbc.ListMigrations()
+	require.NoError(t, err)
+	for _, m := range migrations {
+		assert.Nil(t, m.AppliedAt)
+	}
+
+	applied, err := dbc.MigrateUp()
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+
+	// Default accounts are created by the migrations
+	acc, err := dbc.GetAccount(UnallocatedMoney)
+	require.NoError(t, err)
+	assert.Equal(t, "Unallocated Money", acc.Name)
+	acc, err = dbc.GetAccount(StartingBalance)
+	require.NoError(t, err)
+	assert.True(t, acc.Hidden)
+
+	// Nothing left to do
+	applied, err = dbc.MigrateUp()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	for range migrations {
+		_, err = dbc.MigrateDown()
+		require.NoError(t, err)
+	}
+
+	assert.False(t, dbc.db.Migrator().HasTable(&Account{}))
+	_, err = dbc.MigrateDown()
+	assert.ErrorIs(t, err, ErrNoMigrationApplied)
+
+	applied, err = dbc.MigrateUp()
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	var (
+		errs = make([]error, 3) //revive:disable-line:add-constant // number of instances
+		wg   sync.WaitGroup
+	)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = New("sqlite", dsn)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	dbc, err := Open("sqlite", dsn)
+	require.NoError(t, err)
+
+	migrations, err := dbc.ListMigrations()
+	require.NoError(t, err)
+	for _, m := range migrations {
+		assert.NotNil(t, m.AppliedAt)
+	}
+}
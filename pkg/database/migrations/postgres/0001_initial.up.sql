@@ -0,0 +1,28 @@
+CREATE TABLE "accounts" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"name" text,"type" text,"hidden" boolean,"account_group" uuid,"sort_order" bigint,PRIMARY KEY ("id"));
+CREATE INDEX "idx_accounts_deleted_at" ON "accounts"("deleted_at");
+
+CREATE TABLE "account_groups" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"name" text,"sort_order" bigint,PRIMARY KEY ("id"));
+CREATE INDEX "idx_account_groups_deleted_at" ON "account_groups"("deleted_at");
+
+CREATE TABLE "category_goals" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"category" uuid,"type" text,"amount_cents" bigint,"target_date" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_category_goals_category" ON "category_goals"("category");
+CREATE INDEX "idx_category_goals_deleted_at" ON "category_goals"("deleted_at");
+
+CREATE TABLE "import_profiles" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"name" text,"encoding" text,"delimiter" text,"skip_rows" bigint,"date_format" text,"decimal_separator" text,"thousands_separator" text,"time_column" bigint,"payee_column" bigint,"description_column" bigint,"amount_column" bigint,"debit_column" bigint,"credit_column" bigint,PRIMARY KEY ("id"));
+CREATE INDEX "idx_import_profiles_deleted_at" ON "import_profiles"("deleted_at");
+
+CREATE TABLE "scheduled_transactions" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"payee" text,"description" text,"amount_cents" bigint,"account" uuid,"category" uuid,"frequency" text,"interval" bigint,"day_of_month" bigint,"start" timestamptz,"end" timestamptz,"last_due" timestamptz,"next_due" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX "idx_scheduled_transactions_next_due" ON "scheduled_transactions"("next_due");
+CREATE INDEX "idx_scheduled_transactions_deleted_at" ON "scheduled_transactions"("deleted_at");
+
+CREATE TABLE "transactions" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"time" timestamptz,"payee" text,"description" text,"amount_cents" bigint,"account" uuid,"category" uuid,"cleared" boolean,"reconciled" boolean,"import_id" text,"pair_key" uuid,PRIMARY KEY ("id"));
+CREATE INDEX "idx_transactions_import_id" ON "transactions"("import_id");
+CREATE INDEX "idx_transactions_deleted_at" ON "transactions"("deleted_at");
+
+CREATE TABLE "transaction_splits" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"transaction_id" uuid,"description" text,"amount_cents" bigint,"category" uuid,PRIMARY KEY ("id"),CONSTRAINT "fk_transactions_splits" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id"));
+CREATE INDEX "idx_transaction_splits_transaction_id" ON "transaction_splits"("transaction_id");
+CREATE INDEX "idx_transaction_splits_deleted_at" ON "transaction_splits"("deleted_at");
+
+INSERT INTO "accounts" ("id","created_at","updated_at","name","type","hidden","sort_order") VALUES
+  ('00000000-0000-0000-0000-000000000001',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'Unallocated Money','category',false,0),
+  ('00000000-0000-0000-0000-000000000002',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'Starting Balance','category',true,0);
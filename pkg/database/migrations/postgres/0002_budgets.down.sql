@@ -0,0 +1,15 @@
+-- Data of all budgets is merged: their categories stay as regular
+-- categories in the single remaining budget
+DROP INDEX "idx_transactions_budget";
+ALTER TABLE "transactions" DROP COLUMN "budget";
+
+DROP INDEX "idx_scheduled_transactions_budget";
+ALTER TABLE "scheduled_transactions" DROP COLUMN "budget";
+
+DROP INDEX "idx_account_groups_budget";
+ALTER TABLE "account_groups" DROP COLUMN "budget";
+
+DROP INDEX "idx_accounts_budget";
+ALTER TABLE "accounts" DROP COLUMN "budget";
+
+DROP TABLE "budgets";
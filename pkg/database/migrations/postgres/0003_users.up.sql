@@ -0,0 +1,13 @@
+CREATE TABLE "users" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"username" text,"password_hash" text,"admin" boolean,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_users_username" ON "users"("username");
+CREATE INDEX "idx_users_deleted_at" ON "users"("deleted_at");
+
+CREATE TABLE "sessions" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" uuid,"token_hash" text,"expires_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX "idx_sessions_user_id" ON "sessions"("user_id");
+CREATE UNIQUE INDEX "idx_sessions_token_hash" ON "sessions"("token_hash");
+CREATE INDEX "idx_sessions_deleted_at" ON "sessions"("deleted_at");
+
+CREATE TABLE "api_tokens" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" uuid,"name" text,"token_hash" text,"last_used" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX "idx_api_tokens_user_id" ON "api_tokens"("user_id");
+CREATE UNIQUE INDEX "idx_api_tokens_token_hash" ON "api_tokens"("token_hash");
+CREATE INDEX "idx_api_tokens_deleted_at" ON "api_tokens"("deleted_at");
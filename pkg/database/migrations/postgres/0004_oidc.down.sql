@@ -0,0 +1,2 @@
+DROP INDEX "idx_users_oidc_subject";
+ALTER TABLE "users" DROP COLUMN "oidc_subject";
@@ -0,0 +1 @@
+DROP TABLE "budget_members";
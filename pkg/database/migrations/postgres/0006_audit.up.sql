@@ -0,0 +1,6 @@
+CREATE TABLE "audit_entries" ("id" uuid,"created_at" timestamptz,"operation" uuid,"budget" uuid,"user_id" uuid,"entity" text,"entity_id" uuid,"action" text,"before" text,"after" text,PRIMARY KEY ("id"));
+CREATE INDEX "idx_audit_entries_created_at" ON "audit_entries"("created_at");
+CREATE INDEX "idx_audit_entries_operation" ON "audit_entries"("operation");
+CREATE INDEX "idx_audit_entries_budget" ON "audit_entries"("budget");
+CREATE INDEX "idx_audit_entries_user_id" ON "audit_entries"("user_id");
+CREATE INDEX "idx_audit_entries_entity" ON "audit_entries"("entity","entity_id");
@@ -0,0 +1,4 @@
+CREATE TABLE "operations" ("id" uuid,"created_at" timestamptz,"budget" uuid,"user_id" uuid,"kind" text,"reverts" uuid,"reverted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX "idx_operations_created_at" ON "operations"("created_at");
+CREATE INDEX "idx_operations_budget" ON "operations"("budget");
+CREATE INDEX "idx_operations_user_id" ON "operations"("user_id");
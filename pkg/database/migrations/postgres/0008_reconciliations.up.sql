@@ -0,0 +1,4 @@
+CREATE TABLE "reconciliations" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"budget" uuid,"account" uuid,"date" timestamptz,"balance_cents" bigint,"adjustment" uuid,"user_id" uuid,PRIMARY KEY ("id"));
+CREATE INDEX "idx_reconciliations_deleted_at" ON "reconciliations"("deleted_at");
+CREATE INDEX "idx_reconciliations_budget" ON "reconciliations"("budget");
+CREATE INDEX "idx_reconciliations_account" ON "reconciliations"("account");
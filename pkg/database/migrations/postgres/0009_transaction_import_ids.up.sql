@@ -0,0 +1,4 @@
+CREATE TABLE "transaction_import_ids" ("id" uuid,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"transaction_id" uuid,"import_id" text,PRIMARY KEY ("id"));
+CREATE INDEX "idx_transaction_import_ids_deleted_at" ON "transaction_import_ids"("deleted_at");
+CREATE INDEX "idx_transaction_import_ids_transaction_id" ON "transaction_import_ids"("transaction_id");
+CREATE INDEX "idx_transaction_import_ids_import_id" ON "transaction_import_ids"("import_id");
@@ -0,0 +1,7 @@
+DROP TABLE `transaction_splits`;
+DROP TABLE `transactions`;
+DROP TABLE `scheduled_transactions`;
+DROP TABLE `import_profiles`;
+DROP TABLE `category_goals`;
+DROP TABLE `account_groups`;
+DROP TABLE `accounts`;
@@ -0,0 +1,28 @@
+CREATE TABLE `accounts` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`type` text,`hidden` numeric,`account_group` uuid,`sort_order` integer,PRIMARY KEY (`id`));
+CREATE INDEX `idx_accounts_deleted_at` ON `accounts`(`deleted_at`);
+
+CREATE TABLE `account_groups` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`sort_order` integer,PRIMARY KEY (`id`));
+CREATE INDEX `idx_account_groups_deleted_at` ON `account_groups`(`deleted_at`);
+
+CREATE TABLE `category_goals` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`category` uuid,`type` text,`amount_cents` integer,`target_date` datetime,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX `idx_category_goals_category` ON `category_goals`(`category`);
+CREATE INDEX `idx_category_goals_deleted_at` ON `category_goals`(`deleted_at`);
+
+CREATE TABLE `import_profiles` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`encoding` text,`delimiter` text,`skip_rows` integer,`date_format` text,`decimal_separator` text,`thousands_separator` text,`time_column` integer,`payee_column` integer,`description_column` integer,`amount_column` integer,`debit_column` integer,`credit_column` integer,PRIMARY KEY (`id`));
+CREATE INDEX `idx_import_profiles_deleted_at` ON `import_profiles`(`deleted_at`);
+
+CREATE TABLE `scheduled_transactions` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`payee` text,`description` text,`amount_cents` integer,`account` uuid,`category` uuid,`frequency` text,`interval` integer,`day_of_month` integer,`start` datetime,`end` datetime,`last_due` datetime,`next_due` datetime,PRIMARY KEY (`id`));
+CREATE INDEX `idx_scheduled_transactions_next_due` ON `scheduled_transactions`(`next_due`);
+CREATE INDEX `idx_scheduled_transactions_deleted_at` ON `scheduled_transactions`(`deleted_at`);
+
+CREATE TABLE `transactions` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`time` datetime,`payee` text,`description` text,`amount_cents` integer,`account` uuid,`category` uuid,`cleared` numeric,`reconciled` numeric,`import_id` text,`pair_key` uuid,PRIMARY KEY (`id`));
+CREATE INDEX `idx_transactions_import_id` ON `transactions`(`import_id`);
+CREATE INDEX `idx_transactions_deleted_at` ON `transactions`(`deleted_at`);
+
+CREATE TABLE `transaction_splits` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`transaction_id` uuid,`description` text,`amount_cents` integer,`category` uuid,PRIMARY KEY (`id`),CONSTRAINT `fk_transactions_splits` FOREIGN KEY (`transaction_id`) REFERENCES `transactions`(`id`));
+CREATE INDEX `idx_transaction_splits_transaction_id` ON `transaction_splits`(`transaction_id`);
+CREATE INDEX `idx_transaction_splits_deleted_at` ON `transaction_splits`(`deleted_at`);
+
+INSERT INTO `accounts` (`id`,`created_at`,`updated_at`,`name`,`type`,`hidden`,`sort_order`) VALUES
+  ('00000000-0000-0000-0000-000000000001',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'Unallocated Money','category',0,0),
+  ('00000000-0000-0000-0000-000000000002',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'Starting Balance','category',1,0);
@@ -0,0 +1,23 @@
+CREATE TABLE `budgets` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`unallocated_money` uuid,`starting_balance` uuid,PRIMARY KEY (`id`));
+CREATE INDEX `idx_budgets_deleted_at` ON `budgets`(`deleted_at`);
+
+-- All existing data is moved into the default budget owning the
+-- default categories
+INSERT INTO `budgets` (`id`,`created_at`,`updated_at`,`name`,`unallocated_money`,`starting_balance`) VALUES
+  ('00000000-0000-0000-0001-000000000001',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'Budget','00000000-0000-0000-0000-000000000001','00000000-0000-0000-0000-000000000002');
+
+ALTER TABLE `accounts` ADD `budget` uuid;
+UPDATE `accounts` SET `budget` = '00000000-0000-0000-0001-000000000001';
+CREATE INDEX `idx_accounts_budget` ON `accounts`(`budget`);
+
+ALTER TABLE `account_groups` ADD `budget` uuid;
+UPDATE `account_groups` SET `budget` = '00000000-0000-0000-0001-000000000001';
+CREATE INDEX `idx_account_groups_budget` ON `account_groups`(`budget`);
+
+ALTER TABLE `scheduled_transactions` ADD `budget` uuid;
+UPDATE `scheduled_transactions` SET `budget` = '00000000-0000-0000-0001-000000000001';
+CREATE INDEX `idx_scheduled_transactions_budget` ON `scheduled_transactions`(`budget`);
+
+ALTER TABLE `transactions` ADD `budget` uuid;
+UPDATE `transactions` SET `budget` = '00000000-0000-0000-0001-000000000001';
+CREATE INDEX `idx_transactions_budget` ON `transactions`(`budget`);
@@ -0,0 +1,5 @@
+-- Existing budgets get no members: admins have access to all budgets
+-- and can invite the other users
+CREATE TABLE `budget_members` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`budget` uuid,`user_id` uuid,`role` text,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX `idx_budget_members_budget_user` ON `budget_members`(`budget`,`user_id`);
+CREATE INDEX `idx_budget_members_deleted_at` ON `budget_members`(`deleted_at`);
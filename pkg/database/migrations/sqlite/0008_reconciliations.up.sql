@@ -0,0 +1,4 @@
+CREATE TABLE `reconciliations` (`id` uuid,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`budget` uuid,`account` uuid,`date` datetime,`balance_cents` integer,`adjustment` uuid,`user_id` uuid,PRIMARY KEY (`id`));
+CREATE INDEX `idx_reconciliations_deleted_at` ON `reconciliations`(`deleted_at`);
+CREATE INDEX `idx_reconciliations_budget` ON `reconciliations`(`budget`);
+CREATE INDEX `idx_reconciliations_account` ON `reconciliations`(`account`);
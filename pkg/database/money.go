@@ -0,0 +1,79 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const centsPerUnit = 100
+
+type (
+	// Money represents an amount of money stored as exact integer
+	// minor units (cents). In JSON it is represented as a decimal
+	// number with two fractional digits to keep the API compatible
+	// with clients expecting float values.
+	Money int64
+)
+
+// ParseMoney parses a decimal representation of an amount (i.e.
+// "12.34", "-5" or "1e3") into Money. Values having more than two
+// fractional digits are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt64(centsPerUnit))
+
+	// Round half away from zero: the remainder is compared to half of
+	// the denominator by doubling it
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if m.Lsh(m.Abs(m), 1).Cmp(r.Denom()) >= 0 {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+
+	return Money(q.Int64()), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// String returns the decimal representation of the amount with two
+// fractional digits (i.e. "-12.34")
+func (m Money) String() string {
+	sign := ""
+	v := uint64(m) //#nosec:G115 // sign is handled below
+	if m < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	return sign + strconv.FormatUint(v/centsPerUnit, 10) + fmt.Sprintf(".%02d", v%centsPerUnit)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (m *Money) UnmarshalJSON(data []byte) (err error) {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if *m, err = ParseMoney(string(bytes.Trim(data, `"`))); err != nil {
+		return fmt.Errorf("parsing amount: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	for in, exp := range map[string]Money{
+		`12.34`:   1234,
+		`-12.34`:  -1234,
+		`0.1`:     10,
+		`-0.05`:   -5,
+		`5`:       500,
+		`1e3`:     100000,
+		`0.005`:   1,
+		`-0.005`:  -1,
+		`0.0049`:  0,
+		`"42.42"`: 4242,
+	} {
+		var m Money
+		require.NoError(t, json.Unmarshal([]byte(in), &m), in)
+		assert.Equal(t, exp, m, in)
+	}
+
+	var m Money
+	require.Error(t, json.Unmarshal([]byte(`"foo"`), &m))
+
+	for in, exp := range map[Money]string{
+		1234:  `12.34`,
+		-1234: `-12.34`,
+		-5:    `-0.05`,
+		0:     `0.00`,
+		100:   `1.00`,
+	} {
+		out, err := json.Marshal(in)
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(out))
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateImportProfile validates and stores a new import profile
+func (c *Client) CreateImportProfile(p ImportProfile) (np ImportProfile, err error) {
+	if err = p.Validate(); err != nil {
+		return p, fmt.Errorf("validating import profile: %w", err)
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Save(&p).Error
+	}); err != nil {
+		return p, fmt.Errorf("creating import profile: %w", err)
+	}
+
+	return p, nil
+}
+
+// DeleteImportProfile deletes an import profile
+func (c *Client) DeleteImportProfile(id uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Delete(&ImportProfile{}, "id = ?", id).Error
+	}); err != nil {
+		return fmt.Errorf("deleting import profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetImportProfile retrieves an ImportProfile using its ID
+func (c *Client) GetImportProfile(id uuid.UUID) (p ImportProfile, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.First(&p, "id = ?", id).Error
+	}); err != nil {
+		return p, fmt.Errorf("fetching import profile: %w", err)
+	}
+
+	return p, nil
+}
+
+// ListImportProfiles returns a list of all import profiles
+func (c *Client) ListImportProfiles() (p []ImportProfile, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Order("name").Find(&p).Error
+	}); err != nil {
+		return p, fmt.Errorf("listing import profiles: %w", err)
+	}
+
+	return p, nil
+}
+
+// UpdateImportProfile overwrites the given import profile
+func (c *Client) UpdateImportProfile(id uuid.UUID, p ImportProfile) (err error) {
+	if err = p.Validate(); err != nil {
+		return fmt.Errorf("validating import profile: %w", err)
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		var old ImportProfile
+		if err := db.First(&old, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return backoff.NewErrCannotRetry(fmt.Errorf("fetching old import profile: %w", err))
+			}
+			return fmt.Errorf("fetching old import profile: %w", err)
+		}
+
+		p.BaseModel = old.BaseModel
+		return db.Save(&p).Error
+	}); err != nil {
+		return fmt.Errorf("updating import profile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const reconcileAdjustmentPayee = "Reconciliation adjustment"
+
+var (
+	// ErrNotReconcilable signals the account is a category which has
+	// no statement to reconcile against
+	ErrNotReconcilable = errors.New("categories cannot be reconciled")
+	// ErrReconcileMismatch signals the statement balance differs from
+	// the cleared balance of the account
+	ErrReconcileMismatch = errors.New("statement balance does not match cleared balance")
+)
+
+// ListReconciliations returns the reconciliations of the account,
+// newest first
+func (c *Client) ListReconciliations(acc uuid.UUID) (r []Reconciliation, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Scopes(c.inBudget).Where("account = ?", acc).Order("date DESC, created_at DESC").Find(&r).Error
+	}); err != nil {
+		return r, fmt.Errorf("listing reconciliations: %w", err)
+	}
+
+	return r, nil
+}
+
+// ReconcileAccount compares the balance of a bank statement at the
+// given day with the balance of the cleared transactions of the
+// account up to the end of that day. If they differ, the
+// reconciliation is refused with ErrReconcileMismatch unless adjust is
+// set: then a "Reconciliation adjustment" transaction books the
+// difference. The cleared transactions are marked reconciled and the
+// Reconciliation is recorded as checkpoint.
+//
+//revive:disable-next-line:flag-parameter // explicit consent to book the difference
+func (c *Client) ReconcileAccount(acc uuid.UUID, date time.Time, balance Money, adjust bool) (r Reconciliation, err error) {
+	a, err := c.GetAccount(acc)
+	if err != nil {
+		return r, err
+	}
+
+	if a.Type == AccountTypeCategory {
+		return r, ErrNotReconcilable
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	until := day.AddDate(0, 0, 1)
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		r = Reconciliation{Budget: c.budget.ID, Account: a.ID, Date: day, Balance: balance}
+		if user, ok := db.Statement.Context.Value(auditKeyUser).(uuid.UUID); ok {
+			r.User = uuid.NullUUID{UUID: user, Valid: true}
+		}
+
+		cleared := func() *gorm.DB {
+			return db.Model(&Transaction{}).
+				Scopes(c.inBudget).
+				Where("account = ?", a.ID).
+				Where("cleared = ?", true).
+				Where("time < ?", until)
+		}
+
+		var v *int64
+		if err := cleared().Select("CAST(SUM(amount_cents) AS BIGINT)").Scan(&v).Error; err != nil {
+			return fmt.Errorf("getting cleared balance: %w", err)
+		}
+
+		var clearedBalance Money
+		if v != nil {
+			clearedBalance = Money(*v)
+		}
+
+		if diff := balance - clearedBalance; diff != 0 {
+			if !adjust {
+				return backoff.NewErrCannotRetry(fmt.Errorf(
+					"%w: statement %s, cleared %s",
+					ErrReconcileMismatch, balance, clearedBalance,
+				))
+			}
+
+			tx := Transaction{
+				Budget:     c.budget.ID,
+				Time:       day,
+				Payee:      reconcileAdjustmentPayee,
+				Amount:     diff,
+				Account:    uuid.NullUUID{UUID: a.ID, Valid: true},
+				Cleared:    true,
+				Reconciled: true,
+			}
+
+			if a.Type == AccountTypeBudget {
+				tx.Category = uuid.NullUUID{UUID: c.budget.UnallocatedMoney, Valid: true}
+			}
+
+			if err := db.Save(&tx).Error; err != nil {
+				return fmt.Errorf("creating adjustment: %w", err)
+			}
+
+			r.Adjustment = uuid.NullUUID{UUID: tx.ID, Valid: true}
+		}
+
+		if err := cleared().Update("reconciled", true).Error; err != nil {
+			return fmt.Errorf("marking transactions reconciled: %w", err)
+		}
+
+		return db.Save(&r).Error
+	}); err != nil {
+		return r, fmt.Errorf("reconciling account: %w", err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileAccount(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	user, err := dbc.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	dbc = dbc.AsUser(user.ID)
+
+	checking, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := dbc.CreateAccount("Groceries", AccountTypeCategory)
+	require.NoError(t, err)
+
+	for _, tx := range []Transaction{
+		{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Payee: "Employer", Amount: 10000, Cleared: true},
+		{Time: time.Date(2024, 1, 31, 18, 0, 0, 0, time.UTC), Payee: "Shop", Amount: -2000, Cleared: true},
+		{Time: time.Date(2024, 1, 31, 20, 0, 0, 0, time.UTC), Payee: "Pending", Amount: -500},
+		{Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Payee: "Later", Amount: -1000, Cleared: true},
+	} {
+		tx.Account = uuid.NullUUID{UUID: checking.ID, Valid: true}
+		tx.Category = uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}
+		_, err = dbc.CreateTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	statementDate := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	_, err = dbc.ReconcileAccount(groceries.ID, statementDate, 0, false)
+	assert.ErrorIs(t, err, ErrNotReconcilable)
+
+	_, err = dbc.ReconcileAccount(checking.ID, statementDate, 7950, false)
+	assert.ErrorIs(t, err, ErrReconcileMismatch)
+
+	r, err := dbc.ReconcileAccount(checking.ID, statementDate, 8000, false)
+	require.NoError(t, err)
+	assert.False(t, r.Adjustment.Valid)
+	assert.Equal(t, uuid.NullUUID{UUID: user.ID, Valid: true}, r.User)
+
+	txs, err := dbc.ListTransactionsByAccount(checking.ID, time.Time{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	for _, tx := range txs {
+		assert.Equal(t, tx.Payee == "Employer" || tx.Payee == "Shop", tx.Reconciled, tx.Payee)
+	}
+
+	// The difference is booked when requested
+	r, err = dbc.ReconcileAccount(checking.ID, statementDate.AddDate(0, 0, 1), 6950, true)
+	require.NoError(t, err)
+	require.True(t, r.Adjustment.Valid)
+
+	adj, err := dbc.GetTransactionByID(r.Adjustment.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "Reconciliation adjustment", adj.Payee)
+	assert.Equal(t, Money(-50), adj.Amount)
+	assert.True(t, adj.Reconciled)
+
+	recs, err := dbc.ListReconciliations(checking.ID)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	assert.Equal(t, Money(6950), recs[0].Balance)
+	assert.Equal(t, Money(8000), recs[1].Balance)
+
+	// Reconciliations follow the account when merging
+	giro, err := dbc.CreateAccount("Giro", AccountTypeBudget)
+	require.NoError(t, err)
+	require.NoError(t, dbc.MergeAccounts(checking.ID, giro.ID))
+
+	recs, err = dbc.ListReconciliations(checking.ID)
+	require.NoError(t, err)
+	assert.Empty(t, recs)
+
+	recs, err = dbc.ListReconciliations(giro.ID)
+	require.NoError(t, err)
+	assert.Len(t, recs, 2)
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const daysPerWeek = 7
+
+type (
+	// ScheduledOccurrence represents an upcoming, not yet created
+	// Transaction of a ScheduledTransaction
+	ScheduledOccurrence struct {
+		Schedule uuid.UUID `json:"schedule"`
+		Transaction
+	}
+)
+
+// CreateScheduledTransaction validates and stores a new scheduled
+// transaction. If no interval is set an interval of 1 is assumed.
+func (c *Client) CreateScheduledTransaction(s ScheduledTransaction) (ns ScheduledTransaction, err error) {
+	if s.Interval == 0 {
+		s.Interval = 1
+	}
+
+	if err = s.Validate(c); err != nil {
+		return s, fmt.Errorf("validating scheduled transaction: %w", err)
+	}
+
+	s.Budget = c.budget.ID
+	s.LastDue = nil
+	s.NextDue = s.nextOccurrence()
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Save(&s).Error
+	}); err != nil {
+		return s, fmt.Errorf("creating scheduled transaction: %w", err)
+	}
+
+	return s, nil
+}
+
+// DeleteScheduledTransaction deletes a scheduled transaction. Already
+// created transactions are kept.
+func (c *Client) DeleteScheduledTransaction(id uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Scopes(c.inBudget).Delete(&ScheduledTransaction{}, "id = ?", id).Error
+	}); err != nil {
+		return fmt.Errorf("deleting scheduled transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetScheduledTransaction retrieves a ScheduledTransaction using its ID
+func (c *Client) GetScheduledTransaction(id uuid.UUID) (s ScheduledTransaction, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Scopes(c.inBudget).First(&s, "id = ?", id).Error
+	}); err != nil {
+		return s, fmt.Errorf("fetching scheduled transaction: %w", err)
+	}
+
+	return s, nil
+}
+
+// ListScheduledTransactions returns a list of all scheduled
+// transactions
+func (c *Client) ListScheduledTransactions() (s []ScheduledTransaction, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Scopes(c.inBudget).Order("next_due").Find(&s).Error
+	}); err != nil {
+		return s, fmt.Errorf("listing scheduled transactions: %w", err)
+	}
+
+	return s, nil
+}
+
+// ListUpcomingTransactions returns the transactions which will be
+// created by the scheduled transactions up to the given time
+func (c *Client) ListUpcomingTransactions(until time.Time) (o []ScheduledOccurrence, err error) {
+	schedules, err := c.ListScheduledTransactions()
+	if err != nil {
+		return nil, fmt.Errorf("listing scheduled transactions: %w", err)
+	}
+
+	for _, s := range schedules {
+		if s.NextDue == nil {
+			continue
+		}
+
+		for _, t := range s.occurrences(*s.NextDue, until) {
+			o = append(o, ScheduledOccurrence{Schedule: s.ID, Transaction: s.transaction(t)})
+		}
+	}
+
+	return o, nil
+}
+
+// MaterializeScheduledTransactions creates the transactions for all
+// occurrences of scheduled transactions due until the given time and
+// returns the number of created transactions. This is done for all
+// budgets regardless of the scope of the client. Schedules which
+// cannot be materialized are logged and skipped to not block the
+// others.
+func (c *Client) MaterializeScheduledTransactions(now time.Time) (n int, err error) {
+	var due []ScheduledTransaction
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Find(&due, "next_due IS NOT NULL AND next_due <= ?", now).Error
+	}); err != nil {
+		return n, fmt.Errorf("listing due scheduled transactions: %w", err)
+	}
+
+	budgets := map[uuid.UUID]*Client{}
+	for _, s := range due {
+		logger := logrus.WithField("schedule", s.ID)
+
+		bc, ok := budgets[s.Budget]
+		if !ok {
+			if bc, err = c.ForBudget(s.Budget); err != nil {
+				logger.WithError(err).Error("getting budget of scheduled transaction")
+				continue
+			}
+			budgets[s.Budget] = bc
+		}
+
+		for s.NextDue != nil && !s.NextDue.After(now) {
+			created, err := bc.materializeOccurrence(&s)
+			if err != nil {
+				logger.WithError(err).Error("materializing scheduled transaction")
+				break
+			}
+
+			if !created {
+				// Another instance advanced the schedule in the meantime
+				break
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// UpdateScheduledTransaction overwrites the given scheduled
+// transaction. Occurrences already created are not created again.
+func (c *Client) UpdateScheduledTransaction(id uuid.UUID, s ScheduledTransaction) (err error) {
+	if s.Interval == 0 {
+		s.Interval = 1
+	}
+
+	if err = s.Validate(c); err != nil {
+		return fmt.Errorf("validating scheduled transaction: %w", err)
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		var old ScheduledTransaction
+		if err := db.Scopes(c.inBudget).First(&old, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return backoff.NewErrCannotRetry(fmt.Errorf("fetching old scheduled transaction: %w", err))
+			}
+			return fmt.Errorf("fetching old scheduled transaction: %w", err)
+		}
+
+		s.BaseModel = old.BaseModel
+		s.Budget = old.Budget
+		s.LastDue = old.LastDue
+		s.NextDue = s.nextOccurrence()
+
+		return db.Save(&s).Error
+	}); err != nil {
+		return fmt.Errorf("updating scheduled transaction: %w", err)
+	}
+
+	return nil
+}
+
+// nextOccurrence returns the first occurrence after LastDue or nil if
+// there is none left before the End of the schedule
+func (s ScheduledTransaction) nextOccurrence() *time.Time {
+	from := s.Start
+	if s.LastDue != nil {
+		from = s.LastDue.Add(time.Nanosecond)
+	}
+
+	for n := 0; ; n++ {
+		t := s.occurrence(n)
+		if s.End != nil && t.After(*s.End) {
+			return nil
+		}
+
+		if !t.Before(from) {
+			return &t
+		}
+	}
+}
+
+// occurrence calculates the n-th occurrence of the schedule without
+// taking Start or End into account
+func (s ScheduledTransaction) occurrence(n int) time.Time {
+	step := n * s.Interval
+
+	switch s.Frequency {
+	case FrequencyDaily:
+		return s.Start.AddDate(0, 0, step)
+
+	case FrequencyWeekly:
+		return s.Start.AddDate(0, 0, daysPerWeek*step)
+
+	case FrequencyMonthly:
+		day := s.DayOfMonth
+		if day == 0 {
+			day = s.Start.Day()
+		}
+		return clampedDate(s.Start.Year(), s.Start.Month()+time.Month(step), day, s.Start)
+
+	case FrequencyYearly:
+		return clampedDate(s.Start.Year()+step, s.Start.Month(), s.Start.Day(), s.Start)
+
+	default:
+		// Validation prevents unknown frequencies, this is just a
+		// safeguard against endless loops
+		return time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC) //revive:disable-line:add-constant // far future
+	}
+}
+
+// occurrences returns all occurrences between from and until
+// (including both) respecting Start and End of the schedule
+func (s ScheduledTransaction) occurrences(from, until time.Time) (ts []time.Time) {
+	for n := 0; ; n++ {
+		t := s.occurrence(n)
+		if t.After(until) || (s.End != nil && t.After(*s.End)) {
+			return ts
+		}
+
+		if t.Before(from) || t.Before(s.Start) {
+			continue
+		}
+
+		ts = append(ts, t)
+	}
+}
+
+// materializeOccurrence creates the transaction for the next due
+// occurrence of the schedule and advances it in one database
+// transaction. The schedule is only advanced if it still is due at the
+// same time: otherwise created is false and nothing is changed.
+func (c *Client) materializeOccurrence(s *ScheduledTransaction) (created bool, err error) {
+	tx := s.transaction(*s.NextDue)
+	if err = tx.Validate(c); err != nil {
+		return false, fmt.Errorf("validating transaction: %w", err)
+	}
+	tx.Budget = c.budget.ID
+
+	advanced := *s
+	advanced.LastDue = s.NextDue
+	advanced.NextDue = advanced.nextOccurrence()
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		res := db.
+			Model(&ScheduledTransaction{}).
+			Where("id = ? AND next_due = ?", s.ID, *s.NextDue).
+			Updates(map[string]any{"last_due": advanced.LastDue, "next_due": advanced.NextDue})
+		if res.Error != nil {
+			return fmt.Errorf("updating schedule: %w", res.Error)
+		}
+
+		if created = res.RowsAffected > 0; !created {
+			return nil
+		}
+
+		return db.Save(&tx).Error
+	}); err != nil {
+		return false, fmt.Errorf("creating transaction: %w", err)
+	}
+
+	if created {
+		*s = advanced
+	}
+
+	return created, nil
+}
+
+// transaction creates the Transaction for an occurrence at the given
+// time
+func (s ScheduledTransaction) transaction(t time.Time) Transaction {
+	return Transaction{
+		Time:        t,
+		Payee:       s.Payee,
+		Description: s.Description,
+		Amount:      s.Amount,
+		Account:     s.Account,
+		Category:    s.Category,
+	}
+}
+
+// clampedDate creates a date with the time of day taken from tod and
+// moves days exceeding the month to the last day of that month
+func clampedDate(year int, month time.Month, day int, tod time.Time) time.Time {
+	first := time.Date(year, month, 1, tod.Hour(), tod.Minute(), tod.Second(), tod.Nanosecond(), tod.Location())
+	return first.AddDate(0, 0, min(day, first.AddDate(0, 1, -1).Day())-1)
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledOccurrences(t *testing.T) {
+	start := time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		s   ScheduledTransaction
+		exp []time.Time
+	}{
+		"daily": {
+			s: ScheduledTransaction{Frequency: FrequencyDaily, Interval: 30, Start: start, End: &end},
+			exp: []time.Time{
+				start,
+				time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 31, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		"every second week": {
+			s: ScheduledTransaction{Frequency: FrequencyWeekly, Interval: 2, Start: start, End: &end},
+			exp: []time.Time{
+				start,
+				time.Date(2024, 2, 14, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 2, 28, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 27, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 10, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 24, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		"monthly clamped": {
+			s: ScheduledTransaction{Frequency: FrequencyMonthly, Interval: 1, Start: start, End: &end},
+			exp: []time.Time{
+				start,
+				time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 31, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		"monthly on day": {
+			s: ScheduledTransaction{Frequency: FrequencyMonthly, Interval: 1, DayOfMonth: 15, Start: start, End: &end},
+			exp: []time.Time{
+				time.Date(2024, 2, 15, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 15, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 15, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		"yearly": {
+			s: ScheduledTransaction{Frequency: FrequencyYearly, Interval: 1, Start: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+			exp: []time.Time{
+				time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	} {
+		assert.Equal(t, tc.exp, tc.s.occurrences(time.Time{}, time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)), name)
+	}
+}
+
+func TestMaterializeScheduledTransactions(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	tb, err := dbc.CreateAccount("test", AccountTypeBudget)
+	require.NoError(t, err)
+
+	var (
+		start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		end   = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	)
+
+	// Invalid schedules are rejected
+	_, err = dbc.CreateScheduledTransaction(ScheduledTransaction{
+		Payee:     "Landlord",
+		Amount:    -500,
+		Account:   uuid.NullUUID{UUID: tb.ID, Valid: true},
+		Category:  uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+		Frequency: Frequency("hourly"),
+		Start:     start,
+	})
+	require.Error(t, err)
+
+	s, err := dbc.CreateScheduledTransaction(ScheduledTransaction{
+		Payee:     "Landlord",
+		Amount:    -500,
+		Account:   uuid.NullUUID{UUID: tb.ID, Valid: true},
+		Category:  uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+		Frequency: FrequencyMonthly,
+		Start:     start,
+		End:       &end,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.Interval)
+	require.NotNil(t, s.NextDue)
+	assert.True(t, start.Equal(*s.NextDue))
+
+	upcoming, err := dbc.ListUpcomingTransactions(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Len(t, upcoming, 3)
+
+	// Create the first two months
+	n, err := dbc.MaterializeScheduledTransactions(time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Nothing new due
+	n, err = dbc.MaterializeScheduledTransactions(time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Schedule ends after the third month
+	n, err = dbc.MaterializeScheduledTransactions(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	s, err = dbc.GetScheduledTransaction(s.ID)
+	require.NoError(t, err)
+	assert.Nil(t, s.NextDue)
+
+	bals, err := dbc.ListAccountBalances(false)
+	require.NoError(t, err)
+	testCheckAcctBal(t, bals, tb.ID, -1500)
+
+	require.NoError(t, dbc.DeleteScheduledTransaction(s.ID))
+	_, err = dbc.GetScheduledTransaction(s.ID)
+	require.Error(t, err)
+}
+
+func TestMaterializeScheduledTransactionsSkipsFailures(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	closed, err := dbc.CreateAccount("Closed", AccountTypeBudget)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var schedules []ScheduledTransaction
+	for _, acc := range []uuid.UUID{closed.ID, checking.ID} {
+		s, err := dbc.CreateScheduledTransaction(ScheduledTransaction{
+			Payee:     "Landlord",
+			Amount:    -500,
+			Account:   uuid.NullUUID{UUID: acc, Valid: true},
+			Category:  uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+			Frequency: FrequencyMonthly,
+			Start:     start,
+		})
+		require.NoError(t, err)
+		schedules = append(schedules, s)
+	}
+
+	// The account of the first schedule vanishes behind its back
+	require.NoError(t, dbc.db.Delete(&Account{}, "id = ?", closed.ID).Error)
+
+	n, err := dbc.MaterializeScheduledTransactions(time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	bal, err := dbc.GetAccountBalance(checking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(-1000), bal.Balance)
+
+	broken, err := dbc.GetScheduledTransaction(schedules[0].ID)
+	require.NoError(t, err)
+	assert.Nil(t, broken.LastDue)
+
+	// A stale copy of an already advanced schedule does not book twice
+	stale := schedules[1]
+	created, err := dbc.materializeOccurrence(&stale)
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	bal, err = dbc.GetAccountBalance(checking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(-1000), bal.Balance)
+}
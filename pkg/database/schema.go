@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"slices"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"golang.org/x/text/encoding/htmlindex"
 	"gorm.io/gorm"
 )
 
@@ -17,39 +19,307 @@ const (
 	AccountTypeTracking AccountType = "tracking"
 )
 
+// Known values of the AuditAction enum
+const (
+	AuditActionCreate AuditAction = "create"
+	AuditActionDelete AuditAction = "delete"
+	AuditActionUpdate AuditAction = "update"
+)
+
+// Known values of the BudgetRole enum
+const (
+	// BudgetRoleOwner may do everything including managing the members
+	// of the budget and deleting accounts
+	BudgetRoleOwner BudgetRole = "owner"
+	// BudgetRoleEditor may change the data of the budget
+	BudgetRoleEditor BudgetRole = "editor"
+	// BudgetRoleViewer may only read the data of the budget
+	BudgetRoleViewer BudgetRole = "viewer"
+)
+
+// Known values of the OperationKind enum
+const (
+	// OperationKindChange is a regular change made by a user
+	OperationKindChange OperationKind = "change"
+	// OperationKindUndo reverts a change or redo
+	OperationKindUndo OperationKind = "undo"
+	// OperationKindRedo reverts an undo
+	OperationKindRedo OperationKind = "redo"
+)
+
+// Known values of the GoalType enum
+const (
+	// GoalTypeMonthlyFunding requests the Amount to be allocated to
+	// the category every month
+	GoalTypeMonthlyFunding GoalType = "monthlyFunding"
+	// GoalTypeMonthlySpending requests the category to hold the Amount
+	// to be spent in every month
+	GoalTypeMonthlySpending GoalType = "monthlySpending"
+	// GoalTypeTargetBalance requests the category to hold the Amount at
+	// the TargetDate, funded in equal monthly portions
+	GoalTypeTargetBalance GoalType = "targetBalance"
+)
+
+// Known values of the Frequency enum
+const (
+	FrequencyDaily   Frequency = "daily"
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+	FrequencyYearly  Frequency = "yearly"
+)
+
 type (
+	// APIToken represents a personal token of a User to access the API
+	// from scripts. Only the hash of the token is stored.
+	APIToken struct {
+		BaseModel
+		User      uuid.UUID  `gorm:"column:user_id;type:uuid;index" json:"-"`
+		Name      string     `json:"name"`
+		TokenHash string     `gorm:"uniqueIndex" json:"-"`
+		LastUsed  *time.Time `json:"lastUsed"`
+	}
+
+	// AuditAction represents the kind of change of an AuditEntry
+	AuditAction string
+
+	// AuditEntry records the change of one database row. Entries are
+	// never changed or deleted. All entries written in the same
+	// database transaction share the same Operation.
+	AuditEntry struct {
+		ID        uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+		CreatedAt time.Time      `gorm:"index" json:"time"`
+		Operation uuid.UUID      `gorm:"type:uuid;index" json:"operation"`
+		Budget    uuid.NullUUID  `gorm:"type:uuid;index" json:"budget"`
+		User      uuid.NullUUID  `gorm:"column:user_id;type:uuid;index" json:"user"`
+		Entity    string         `gorm:"index:idx_audit_entries_entity" json:"entity"`
+		EntityID  uuid.UUID      `gorm:"type:uuid;index:idx_audit_entries_entity" json:"entityId"`
+		Action    AuditAction    `json:"action"`
+		Before    map[string]any `gorm:"serializer:json" json:"before"`
+		After     map[string]any `gorm:"serializer:json" json:"after"`
+	}
+
 	// Account represents a budget, tracking or category account - in
 	// general something holding money through the sum of transactions
 	Account struct {
 		BaseModel
-		Name   string      `json:"name"`
-		Type   AccountType `json:"type"`
-		Hidden bool        `json:"hidden"`
+		Budget    uuid.UUID     `gorm:"type:uuid;index" json:"-"`
+		Name      string        `json:"name"`
+		Type      AccountType   `json:"type"`
+		Hidden    bool          `json:"hidden"`
+		Group     uuid.NullUUID `gorm:"column:account_group;type:uuid" json:"group"`
+		SortOrder int           `json:"sortOrder"`
 	}
 
 	// AccountBalance wraps an Account and adds the balance
 	AccountBalance struct {
 		Account
-		Balance float64 `json:"balance"`
+		Balance Money       `json:"balance"`
+		Goal    *GoalStatus `json:"goal,omitempty"`
+	}
+
+	// AccountGroup represents a user-defined group of accounts
+	AccountGroup struct {
+		BaseModel
+		Budget    uuid.UUID `gorm:"type:uuid;index" json:"-"`
+		Name      string    `json:"name"`
+		SortOrder int       `json:"sortOrder"`
 	}
 
 	// AccountType represents the type of an account
 	AccountType string
 
+	// Budget represents an independent set of accounts, categories and
+	// transactions having its own Unallocated Money and Starting
+	// Balance categories
+	Budget struct {
+		BaseModel
+		Name             string    `json:"name"`
+		UnallocatedMoney uuid.UUID `gorm:"type:uuid" json:"unallocatedMoney"`
+		StartingBalance  uuid.UUID `gorm:"type:uuid" json:"startingBalance"`
+	}
+
+	// BudgetMember grants a User access to a Budget
+	BudgetMember struct {
+		BaseModel
+		Budget   uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_budget_members_budget_user" json:"-"`
+		User     uuid.UUID  `gorm:"column:user_id;type:uuid;uniqueIndex:idx_budget_members_budget_user" json:"user"`
+		Role     BudgetRole `json:"role"`
+		Username string     `gorm:"->;-:migration" json:"username"`
+	}
+
+	// BudgetRole represents the permissions of a BudgetMember
+	BudgetRole string
+
+	// CategoryGoal represents a funding goal attached to a category
+	CategoryGoal struct {
+		BaseModel
+		Category   uuid.UUID  `gorm:"type:uuid;uniqueIndex" json:"category"`
+		Type       GoalType   `json:"type"`
+		Amount     Money      `gorm:"column:amount_cents" json:"amount"`
+		TargetDate *time.Time `json:"targetDate,omitempty"`
+	}
+
+	// Frequency represents the base unit of a recurrence rule
+	Frequency string
+
+	// GoalType represents the type of a CategoryGoal
+	GoalType string
+
+	// ImportProfile describes the layout of a CSV file exported by a
+	// bank to import its rows as transactions. Columns are counted
+	// starting at 1, a column set to 0 is not present in the file.
+	ImportProfile struct {
+		BaseModel
+		Name string `json:"name"`
+
+		// Encoding is the WHATWG name of the file encoding (i.e.
+		// "utf-8", "iso-8859-15", "windows-1252"), defaults to UTF-8
+		Encoding string `json:"encoding"`
+		// Delimiter is the field delimiter, defaults to ","
+		Delimiter string `json:"delimiter"`
+		// SkipRows is the number of (header) rows to skip
+		SkipRows int `json:"skipRows"`
+
+		// DateFormat is the Go time layout of the time column (i.e.
+		// "02.01.2006"), defaults to "2006-01-02"
+		DateFormat string `json:"dateFormat"`
+		// DecimalSeparator and ThousandsSeparator describe the number
+		// format of the amount columns (i.e. "," and "." for 1.234,56)
+		DecimalSeparator   string `json:"decimalSeparator"`
+		ThousandsSeparator string `json:"thousandsSeparator"`
+
+		TimeColumn        int `json:"timeColumn"`
+		PayeeColumn       int `json:"payeeColumn"`
+		DescriptionColumn int `json:"descriptionColumn"`
+		// AmountColumn contains the signed amount. Alternatively
+		// DebitColumn and CreditColumn can be used for files having
+		// separate columns for money going out and coming in.
+		AmountColumn int `json:"amountColumn"`
+		DebitColumn  int `json:"debitColumn"`
+		CreditColumn int `json:"creditColumn"`
+	}
+
+	// Operation groups the audit entries of one change to a budget
+	// (usually one API request) to be reverted as a whole by Undo and
+	// Redo
+	Operation struct {
+		ID         uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
+		CreatedAt  time.Time     `gorm:"index" json:"time"`
+		Budget     uuid.UUID     `gorm:"type:uuid;index" json:"budget"`
+		User       uuid.NullUUID `gorm:"column:user_id;type:uuid;index" json:"user"`
+		Kind       OperationKind `json:"kind"`
+		Reverts    uuid.NullUUID `gorm:"type:uuid" json:"reverts"`
+		RevertedAt *time.Time    `json:"revertedAt"`
+	}
+
+	// OperationKind represents whether an Operation is a change or the
+	// revert of another Operation
+	OperationKind string
+
+	// Reconciliation records the check of the cleared balance of an
+	// account against the balance of a bank statement at its date. If
+	// both did not match, Adjustment is the transaction booking the
+	// difference.
+	Reconciliation struct {
+		BaseModel
+		Budget     uuid.UUID     `gorm:"type:uuid;index" json:"-"`
+		Account    uuid.UUID     `gorm:"type:uuid;index" json:"account"`
+		Date       time.Time     `json:"date"`
+		Balance    Money         `gorm:"column:balance_cents" json:"balance"`
+		Adjustment uuid.NullUUID `gorm:"type:uuid" json:"adjustment"`
+		User       uuid.NullUUID `gorm:"column:user_id;type:uuid" json:"user"`
+	}
+
+	// ScheduledTransaction represents a template for a Transaction
+	// which is created every time it falls due according to its
+	// recurrence rule
+	ScheduledTransaction struct {
+		BaseModel
+		Budget      uuid.UUID     `gorm:"type:uuid;index" json:"-"`
+		Payee       string        `json:"payee"`
+		Description string        `json:"description"`
+		Amount      Money         `gorm:"column:amount_cents" json:"amount"`
+		Account     uuid.NullUUID `gorm:"type:uuid" json:"account"`
+		Category    uuid.NullUUID `gorm:"type:uuid" json:"category"`
+
+		// Frequency and Interval define the distance between two
+		// occurrences: i.e. weekly with an interval of 2 is every
+		// second week
+		Frequency Frequency `json:"frequency"`
+		Interval  int       `json:"interval"`
+		// DayOfMonth sets the day for monthly schedules, if unset the
+		// day of the start date is used. Days not existing in a month
+		// are moved to the last day of that month.
+		DayOfMonth int        `json:"dayOfMonth"`
+		Start      time.Time  `json:"start"`
+		End        *time.Time `json:"end"`
+		// LastDue is the time of the last occurrence a Transaction was
+		// created for, NextDue the next one to be created (nil if the
+		// schedule has ended)
+		LastDue *time.Time `json:"lastDue"`
+		NextDue *time.Time `gorm:"index" json:"nextDue"`
+	}
+
+	// Session represents a login of a User in the frontend. Only the
+	// hash of the session token is stored.
+	Session struct {
+		BaseModel
+		User      uuid.UUID `gorm:"column:user_id;type:uuid;index"`
+		TokenHash string    `gorm:"uniqueIndex"`
+		ExpiresAt time.Time
+	}
+
 	// Transaction represents some money movement between, from
 	// or to accounts
 	Transaction struct {
 		BaseModel
+		Budget      uuid.UUID     `gorm:"type:uuid;index" json:"-"`
 		Time        time.Time     `json:"time"`
 		Payee       string        `json:"payee"`
 		Description string        `json:"description"`
-		Amount      float64       `json:"amount"`
+		Amount      Money         `gorm:"column:amount_cents" json:"amount"`
 		Account     uuid.NullUUID `gorm:"type:uuid" json:"account"`
 		Category    uuid.NullUUID `gorm:"type:uuid" json:"category"`
 		Cleared     bool          `json:"cleared"`
 		Reconciled  bool          `json:"reconciled"`
 
+		// ImportID is the ID given to the transaction by the bank (i.e.
+		// the OFX FITID) and used to detect already imported transactions
+		ImportID string `gorm:"index" json:"importId,omitempty"`
+
 		PairKey uuid.NullUUID `gorm:"type:uuid" json:"-"`
+
+		Splits []TransactionSplit `gorm:"foreignKey:TransactionID" json:"splits,omitempty"`
+	}
+
+	// TransactionSplit represents a part of a Transaction assigned to
+	// its own category. If a Transaction has splits it must not have a
+	// category itself and the splits must add up to its amount.
+	TransactionSplit struct {
+		BaseModel
+		TransactionID uuid.UUID     `gorm:"type:uuid;index" json:"-"`
+		Description   string        `json:"description"`
+		Amount        Money         `gorm:"column:amount_cents" json:"amount"`
+		Category      uuid.NullUUID `gorm:"type:uuid" json:"category"`
+	}
+
+	// TransactionImportID keeps an ImportID a Transaction had before a
+	// later import (e.g. the booked entry of a pending one) replaced it
+	// so importing the earlier statement again is detected
+	TransactionImportID struct {
+		BaseModel
+		TransactionID uuid.UUID `gorm:"type:uuid;index" json:"-"`
+		ImportID      string    `gorm:"index" json:"importId"`
+	}
+
+	// User represents a person allowed to log in using a password or
+	// through OpenID Connect
+	User struct {
+		BaseModel
+		Username     string  `gorm:"uniqueIndex" json:"username"`
+		PasswordHash string  `json:"-"`
+		Admin        bool    `json:"admin"`
+		OIDCSubject  *string `gorm:"column:oidc_subject;uniqueIndex" json:"-"`
 	}
 
 	// BaseModel is used internally in all other models for common fields
@@ -71,12 +341,146 @@ func (a AccountType) IsValid() bool {
 	}, a)
 }
 
+// Allows checks whether the role grants at least the permissions of
+// the required role
+func (b BudgetRole) Allows(required BudgetRole) bool {
+	roles := []BudgetRole{BudgetRoleViewer, BudgetRoleEditor, BudgetRoleOwner}
+	return b.IsValid() && slices.Index(roles, b) >= slices.Index(roles, required)
+}
+
+// IsValid checks whether the given BudgetRole belongs to the known
+// roles
+func (b BudgetRole) IsValid() bool {
+	return slices.Contains([]BudgetRole{
+		BudgetRoleOwner,
+		BudgetRoleEditor,
+		BudgetRoleViewer,
+	}, b)
+}
+
+// IsValid checks whether the given GoalType belongs to the known
+// types
+func (g GoalType) IsValid() bool {
+	return slices.Contains([]GoalType{
+		GoalTypeMonthlyFunding,
+		GoalTypeMonthlySpending,
+		GoalTypeTargetBalance,
+	}, g)
+}
+
+// IsValid checks whether the given Frequency belongs to the known
+// frequencies
+func (f Frequency) IsValid() bool {
+	return slices.Contains([]Frequency{
+		FrequencyDaily,
+		FrequencyWeekly,
+		FrequencyMonthly,
+		FrequencyYearly,
+	}, f)
+}
+
 // BeforeCreate ensures the object UUID is filled
 func (b *BaseModel) BeforeCreate(*gorm.DB) (err error) {
 	b.ID = uuid.New()
 	return nil
 }
 
+// Validate executes some basic checks on the goal
+func (g CategoryGoal) Validate(c *Client) (err error) {
+	var errs []error
+
+	if !g.Type.IsValid() {
+		errs = append(errs, fmt.Errorf("invalid goal type %q", g.Type))
+	}
+
+	if g.Amount <= 0 {
+		errs = append(errs, fmt.Errorf("amount must be positive"))
+	}
+
+	if g.Type == GoalTypeTargetBalance && g.TargetDate == nil {
+		errs = append(errs, fmt.Errorf("target balance goals need a target date"))
+	}
+
+	cat, err := c.GetAccount(g.Category)
+	if err != nil {
+		return fmt.Errorf("fetching category: %w", err)
+	}
+
+	if cat.Type != AccountTypeCategory {
+		errs = append(errs, fmt.Errorf("goals can only be set on categories"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate executes some basic checks on the import profile
+func (p ImportProfile) Validate() error {
+	var errs []error
+
+	if p.Name == "" {
+		errs = append(errs, fmt.Errorf("name is empty"))
+	}
+
+	if p.Encoding != "" {
+		if _, err := htmlindex.Get(p.Encoding); err != nil {
+			errs = append(errs, fmt.Errorf("unknown encoding %q", p.Encoding))
+		}
+	}
+
+	if utf8.RuneCountInString(p.Delimiter) > 1 {
+		errs = append(errs, fmt.Errorf("delimiter must be a single character"))
+	}
+
+	if p.SkipRows < 0 {
+		errs = append(errs, fmt.Errorf("skip rows must not be negative"))
+	}
+
+	if p.TimeColumn < 1 {
+		errs = append(errs, fmt.Errorf("time column is required"))
+	}
+
+	if (p.AmountColumn > 0) == (p.DebitColumn > 0 || p.CreditColumn > 0) {
+		errs = append(errs, fmt.Errorf("either amount column or debit / credit columns are required"))
+	}
+
+	for _, col := range []int{p.TimeColumn, p.PayeeColumn, p.DescriptionColumn, p.AmountColumn, p.DebitColumn, p.CreditColumn} {
+		if col < 0 {
+			errs = append(errs, fmt.Errorf("columns must not be negative"))
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate executes some basic checks on the scheduled transaction
+// and the transactions it will create
+func (s ScheduledTransaction) Validate(c *Client) (err error) {
+	var errs []error
+
+	if !s.Frequency.IsValid() {
+		errs = append(errs, fmt.Errorf("invalid frequency %q", s.Frequency))
+	}
+
+	if s.Interval < 1 {
+		errs = append(errs, fmt.Errorf("interval must be at least 1"))
+	}
+
+	if s.DayOfMonth < 0 || s.DayOfMonth > 31 {
+		errs = append(errs, fmt.Errorf("day of month out of range"))
+	}
+
+	if s.End != nil && s.End.Before(s.Start) {
+		errs = append(errs, fmt.Errorf("end is before start"))
+	}
+
+	if err = s.transaction(s.Start).Validate(c); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
 // Validate executes some basic checks on the transaction
 //
 //nolint:gocyclo // simple validation rules
@@ -108,7 +512,7 @@ func (t Transaction) Validate(c *Client) (err error) {
 		}
 	}
 
-	if acc.Type == AccountTypeBudget && !t.Category.Valid && !t.PairKey.Valid {
+	if acc.Type == AccountTypeBudget && !t.Category.Valid && !t.PairKey.Valid && len(t.Splits) == 0 {
 		errs = append(errs, fmt.Errorf("budget account transactions need a category"))
 	}
 
@@ -120,5 +524,60 @@ func (t Transaction) Validate(c *Client) (err error) {
 		errs = append(errs, fmt.Errorf("category is not of type category"))
 	}
 
+	if len(t.Splits) > 0 {
+		if err = t.validateSplits(c, acc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateSplits checks the splits of the transaction are valid and
+// add up to the amount of the transaction
+func (t Transaction) validateSplits(c *Client, acc Account) (err error) {
+	var (
+		errs []error
+		sum  Money
+	)
+
+	if t.Category.Valid {
+		errs = append(errs, fmt.Errorf("split transactions must not have a category"))
+	}
+
+	if acc.Type != AccountTypeBudget {
+		errs = append(errs, fmt.Errorf("only budget account transactions can be split"))
+	}
+
+	if t.PairKey.Valid {
+		errs = append(errs, fmt.Errorf("paired transactions can not be split"))
+	}
+
+	for i, s := range t.Splits {
+		sum += s.Amount
+
+		if s.Amount == 0 {
+			errs = append(errs, fmt.Errorf("split %d: amount is zero", i))
+		}
+
+		if !s.Category.Valid {
+			errs = append(errs, fmt.Errorf("split %d: category is null", i))
+			continue
+		}
+
+		cat, err := c.GetAccount(s.Category.UUID)
+		if err != nil {
+			return fmt.Errorf("fetching split %d category: %w", i, err)
+		}
+
+		if cat.Type != AccountTypeCategory {
+			errs = append(errs, fmt.Errorf("split %d: category is not of type category", i))
+		}
+	}
+
+	if sum != t.Amount {
+		errs = append(errs, fmt.Errorf("splits add up to %s instead of %s", sum, t.Amount))
+	}
+
 	return errors.Join(errs...)
 }
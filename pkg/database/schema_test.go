@@ -90,4 +90,46 @@ func TestTransactionValidateErrs(t *testing.T) {
 		Account:     uuid.NullUUID{UUID: actB.ID, Valid: true},
 		Category:    uuid.NullUUID{UUID: invalidAcc, Valid: true}, // ERR: Cat does not exist
 	}.Validate(dbc))
+
+	require.Error(t, Transaction{
+		Time:    time.Now(),
+		Payee:   "test",
+		Amount:  50,
+		Account: uuid.NullUUID{UUID: actB.ID, Valid: true},
+		Splits: []TransactionSplit{
+			{Amount: 20, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+			{Amount: 20, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}}, // ERR: Splits don't add up
+		},
+	}.Validate(dbc))
+
+	require.Error(t, Transaction{
+		Time:     time.Now(),
+		Payee:    "test",
+		Amount:   50,
+		Account:  uuid.NullUUID{UUID: actB.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}, // ERR: Category and splits
+		Splits: []TransactionSplit{
+			{Amount: 50, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		},
+	}.Validate(dbc))
+
+	require.Error(t, Transaction{
+		Time:    time.Now(),
+		Payee:   "test",
+		Amount:  50,
+		Account: uuid.NullUUID{UUID: actT.ID, Valid: true}, // ERR: Split on tracking account
+		Splits: []TransactionSplit{
+			{Amount: 50, Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true}},
+		},
+	}.Validate(dbc))
+
+	require.Error(t, Transaction{
+		Time:    time.Now(),
+		Payee:   "test",
+		Amount:  50,
+		Account: uuid.NullUUID{UUID: actB.ID, Valid: true},
+		Splits: []TransactionSplit{
+			{Amount: 50, Category: uuid.NullUUID{}}, // ERR: Split without category
+		},
+	}.Validate(dbc))
 }
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrDeletedAccount signals a transaction cannot be restored as it
+// references an account which is deleted itself
+var ErrDeletedAccount = errors.New("transaction references a deleted account")
+
+type (
+	// Trash contains the deleted accounts and transactions of a budget
+	Trash struct {
+		Accounts     []TrashedAccount     `json:"accounts"`
+		Transactions []TrashedTransaction `json:"transactions"`
+	}
+
+	// TrashedAccount wraps a deleted Account and exposes the time of
+	// its deletion
+	TrashedAccount struct {
+		Account
+		DeletedAt time.Time `json:"deletedAt"`
+	}
+
+	// TrashedTransaction wraps a deleted Transaction and exposes the
+	// time of its deletion
+	TrashedTransaction struct {
+		Transaction
+		DeletedAt time.Time `json:"deletedAt"`
+	}
+)
+
+// ListTrash returns the deleted accounts and transactions of the
+// budget, most recently deleted first
+func (c *Client) ListTrash() (t Trash, err error) {
+	var (
+		accs []Account
+		txs  []Transaction
+	)
+
+	if err = c.retryRead(func(db *gorm.DB) error {
+		if err := c.inTrash(db).Order("deleted_at DESC").Find(&accs).Error; err != nil {
+			return fmt.Errorf("listing accounts: %w", err)
+		}
+
+		if err := c.inTrash(db).Preload("Splits").Order("deleted_at DESC").Find(&txs).Error; err != nil {
+			return fmt.Errorf("listing transactions: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return t, fmt.Errorf("listing trash: %w", err)
+	}
+
+	t.Accounts = make([]TrashedAccount, 0, len(accs))
+	for _, a := range accs {
+		t.Accounts = append(t.Accounts, TrashedAccount{Account: a, DeletedAt: a.DeletedAt.Time})
+	}
+
+	t.Transactions = make([]TrashedTransaction, 0, len(txs))
+	for _, tx := range txs {
+		t.Transactions = append(t.Transactions, TrashedTransaction{Transaction: tx, DeletedAt: tx.DeletedAt.Time})
+	}
+
+	return t, nil
+}
+
+// PurgeTrash permanently removes the accounts, transactions and
+// scheduled transactions of all budgets deleted before the given time
+// and returns the number of removed records. Accounts still referenced
+// by transactions or scheduled transactions are kept, the
+// reconciliations of removed accounts are removed with them.
+func (c *Client) PurgeTrash(before time.Time) (n int64, err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		n = 0
+
+		purged := db.Unscoped().Model(&Transaction{}).Select("id").Where("deleted_at < ?", before)
+		if err := db.Unscoped().Delete(&TransactionSplit{}, "transaction_id IN (?)", purged).Error; err != nil {
+			return fmt.Errorf("deleting splits: %w", err)
+		}
+
+		if err := db.Unscoped().Delete(&TransactionImportID{}, "transaction_id IN (?)", purged).Error; err != nil {
+			return fmt.Errorf("deleting previous import ids: %w", err)
+		}
+
+		res := db.Unscoped().Delete(&Transaction{}, "deleted_at < ?", before)
+		if res.Error != nil {
+			return fmt.Errorf("deleting transactions: %w", res.Error)
+		}
+		n += res.RowsAffected
+
+		res = db.Unscoped().Delete(&ScheduledTransaction{}, "deleted_at < ?", before)
+		if res.Error != nil {
+			return fmt.Errorf("deleting scheduled transactions: %w", res.Error)
+		}
+		n += res.RowsAffected
+
+		purgeable := db.Unscoped().Model(&Account{}).Select("id").
+			Where("deleted_at < ?", before).
+			Where("id NOT IN (?)", db.Unscoped().Model(&Transaction{}).Select("account").Where("account IS NOT NULL")).
+			Where("id NOT IN (?)", db.Unscoped().Model(&Transaction{}).Select("category").Where("category IS NOT NULL")).
+			Where("id NOT IN (?)", db.Unscoped().Model(&TransactionSplit{}).Select("category").Where("category IS NOT NULL")).
+			Where("id NOT IN (?)", db.Unscoped().Model(&ScheduledTransaction{}).Select("account").Where("account IS NOT NULL")).
+			Where("id NOT IN (?)", db.Unscoped().Model(&ScheduledTransaction{}).Select("category").Where("category IS NOT NULL"))
+
+		if err := db.Unscoped().Delete(&Reconciliation{}, "account IN (?)", purgeable).Error; err != nil {
+			return fmt.Errorf("deleting reconciliations: %w", err)
+		}
+
+		res = db.Unscoped().Where("id IN (?)", purgeable).Delete(&Account{})
+		if res.Error != nil {
+			return fmt.Errorf("deleting accounts: %w", res.Error)
+		}
+		n += res.RowsAffected
+
+		if err := db.Unscoped().
+			Where("category NOT IN (?)", db.Unscoped().Model(&Account{}).Select("id")).
+			Delete(&CategoryGoal{}).Error; err != nil {
+			return fmt.Errorf("deleting goals of deleted categories: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("purging trash: %w", err)
+	}
+
+	return n, nil
+}
+
+// RestoreAccount brings back a deleted account
+func (c *Client) RestoreAccount(id uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		res := c.inTrash(db).Model(&Account{}).Where("id = ?", id).Update("deleted_at", nil)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return backoff.NewErrCannotRetry(gorm.ErrRecordNotFound)
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("restoring account: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreTransaction brings back a deleted transaction. Paired
+// transactions are restored together with their counterpart to keep
+// them in sync.
+func (c *Client) RestoreTransaction(id uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		var tx Transaction
+		if err := c.inTrash(db).Preload("Splits").First(&tx, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return backoff.NewErrCannotRetry(err)
+			}
+			return fmt.Errorf("fetching transaction: %w", err)
+		}
+
+		var txs []Transaction
+		if tx.PairKey.Valid {
+			if err := c.inTrash(db).Preload("Splits").Find(&txs, "pair_key = ?", tx.PairKey.UUID).Error; err != nil {
+				return fmt.Errorf("fetching paired transactions: %w", err)
+			}
+		} else {
+			txs = []Transaction{tx}
+		}
+
+		ids := make([]uuid.UUID, 0, len(txs))
+		for _, t := range txs {
+			if err := c.ensureAccountsExist(db, t.Account, t.Category); err != nil {
+				return err
+			}
+
+			for _, split := range t.Splits {
+				if err := c.ensureAccountsExist(db, split.Category); err != nil {
+					return err
+				}
+			}
+			ids = append(ids, t.ID)
+		}
+
+		return c.inTrash(db).Model(&Transaction{}).Where("id IN ?", ids).Update("deleted_at", nil).Error
+	}); err != nil {
+		return fmt.Errorf("restoring transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ensureAccountsExist checks the given accounts are not deleted
+func (c *Client) ensureAccountsExist(db *gorm.DB, accs ...uuid.NullUUID) error {
+	for _, acc := range accs {
+		if !acc.Valid {
+			continue
+		}
+
+		var n int64
+		if err := db.Model(&Account{}).Scopes(c.inBudget).Where("id = ?", acc.UUID).Count(&n).Error; err != nil {
+			return fmt.Errorf("checking account: %w", err)
+		}
+
+		if n == 0 {
+			return backoff.NewErrCannotRetry(ErrDeletedAccount)
+		}
+	}
+
+	return nil
+}
+
+// inTrash returns a query for the deleted records of the budget
+func (c *Client) inTrash(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Scopes(c.inBudget).Where("deleted_at IS NOT NULL")
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTrash(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := dbc.CreateAccount("Savings", AccountTypeBudget)
+	require.NoError(t, err)
+
+	require.NoError(t, dbc.TransferMoney(checking.ID, savings.ID, 5000, "Savings"))
+
+	paired, err := dbc.ListPairedTransactions(checking.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, paired)
+
+	tx, err := dbc.CreateTransaction(Transaction{
+		Time:     time.Now(),
+		Payee:    "Shop",
+		Amount:   -1234,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, dbc.DeleteTransaction(paired[0].ID))
+	require.NoError(t, dbc.DeleteTransaction(tx.ID))
+
+	trash, err := dbc.ListTrash()
+	require.NoError(t, err)
+	assert.Len(t, trash.Transactions, 3)
+	assert.Empty(t, trash.Accounts)
+	for _, tt := range trash.Transactions {
+		assert.False(t, tt.DeletedAt.IsZero())
+	}
+
+	// Restoring one half brings back both
+	require.NoError(t, dbc.RestoreTransaction(paired[0].ID))
+
+	bal, err := dbc.GetAccountBalance(savings.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(5000), bal.Balance)
+
+	assert.ErrorIs(t, dbc.RestoreTransaction(paired[0].ID), gorm.ErrRecordNotFound)
+
+	trash, err = dbc.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, trash.Transactions, 1)
+	assert.Equal(t, tx.ID, trash.Transactions[0].ID)
+
+	// Only trash older than the given time is purged
+	n, err := dbc.PurgeTrash(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	n, err = dbc.PurgeTrash(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	trash, err = dbc.ListTrash()
+	require.NoError(t, err)
+	assert.Empty(t, trash.Transactions)
+	assert.ErrorIs(t, dbc.RestoreTransaction(tx.ID), gorm.ErrRecordNotFound)
+}
+
+func TestTrashReferences(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	wallet, err := dbc.CreateAccount("Wallet", AccountTypeTracking)
+	require.NoError(t, err)
+	food, err := dbc.CreateAccount("Food", AccountTypeCategory)
+	require.NoError(t, err)
+	household, err := dbc.CreateAccount("Household", AccountTypeCategory)
+	require.NoError(t, err)
+	gym, err := dbc.CreateAccount("Gym", AccountTypeCategory)
+	require.NoError(t, err)
+
+	tx, err := dbc.CreateTransaction(Transaction{
+		Time:    time.Now(),
+		Payee:   "Supermarket",
+		Amount:  -1500,
+		Account: uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Splits: []TransactionSplit{
+			{Amount: -1000, Category: uuid.NullUUID{UUID: food.ID, Valid: true}},
+			{Amount: -500, Category: uuid.NullUUID{UUID: household.ID, Valid: true}},
+		},
+	})
+	require.NoError(t, err)
+
+	// Split categories must exist to restore the transaction
+	require.NoError(t, dbc.DeleteTransaction(tx.ID))
+	require.NoError(t, dbc.DeleteAccount(household.ID, uuid.NullUUID{}))
+	assert.ErrorIs(t, dbc.RestoreTransaction(tx.ID), ErrDeletedAccount)
+
+	require.NoError(t, dbc.RestoreAccount(household.ID))
+	require.NoError(t, dbc.RestoreTransaction(tx.ID))
+
+	// Reconciliations are purged with their account, scheduled
+	// transactions keep the account until purged themselves
+	_, err = dbc.ReconcileAccount(wallet.ID, time.Now(), 0, false)
+	require.NoError(t, err)
+	require.NoError(t, dbc.DeleteAccount(wallet.ID, uuid.NullUUID{}))
+
+	// Finished schedule without occurrences
+	end := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	s, err := dbc.CreateScheduledTransaction(ScheduledTransaction{
+		Payee:      "Gym",
+		Amount:     -3000,
+		Account:    uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category:   uuid.NullUUID{UUID: gym.ID, Valid: true},
+		Frequency:  FrequencyMonthly,
+		DayOfMonth: 20,
+		Start:      end,
+		End:        &end,
+	})
+	require.NoError(t, err)
+	require.Nil(t, s.NextDue)
+	require.NoError(t, dbc.DeleteAccount(gym.ID, uuid.NullUUID{}))
+
+	n, err := dbc.PurgeTrash(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	recs, err := dbc.ListReconciliations(wallet.ID)
+	require.NoError(t, err)
+	assert.Empty(t, recs)
+
+	trash, err := dbc.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, trash.Accounts, 1)
+	assert.Equal(t, gym.ID, trash.Accounts[0].ID)
+
+	require.NoError(t, dbc.DeleteScheduledTransaction(s.ID))
+	n, err = dbc.PurgeTrash(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
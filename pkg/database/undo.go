@@ -0,0 +1,273 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrNothingToRedo signals there is no undone Operation of the user
+	// left to redo or a later change discarded them
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrNothingToUndo signals there is no Operation of the user left
+	// to undo
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrUndoConflict signals the records of the Operation were changed
+	// afterwards and reverting it would discard those changes
+	ErrUndoConflict = errors.New("records were changed by a later operation")
+)
+
+// revertModels contains the models of the tables whose changes can be
+// reverted, keyed by their table name
+var revertModels = map[string]func() any{
+	"account_groups":         newModel[AccountGroup],
+	"accounts":               newModel[Account],
+	"budget_members":         newModel[BudgetMember],
+	"budgets":                newModel[Budget],
+	"category_goals":         newModel[CategoryGoal],
+	"reconciliations":        newModel[Reconciliation],
+	"scheduled_transactions": newModel[ScheduledTransaction],
+	"transaction_import_ids": newModel[TransactionImportID],
+	"transaction_splits":     newModel[TransactionSplit],
+	"transactions":           newModel[Transaction],
+}
+
+// Redo reverts the latest Undo of the user in the budget of the client
+// and returns the Operation reverted. Redo is only possible as long as
+// the user did not make another change after the Undo.
+func (c *Client) Redo() (op Operation, err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		err := c.latestOperation(db).Where("kind = ?", OperationKindUndo).First(&op).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return backoff.NewErrCannotRetry(ErrNothingToRedo)
+		}
+		if err != nil {
+			return fmt.Errorf("fetching operation: %w", err)
+		}
+
+		var changes int64
+		if err = c.userOperations(db).
+			Where("kind = ?", OperationKindChange).
+			Where("created_at > ?", op.CreatedAt).
+			Count(&changes).Error; err != nil {
+			return fmt.Errorf("counting later changes: %w", err)
+		}
+
+		if changes > 0 {
+			return backoff.NewErrCannotRetry(ErrNothingToRedo)
+		}
+
+		return c.revertOperation(db, &op, OperationKindRedo)
+	}); err != nil {
+		return op, fmt.Errorf("reverting undo: %w", err)
+	}
+
+	return op, nil
+}
+
+// Undo reverts the latest change (or Redo) of the user in the budget
+// of the client and returns the Operation reverted
+func (c *Client) Undo() (op Operation, err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		err := c.latestOperation(db).
+			Where("kind IN ?", []OperationKind{OperationKindChange, OperationKindRedo}).
+			First(&op).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return backoff.NewErrCannotRetry(ErrNothingToUndo)
+		}
+		if err != nil {
+			return fmt.Errorf("fetching operation: %w", err)
+		}
+
+		return c.revertOperation(db, &op, OperationKindUndo)
+	}); err != nil {
+		return op, fmt.Errorf("reverting operation: %w", err)
+	}
+
+	return op, nil
+}
+
+// latestOperation returns a query for the newest not yet reverted
+// Operation of the user
+func (c *Client) latestOperation(db *gorm.DB) *gorm.DB {
+	return c.userOperations(db).Where("reverted_at IS NULL").Order("created_at DESC")
+}
+
+// revertOperation restores the state before the Operation for all
+// records it changed, newest change first, and marks it reverted. The
+// changes are recorded as a new Operation of the given kind.
+func (c *Client) revertOperation(db *gorm.DB, op *Operation, kind OperationKind) error {
+	db = withContextValue(db, auditKeyOperationKind, kind)
+	db = withContextValue(db, auditKeyReverts, op.ID)
+
+	var entries []AuditEntry
+	if err := db.Where("operation = ?", op.ID).Order("created_at DESC").Find(&entries).Error; err != nil {
+		return fmt.Errorf("listing audit entries: %w", err)
+	}
+
+	for _, e := range entries {
+		if err := revertAuditEntry(db, e); err != nil {
+			return fmt.Errorf("reverting %s %s: %w", e.Entity, e.EntityID, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := db.Model(&Operation{}).Where("id = ?", op.ID).Update("reverted_at", now).Error; err != nil {
+		return fmt.Errorf("marking operation reverted: %w", err)
+	}
+
+	op.RevertedAt = &now
+	return nil
+}
+
+// userOperations returns a query for the operations of the user in the
+// budget of the client
+func (c *Client) userOperations(db *gorm.DB) *gorm.DB {
+	q := db.Model(&Operation{}).Scopes(c.inBudget)
+
+	if user, ok := c.db.Statement.Context.Value(auditKeyUser).(uuid.UUID); ok {
+		return q.Where("user_id = ?", user)
+	}
+
+	return q.Where("user_id IS NULL")
+}
+
+func newModel[T any]() any { return new(T) }
+
+// revertAuditEntry brings the record of the entry back into the state
+// before the change after making sure it was not changed since
+func revertAuditEntry(db *gorm.DB, e AuditEntry) error {
+	newModelFn, ok := revertModels[e.Entity]
+	if !ok {
+		return backoff.NewErrCannotRetry(fmt.Errorf("changes of %s cannot be reverted", e.Entity))
+	}
+	model := newModelFn()
+
+	rows, err := auditReadRows(db.Session(&gorm.Session{NewDB: true}).Table(e.Entity).Where("id = ?", e.EntityID))
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+
+	current, err := revertNormalize(rows[e.EntityID])
+	if err != nil {
+		return fmt.Errorf("normalizing record: %w", err)
+	}
+
+	if auditChanged(current, e.After) {
+		return backoff.NewErrCannotRetry(ErrUndoConflict)
+	}
+
+	if e.Before == nil {
+		// The record was created by the change
+		if err = db.Unscoped().Delete(model, "id = ?", e.EntityID).Error; err != nil {
+			return fmt.Errorf("deleting record: %w", err)
+		}
+		return nil
+	}
+
+	values, err := revertValues(db, model, e.Before)
+	if err != nil {
+		return fmt.Errorf("converting record: %w", err)
+	}
+
+	if current == nil {
+		// The record was removed from the database by the change
+		if err = db.Model(model).Create(values).Error; err != nil {
+			return fmt.Errorf("creating record: %w", err)
+		}
+		return nil
+	}
+
+	delete(values, "id")
+	if err = db.Unscoped().Model(model).Where("id = ?", e.EntityID).Updates(values).Error; err != nil {
+		return fmt.Errorf("updating record: %w", err)
+	}
+
+	return nil
+}
+
+// revertNormalize converts a row read from the database into the
+// representation stored in the audit log to compare both
+func revertNormalize(row map[string]any) (norm map[string]any, err error) {
+	if row == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("encoding row: %w", err)
+	}
+
+	if err = json.Unmarshal(raw, &norm); err != nil {
+		return nil, fmt.Errorf("decoding row: %w", err)
+	}
+
+	return norm, nil
+}
+
+// revertValues converts the JSON decoded values of a row stored in the
+// audit log back into the types of the fields of the model
+func revertValues(db *gorm.DB, model any, row map[string]any) (map[string]any, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("parsing model: %w", err)
+	}
+
+	values := make(map[string]any, len(row))
+	for col, v := range row {
+		field := stmt.Schema.LookUpField(col)
+		if field == nil || v == nil {
+			values[col] = v
+			continue
+		}
+
+		var err error
+		if values[col], err = revertValue(field, v); err != nil {
+			return nil, fmt.Errorf("converting %s: %w", col, err)
+		}
+	}
+
+	return values, nil
+}
+
+func revertValue(field *schema.Field, v any) (any, error) {
+	t := field.IndirectFieldType
+
+	switch t {
+	case reflect.TypeFor[time.Time](), reflect.TypeFor[gorm.DeletedAt]():
+		if s, ok := v.(string); ok {
+			tv, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("parsing time: %w", err)
+			}
+			return tv, nil
+		}
+	}
+
+	f, ok := v.(float64)
+	if !ok {
+		return v, nil
+	}
+
+	switch t.Kind() { //nolint:exhaustive // Other kinds are passed through
+	case reflect.Bool:
+		// SQLite stores booleans as numbers
+		return f != 0, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int64(f), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint64(f), nil
+	}
+
+	return v, nil
+}
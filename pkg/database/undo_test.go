@@ -0,0 +1,123 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUndoRedo(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	alice, err := dbc.CreateUser("alice", "correct horse", false)
+	require.NoError(t, err)
+	bob, err := dbc.CreateUser("bob", "battery staple", false)
+	require.NoError(t, err)
+
+	adbc := dbc.AsUser(alice.ID)
+	bdbc := dbc.AsUser(bob.ID)
+
+	_, err = adbc.InOperation().Undo()
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	checking, err := adbc.InOperation().CreateAccount("Checking", AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := adbc.InOperation().CreateAccount("Savings", AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := adbc.InOperation().CreateAccount("Groceries", AccountTypeCategory)
+	require.NoError(t, err)
+
+	tx, err := adbc.InOperation().CreateTransaction(Transaction{
+		Time:     time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Payee:    "Shop",
+		Amount:   -1234,
+		Account:  uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category: uuid.NullUUID{UUID: UnallocatedMoney, Valid: true},
+	})
+	require.NoError(t, err)
+
+	// Category changes are restored
+	require.NoError(t, adbc.InOperation().UpdateTransactionCategory(tx.ID, groceries.ID))
+
+	op, err := adbc.InOperation().Undo()
+	require.NoError(t, err)
+	assert.NotNil(t, op.RevertedAt)
+
+	got, err := dbc.GetTransactionByID(tx.ID)
+	require.NoError(t, err)
+	assert.Equal(t, UnallocatedMoney, got.Category.UUID)
+	assert.Equal(t, Money(-1234), got.Amount)
+	assert.True(t, got.Time.Equal(tx.Time))
+
+	_, err = adbc.InOperation().Redo()
+	require.NoError(t, err)
+
+	got, err = dbc.GetTransactionByID(tx.ID)
+	require.NoError(t, err)
+	assert.Equal(t, groceries.ID, got.Category.UUID)
+
+	// Soft-deleted transactions are brought back
+	require.NoError(t, adbc.InOperation().DeleteTransaction(tx.ID))
+	_, err = dbc.GetTransactionByID(tx.ID)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = adbc.InOperation().Undo()
+	require.NoError(t, err)
+	_, err = dbc.GetTransactionByID(tx.ID)
+	require.NoError(t, err)
+
+	// A new change discards the undone operations
+	_, err = adbc.InOperation().Redo()
+	require.NoError(t, err)
+	_, err = adbc.InOperation().Undo()
+	require.NoError(t, err)
+	require.NoError(t, adbc.InOperation().UpdateAccountName(savings.ID, "Rainy Day"))
+	_, err = adbc.InOperation().Redo()
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+
+	// Both halves of a transfer are removed and restored together
+	require.NoError(t, adbc.InOperation().TransferMoney(checking.ID, savings.ID, 5000, "Savings"))
+
+	bal, err := dbc.GetAccountBalance(savings.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(5000), bal.Balance)
+
+	_, err = adbc.InOperation().Undo()
+	require.NoError(t, err)
+
+	bal, err = dbc.GetAccountBalance(savings.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(0), bal.Balance)
+
+	_, err = adbc.InOperation().Redo()
+	require.NoError(t, err)
+
+	bal, err = dbc.GetAccountBalance(savings.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Money(5000), bal.Balance)
+
+	// Changes made by others afterwards are not discarded
+	require.NoError(t, adbc.InOperation().UpdateAccountName(checking.ID, "Giro"))
+	require.NoError(t, bdbc.InOperation().UpdateAccountName(checking.ID, "Main"))
+
+	_, err = adbc.InOperation().Undo()
+	assert.ErrorIs(t, err, ErrUndoConflict)
+
+	acc, err := dbc.GetAccount(checking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Main", acc.Name)
+
+	// Each user undoes their own operations only
+	_, err = bdbc.InOperation().Undo()
+	require.NoError(t, err)
+
+	acc, err = dbc.GetAccount(checking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Giro", acc.Name)
+}
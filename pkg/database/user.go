@@ -0,0 +1,375 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Luzifer/go_helpers/backoff"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiTokenUsageInterval is the minimum time between two updates of
+	// the last usage of an API token to not write on every request
+	apiTokenUsageInterval = time.Minute
+	// minPasswordLength is the minimum number of characters a password
+	// must have
+	minPasswordLength = 8
+	// tokenBytes is the number of random bytes in session and API
+	// tokens
+	tokenBytes = 32
+)
+
+var (
+	// ErrInvalidCredentials signals the given username / password or
+	// token is not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUsernameTaken signals another user already has the username
+	ErrUsernameTaken = errors.New("username is already taken")
+)
+
+// AuthenticateUser checks the given password for the user and returns
+// the user on success
+func (c *Client) AuthenticateUser(username, password string) (u User, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.First(&u, "username = ?", username).Error
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Compare anyway to not leak the existence of the user
+			// through the response time
+			_ = bcrypt.CompareHashAndPassword([]byte("$2a$10$invalidinvalidinvalidinvalidinvalidinvalidinvalidinva"), []byte(password))
+			return u, ErrInvalidCredentials
+		}
+		return u, fmt.Errorf("fetching user: %w", err)
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return u, ErrInvalidCredentials
+	}
+
+	return u, nil
+}
+
+// CreateAPIToken creates a new API token for the user and returns it
+// together with the token itself which is not retrievable later
+func (c *Client) CreateAPIToken(user uuid.UUID, name string) (t APIToken, token string, err error) {
+	if token, err = newToken(); err != nil {
+		return t, "", err
+	}
+
+	t = APIToken{User: user, Name: name, TokenHash: hashToken(token)}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Save(&t).Error
+	}); err != nil {
+		return t, "", fmt.Errorf("creating API token: %w", err)
+	}
+
+	return t, token, nil
+}
+
+// CreateSession creates a new session for the user valid for the
+// given duration and returns its token
+func (c *Client) CreateSession(user uuid.UUID, ttl time.Duration) (token string, err error) {
+	if token, err = newToken(); err != nil {
+		return "", err
+	}
+
+	s := Session{User: user, TokenHash: hashToken(token), ExpiresAt: time.Now().Add(ttl).UTC()}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		// Clean up expired sessions while being at it
+		if err := db.Unscoped().Delete(&Session{}, "expires_at < ?", time.Now().UTC()).Error; err != nil {
+			return fmt.Errorf("removing expired sessions: %w", err)
+		}
+
+		return db.Save(&s).Error
+	}); err != nil {
+		return "", fmt.Errorf("creating session: %w", err)
+	}
+
+	return token, nil
+}
+
+// CreateUser creates a new user with the given password
+//
+//revive:disable-next-line:flag-parameter // not a behavior switch but a property
+func (c *Client) CreateUser(username, password string, admin bool) (u User, err error) {
+	if username == "" {
+		return u, errors.New("empty username")
+	}
+
+	u = User{Username: username, Admin: admin}
+	if u.PasswordHash, err = hashPassword(password); err != nil {
+		return u, err
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		var n int64
+		if err := db.Model(&User{}).Where("username = ?", username).Count(&n).Error; err != nil {
+			return fmt.Errorf("checking username: %w", err)
+		}
+
+		if n > 0 {
+			return backoff.NewErrCannotRetry(fmt.Errorf("%w: %q", ErrUsernameTaken, username))
+		}
+
+		return db.Save(&u).Error
+	}); err != nil {
+		return u, fmt.Errorf("creating user: %w", err)
+	}
+
+	return u, nil
+}
+
+// DeleteAPIToken deletes an API token of the user
+func (c *Client) DeleteAPIToken(user, id uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Unscoped().Delete(&APIToken{}, "id = ? AND user_id = ?", id, user).Error
+	}); err != nil {
+		return fmt.Errorf("deleting API token: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSession ends the session having the given token
+func (c *Client) DeleteSession(token string) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		return db.Unscoped().Delete(&Session{}, "token_hash = ?", hashToken(token)).Error
+	}); err != nil {
+		return fmt.Errorf("deleting session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUser deletes a user together with their sessions, tokens and
+// budget memberships. Users being the last owner of a budget are not
+// deleted (ErrLastOwner).
+func (c *Client) DeleteUser(id uuid.UUID) (err error) {
+	if err = c.retryTx(func(db *gorm.DB) error {
+		var soleOwned int64
+		if err := db.
+			Model(&BudgetMember{}).
+			Select("budget").
+			Where("role = ?", BudgetRoleOwner).
+			Where("budget IN (?)", db.Model(&BudgetMember{}).Select("budget").Where("user_id = ? AND role = ?", id, BudgetRoleOwner)).
+			Group("budget").
+			Having("COUNT(*) = 1").
+			Count(&soleOwned).
+			Error; err != nil {
+			return fmt.Errorf("checking owned budgets: %w", err)
+		}
+
+		if soleOwned > 0 {
+			return backoff.NewErrCannotRetry(ErrLastOwner)
+		}
+
+		for _, model := range []any{&Session{}, &APIToken{}, &BudgetMember{}} {
+			if err := db.Unscoped().Delete(model, "user_id = ?", id).Error; err != nil {
+				return fmt.Errorf("deleting %T: %w", model, err)
+			}
+		}
+
+		return db.Unscoped().Delete(&User{}, "id = ?", id).Error
+	}); err != nil {
+		return fmt.Errorf("deleting user: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPITokenUser returns the user owning the given API token and
+// records the usage of the token (at most once per
+// apiTokenUsageInterval)
+func (c *Client) GetAPITokenUser(token string) (u User, err error) {
+	var t APIToken
+	if err = c.retryRead(func(db *gorm.DB) error {
+		if err := db.First(&t, "token_hash = ?", hashToken(token)).Error; err != nil {
+			return fmt.Errorf("fetching token: %w", err)
+		}
+
+		return db.First(&u, "id = ?", t.User).Error
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return u, ErrInvalidCredentials
+		}
+		return u, fmt.Errorf("getting token user: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if t.LastUsed != nil && now.Sub(*t.LastUsed) < apiTokenUsageInterval {
+		return u, nil
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		// Concurrent requests must not update the token again
+		return db.Model(&APIToken{}).
+			Where("id = ?", t.ID).
+			Where("last_used IS NULL OR last_used < ?", now.Add(-apiTokenUsageInterval)).
+			Update("last_used", now).
+			Error
+	}); err != nil {
+		return u, fmt.Errorf("updating token: %w", err)
+	}
+
+	return u, nil
+}
+
+// GetOrCreateOIDCUser returns the user linked to the given subject
+// of the OpenID Connect provider and creates it with the given
+// username on first login. Existing users are not linked to prevent
+// the provider from taking over local accounts.
+func (c *Client) GetOrCreateOIDCUser(subject, username string) (u User, err error) {
+	if subject == "" || username == "" {
+		return u, errors.New("empty subject or username")
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		err := db.First(&u, "oidc_subject = ?", subject).Error
+		switch {
+		case err == nil:
+			return nil
+
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return fmt.Errorf("fetching user: %w", err)
+		}
+
+		var n int64
+		if err = db.Model(&User{}).Where("username = ?", username).Count(&n).Error; err != nil {
+			return fmt.Errorf("checking username: %w", err)
+		}
+
+		if n > 0 {
+			return backoff.NewErrCannotRetry(fmt.Errorf("%w: %q", ErrUsernameTaken, username))
+		}
+
+		u = User{Username: username, OIDCSubject: &subject}
+		return db.Save(&u).Error
+	}); err != nil {
+		return u, fmt.Errorf("getting OIDC user: %w", err)
+	}
+
+	return u, nil
+}
+
+// GetSessionUser returns the user of the non-expired session having
+// the given token
+func (c *Client) GetSessionUser(token string) (u User, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		var s Session
+		if err := db.First(&s, "token_hash = ? AND expires_at > ?", hashToken(token), time.Now().UTC()).Error; err != nil {
+			return fmt.Errorf("fetching session: %w", err)
+		}
+
+		return db.First(&u, "id = ?", s.User).Error
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return u, ErrInvalidCredentials
+		}
+		return u, fmt.Errorf("getting session user: %w", err)
+	}
+
+	return u, nil
+}
+
+// GetUser retrieves a User using its ID
+func (c *Client) GetUser(id uuid.UUID) (u User, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.First(&u, "id = ?", id).Error
+	}); err != nil {
+		return u, fmt.Errorf("fetching user: %w", err)
+	}
+
+	return u, nil
+}
+
+// GetUserByUsername retrieves a User using its username
+func (c *Client) GetUserByUsername(username string) (u User, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.First(&u, "username = ?", username).Error
+	}); err != nil {
+		return u, fmt.Errorf("fetching user: %w", err)
+	}
+
+	return u, nil
+}
+
+// ListAPITokens returns a list of all API tokens of the user
+func (c *Client) ListAPITokens(user uuid.UUID) (t []APIToken, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Order("name").Find(&t, "user_id = ?", user).Error
+	}); err != nil {
+		return t, fmt.Errorf("listing API tokens: %w", err)
+	}
+
+	return t, nil
+}
+
+// ListUsers returns a list of all users
+func (c *Client) ListUsers() (u []User, err error) {
+	if err = c.retryRead(func(db *gorm.DB) error {
+		return db.Order("username").Find(&u).Error
+	}); err != nil {
+		return u, fmt.Errorf("listing users: %w", err)
+	}
+
+	return u, nil
+}
+
+// UpdateUserPassword sets a new password for the user and ends all
+// their sessions
+func (c *Client) UpdateUserPassword(id uuid.UUID, password string) (err error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err = c.retryTx(func(db *gorm.DB) error {
+		if err := db.Model(&User{}).Where("id = ?", id).Update("password_hash", hash).Error; err != nil {
+			return fmt.Errorf("updating password: %w", err)
+		}
+
+		return db.Unscoped().Delete(&Session{}, "user_id = ?", id).Error
+	}); err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must have at least %d characters", minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// hashToken returns the hash of a session or API token to be stored
+// instead of the token itself
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAuthentication(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	_, err = dbc.CreateUser("admin", "short", true)
+	assert.Error(t, err, "password too short")
+
+	u, err := dbc.CreateUser("admin", "correct horse", true)
+	require.NoError(t, err)
+	assert.True(t, u.Admin)
+	assert.NotEqual(t, "correct horse", u.PasswordHash)
+
+	_, err = dbc.CreateUser("admin", "battery staple", false)
+	assert.Error(t, err, "duplicate username")
+
+	_, err = dbc.AuthenticateUser("admin", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = dbc.AuthenticateUser("nobody", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	au, err := dbc.AuthenticateUser("admin", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, au.ID)
+
+	// Sessions
+	token, err := dbc.CreateSession(u.ID, time.Hour)
+	require.NoError(t, err)
+
+	su, err := dbc.GetSessionUser(token)
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, su.ID)
+
+	_, err = dbc.GetSessionUser("invalid")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	expired, err := dbc.CreateSession(u.ID, -time.Minute)
+	require.NoError(t, err)
+	_, err = dbc.GetSessionUser(expired)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	require.NoError(t, dbc.DeleteSession(token))
+	_, err = dbc.GetSessionUser(token)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// API tokens
+	at, apiToken, err := dbc.CreateAPIToken(u.ID, "script")
+	require.NoError(t, err)
+	assert.Nil(t, at.LastUsed)
+
+	tu, err := dbc.GetAPITokenUser(apiToken)
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, tu.ID)
+
+	tokens, err := dbc.ListAPITokens(u.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	require.NotNil(t, tokens[0].LastUsed)
+	lastUsed := *tokens[0].LastUsed
+
+	// Usage is not written again within the interval
+	_, err = dbc.GetAPITokenUser(apiToken)
+	require.NoError(t, err)
+
+	tokens, err = dbc.ListAPITokens(u.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.True(t, lastUsed.Equal(*tokens[0].LastUsed))
+
+	require.NoError(t, dbc.DeleteAPIToken(u.ID, at.ID))
+	_, err = dbc.GetAPITokenUser(apiToken)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Password change ends all sessions
+	token, err = dbc.CreateSession(u.ID, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, dbc.UpdateUserPassword(u.ID, "battery staple"))
+	_, err = dbc.GetSessionUser(token)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = dbc.AuthenticateUser("admin", "battery staple")
+	require.NoError(t, err)
+}
+
+func TestOIDCUser(t *testing.T) {
+	dbc, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	_, err = dbc.CreateUser("local", "correct horse", false)
+	require.NoError(t, err)
+
+	_, err = dbc.GetOrCreateOIDCUser("sub-1", "local")
+	assert.Error(t, err, "local user must not be taken over")
+
+	u, err := dbc.GetOrCreateOIDCUser("sub-1", "jane")
+	require.NoError(t, err)
+	assert.Equal(t, "jane", u.Username)
+	assert.False(t, u.Admin)
+
+	again, err := dbc.GetOrCreateOIDCUser("sub-1", "jane.doe")
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, again.ID)
+
+	// Users without password cannot log in using the empty password
+	_, err = dbc.AuthenticateUser("jane", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
@@ -0,0 +1,250 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+// DefaultCurrency is the currency used for formats requiring one when
+// no other currency is given
+const DefaultCurrency = "EUR"
+
+var beancountRoots = map[bookRoot]string{
+	bookRootAssets:      "Assets",
+	bookRootBudget:      "Equity:Budget",
+	bookRootEquity:      "Equity",
+	bookRootExpenses:    "Expenses",
+	bookRootIncome:      "Income",
+	bookRootLiabilities: "Liabilities",
+}
+
+type (
+	// beancountDirective is an open or balance directive
+	beancountDirective struct {
+		date    time.Time
+		account string
+		amount  database.Money
+	}
+)
+
+// WriteBeancount writes all transactions in the given time range as a
+// Beancount ledger using the given currency. Accounts are mapped as
+// described in newBook and opened at their creation time (or their
+// first use if that is earlier). Cleared and reconciled transactions
+// are flagged complete ("*"), others incomplete ("!").
+//
+// At the end of each stretch of reconciled transactions of an account
+// a balance assertion is added to verify the reconciled balance. As
+// the ledger does not contain the transactions before since, the
+// assertions are only added when exporting the full history.
+func WriteBeancount(w io.Writer, dbc *database.Client, since, until time.Time, currency string) error {
+	b, err := newBook(dbc, since, until, func(root bookRoot, name string) string {
+		return beancountRoots[root] + ":" + beancountName(name)
+	})
+	if err != nil {
+		return fmt.Errorf("loading book: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "option \"operating_currency\" %s\n\n", beancountString(currency))
+
+	for _, o := range beancountOpenings(b) {
+		fmt.Fprintf(bw, "%s open %s\n", o.date.Format(time.DateOnly), o.account)
+	}
+	fmt.Fprintln(bw)
+
+	for _, e := range b.entries {
+		flag := "*"
+		if e.status == bookStatusNone && !e.paired {
+			flag = "!"
+		}
+
+		fmt.Fprintf(bw, "%s %s %s %s\n",
+			e.time.Local().Format(time.DateOnly), flag,
+			beancountString(e.payee), beancountString(e.note))
+
+		for _, p := range e.postings {
+			mark := ""
+			if e.paired && p.source.Valid && p.status == bookStatusNone {
+				mark = "! "
+			}
+
+			fmt.Fprintf(bw, "  %s%s  %s %s\n", mark, p.account, p.amount, currency)
+		}
+
+		fmt.Fprintln(bw)
+	}
+
+	if since.IsZero() {
+		for _, a := range beancountBalances(b) {
+			fmt.Fprintf(bw, "%s balance %s  %s %s\n", a.date.Format(time.DateOnly), a.account, a.amount, currency)
+		}
+	}
+
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("writing export: %w", err)
+	}
+
+	return nil
+}
+
+// beancountBalances calculates the balance assertions for the end of
+// each stretch of reconciled postings per account. As Beancount checks
+// the balance at the beginning of the given day, the assertion is
+// dated the day after the last reconciled posting and contains all
+// postings up to the end of that day.
+func beancountBalances(b *book) (assertions []beancountDirective) {
+	type posting struct {
+		day        time.Time
+		amount     database.Money
+		reconciled bool
+	}
+
+	var (
+		names    = map[uuid.UUID]string{}
+		order    []uuid.UUID
+		postings = map[uuid.UUID][]posting{}
+	)
+
+	for _, e := range b.entries {
+		day := beancountDay(e.time)
+		for _, p := range e.postings {
+			if !p.source.Valid {
+				continue
+			}
+
+			if _, ok := postings[p.source.UUID]; !ok {
+				order = append(order, p.source.UUID)
+			}
+
+			names[p.source.UUID] = p.account
+			postings[p.source.UUID] = append(postings[p.source.UUID], posting{day, p.amount, p.status == bookStatusReconciled})
+		}
+	}
+
+	for _, acc := range order {
+		ps := postings[acc]
+
+		var (
+			balance database.Money
+			last    time.Time
+		)
+
+		for i, p := range ps {
+			balance += p.amount
+
+			endOfStretch := p.reconciled && (i+1 == len(ps) || !ps[i+1].reconciled)
+			if endOfStretch && !p.day.Equal(last) {
+				// Include the remaining postings of the same day
+				bal := balance
+				for _, n := range ps[i+1:] {
+					if !n.day.Equal(p.day) {
+						break
+					}
+					bal += n.amount
+				}
+
+				assertions = append(assertions, beancountDirective{p.day.AddDate(0, 0, 1), names[acc], bal})
+				last = p.day
+			}
+		}
+	}
+
+	slices.SortStableFunc(assertions, func(x, y beancountDirective) int { return x.date.Compare(y.date) })
+
+	return assertions
+}
+
+// beancountDay returns the date of the given time in the local zone
+func beancountDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// beancountName converts a name into a valid account name component:
+// letters and digits with dashes in between, starting with an
+// uppercase letter or a digit
+func beancountName(name string) string {
+	var parts []string
+	for _, p := range strings.Split(name, ":") {
+		p = strings.Join(strings.FieldsFunc(p, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}), "-")
+
+		if p == "" {
+			continue
+		}
+
+		r := []rune(p)
+		if !unicode.IsLetter(r[0]) && !unicode.IsDigit(r[0]) {
+			p = "X" + p
+		}
+		r = []rune(p)
+		r[0] = unicode.ToUpper(r[0])
+
+		parts = append(parts, string(r))
+	}
+
+	if len(parts) == 0 {
+		return "Unnamed"
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// beancountOpenings returns an open directive for every account of the
+// database and every other account used in the book. They are dated
+// at the creation time of the account or the first use of the account
+// if that is earlier.
+func beancountOpenings(b *book) (openings []beancountDirective) {
+	opened := map[string]time.Time{}
+	open := func(account string, t time.Time) {
+		day := beancountDay(t)
+		if o, ok := opened[account]; !ok || day.Before(o) {
+			opened[account] = day
+		}
+	}
+
+	for _, a := range b.accounts {
+		switch a.Type {
+		case database.AccountTypeCategory:
+			open(b.category(a.ID), a.CreatedAt)
+			open(b.budget(a.ID), a.CreatedAt)
+		default:
+			open(b.account(a.ID), a.CreatedAt)
+		}
+	}
+
+	for _, e := range b.entries {
+		for _, p := range e.postings {
+			open(p.account, e.time)
+		}
+	}
+
+	for account, day := range opened {
+		openings = append(openings, beancountDirective{date: day, account: account})
+	}
+
+	slices.SortFunc(openings, func(x, y beancountDirective) int {
+		if c := x.date.Compare(y.date); c != 0 {
+			return c
+		}
+		return strings.Compare(x.account, y.account)
+	})
+
+	return openings
+}
+
+// beancountString quotes a string value
+func beancountString(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(singleLine(v)) + `"`
+}
@@ -0,0 +1,107 @@
+package exporter
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestBeancountName(t *testing.T) {
+	for in, out := range map[string]string{
+		"Checking":          "Checking",
+		"Credit Card":       "Credit-Card",
+		"income:salary":     "Income:Salary",
+		"Bäckerei & Café":   "Bäckerei-Café",
+		"  ":                "Unnamed",
+		"2024 Tax (refund)": "2024-Tax-refund",
+	} {
+		assert.Equal(t, out, beancountName(in), in)
+	}
+}
+
+func TestWriteBeancount(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", database.AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := dbc.CreateAccount("Groceries", database.AccountTypeCategory)
+	require.NoError(t, err)
+
+	for _, tx := range []database.Transaction{
+		{
+			Time:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+			Payee:      "Starting Balance",
+			Amount:     100000,
+			Category:   uuid.NullUUID{UUID: database.StartingBalance, Valid: true},
+			Cleared:    true,
+			Reconciled: true,
+		},
+		{
+			Time:       time.Date(2024, 1, 5, 0, 0, 0, 0, time.Local),
+			Payee:      `"Super" market`,
+			Amount:     -4250,
+			Category:   uuid.NullUUID{UUID: groceries.ID, Valid: true},
+			Cleared:    true,
+			Reconciled: true,
+		},
+		{
+			// Same day as the end of the first stretch
+			Time:     time.Date(2024, 1, 5, 12, 0, 0, 0, time.Local),
+			Payee:    "Bakery",
+			Amount:   -350,
+			Category: uuid.NullUUID{UUID: groceries.ID, Valid: true},
+		},
+		{
+			Time:       time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local),
+			Payee:      "Supermarket",
+			Amount:     -1000,
+			Category:   uuid.NullUUID{UUID: groceries.ID, Valid: true},
+			Cleared:    true,
+			Reconciled: true,
+		},
+		{
+			Time:     time.Date(2024, 1, 12, 0, 0, 0, 0, time.Local),
+			Payee:    "Supermarket",
+			Amount:   -500,
+			Category: uuid.NullUUID{UUID: groceries.ID, Valid: true},
+		},
+	} {
+		tx.Account = uuid.NullUUID{UUID: checking.ID, Valid: true}
+		_, err = dbc.CreateTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteBeancount(buf, dbc, time.Time{}, time.Now(), "EUR"))
+
+	// Accounts are opened at their first use as that is before their
+	// creation
+	assert.Contains(t, buf.String(), "2024-01-01 open Assets:Checking\n")
+	assert.Contains(t, buf.String(), "2024-01-01 open Equity:Starting-Balance\n")
+	assert.Contains(t, buf.String(), "2024-01-05 open Expenses:Groceries\n")
+	assert.Contains(t, buf.String(), " open Equity:Budget:Groceries\n")
+
+	assert.Contains(t, buf.String(), "2024-01-05 * \"\\\"Super\\\" market\" \"\"\n"+
+		"  Assets:Checking  -42.50 EUR\n"+
+		"  Expenses:Groceries  42.50 EUR\n\n")
+	assert.Contains(t, buf.String(), "2024-01-05 ! \"Bakery\" \"\"\n")
+
+	assert.Contains(t, buf.String(), "2024-01-06 balance Assets:Checking  954.00 EUR\n")
+	assert.Contains(t, buf.String(), "2024-01-11 balance Assets:Checking  944.00 EUR\n")
+	assert.NotContains(t, buf.String(), "2024-01-13 balance")
+
+	// Partial exports lack the opening balance to assert against
+	buf.Reset()
+	require.NoError(t, WriteBeancount(buf, dbc, time.Date(2024, 1, 6, 0, 0, 0, 0, time.Local), time.Now(), "EUR"))
+	assert.Contains(t, buf.String(), "2024-01-10 * \"Supermarket\" \"\"\n")
+	assert.NotContains(t, buf.String(), "2024-01-05")
+	assert.NotContains(t, buf.String(), " balance ")
+}
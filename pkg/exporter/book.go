@@ -0,0 +1,214 @@
+package exporter
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+// Known values of the bookRoot enum
+const (
+	bookRootAssets      bookRoot = "assets"
+	bookRootBudget      bookRoot = "budget"
+	bookRootEquity      bookRoot = "equity"
+	bookRootExpenses    bookRoot = "expenses"
+	bookRootIncome      bookRoot = "income"
+	bookRootLiabilities bookRoot = "liabilities"
+)
+
+const bookUnknownAccount = "unknown"
+
+type (
+	// book contains the transactions converted into double-entry
+	// entries together with the accounts they are booked on
+	book struct {
+		accounts        map[uuid.UUID]database.AccountBalance
+		entries         []bookEntry
+		name            func(bookRoot, string) string
+		startingBalance uuid.UUID
+	}
+
+	// bookEntry is a balanced set of postings. Paired transactions
+	// might have a different state per side so their status is set
+	// per posting instead of per entry.
+	bookEntry struct {
+		time     time.Time
+		status   bookStatus
+		paired   bool
+		payee    string
+		note     string
+		postings []bookPosting
+	}
+
+	bookPosting struct {
+		account string
+		amount  database.Money
+		status  bookStatus
+
+		// source is the budget or tracking account the posting belongs
+		// to (unset for postings on categories)
+		source uuid.NullUUID
+	}
+
+	// bookRoot is the top level of an account in the book
+	bookRoot string
+
+	bookStatus uint8
+)
+
+// Known values of the bookStatus enum
+const (
+	bookStatusNone bookStatus = iota
+	bookStatusCleared
+	bookStatusReconciled
+)
+
+// newBook loads the accounts and transactions in the given time range
+// and converts them into entries sorted by time:
+//
+//   - Budget and tracking accounts are booked on assets, or on
+//     liabilities if their balance is negative.
+//   - Categories used by account transactions are booked on expenses,
+//     money moved between categories is booked on budget. The
+//     StartingBalance category is booked on equity.
+//   - Transfers sharing a PairKey become one entry with two postings.
+//
+// The name function converts the root and the name of the account into
+// the account name used in the export.
+func newBook(dbc *database.Client, since, until time.Time, name func(bookRoot, string) string) (*book, error) {
+	bals, err := dbc.ListAccountBalances(true)
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts: %w", err)
+	}
+
+	b := &book{
+		accounts:        make(map[uuid.UUID]database.AccountBalance, len(bals)),
+		name:            name,
+		startingBalance: dbc.Budget().StartingBalance,
+	}
+	for _, a := range bals {
+		b.accounts[a.ID] = a
+	}
+
+	txs, err := dbc.ListTransactions(since, until)
+	if err != nil {
+		return nil, fmt.Errorf("listing transactions: %w", err)
+	}
+
+	pairs := map[uuid.UUID][]database.Transaction{}
+	for _, tx := range txs {
+		if tx.PairKey.Valid {
+			pairs[tx.PairKey.UUID] = append(pairs[tx.PairKey.UUID], tx)
+		}
+	}
+
+	for _, tx := range txs {
+		pair := pairs[tx.PairKey.UUID]
+
+		switch {
+		case !tx.PairKey.Valid || len(pair) != 2: //revive:disable-line:add-constant // pair of transactions
+			b.entries = append(b.entries, b.entry(tx))
+
+		case pair[0].ID == tx.ID:
+			b.entries = append(b.entries, b.pairEntry(pair[0], pair[1]))
+		}
+	}
+
+	slices.SortStableFunc(b.entries, func(x, y bookEntry) int { return x.time.Compare(y.time) })
+
+	return b, nil
+}
+
+// account returns the name of a budget or tracking account
+func (b *book) account(id uuid.UUID) string {
+	acc := b.accounts[id]
+	if acc.Balance < 0 {
+		return b.name(bookRootLiabilities, acc.Name)
+	}
+	return b.name(bookRootAssets, acc.Name)
+}
+
+// budget returns the name of a category money is moved from or to
+// inside the budget
+func (b *book) budget(id uuid.UUID) string {
+	return b.name(bookRootBudget, b.accounts[id].Name)
+}
+
+// category returns the name of a category money is spent from or
+// earned into
+func (b *book) category(id uuid.UUID) string {
+	if id == b.startingBalance {
+		return b.name(bookRootEquity, b.accounts[id].Name)
+	}
+	return b.name(bookRootExpenses, b.accounts[id].Name)
+}
+
+// entry converts a single transaction into an entry balanced by its
+// category, its splits or an unknown account
+func (b *book) entry(tx database.Transaction) bookEntry {
+	e := bookEntry{time: tx.Time, status: txStatus(tx), payee: tx.Payee, note: tx.Description}
+
+	if !tx.Account.Valid {
+		// Money moved inside the budget without counterpart
+		e.postings = []bookPosting{
+			{account: b.budget(tx.Category.UUID), amount: tx.Amount},
+			{account: b.name(bookRootEquity, bookUnknownAccount), amount: -tx.Amount},
+		}
+		return e
+	}
+
+	e.postings = []bookPosting{{account: b.account(tx.Account.UUID), amount: tx.Amount, status: e.status, source: tx.Account}}
+
+	switch {
+	case len(tx.Splits) > 0:
+		for _, s := range tx.Splits {
+			e.postings = append(e.postings, bookPosting{account: b.category(s.Category.UUID), amount: -s.Amount})
+		}
+
+	case tx.Category.Valid:
+		e.postings = append(e.postings, bookPosting{account: b.category(tx.Category.UUID), amount: -tx.Amount})
+
+	case tx.Amount > 0:
+		e.postings = append(e.postings, bookPosting{account: b.name(bookRootIncome, bookUnknownAccount), amount: -tx.Amount})
+
+	default:
+		e.postings = append(e.postings, bookPosting{account: b.name(bookRootExpenses, bookUnknownAccount), amount: -tx.Amount})
+	}
+
+	return e
+}
+
+// pairEntry combines two paired transactions into one entry
+func (b *book) pairEntry(x, y database.Transaction) bookEntry {
+	e := bookEntry{time: x.Time, paired: true, payee: x.Payee, note: x.Description}
+
+	for _, tx := range []database.Transaction{x, y} {
+		p := bookPosting{amount: tx.Amount}
+		if tx.Account.Valid {
+			p.account = b.account(tx.Account.UUID)
+			p.status = txStatus(tx)
+			p.source = tx.Account
+		} else {
+			p.account = b.budget(tx.Category.UUID)
+		}
+
+		e.postings = append(e.postings, p)
+	}
+
+	return e
+}
+
+func txStatus(tx database.Transaction) bookStatus {
+	switch {
+	case tx.Reconciled:
+		return bookStatusReconciled
+	case tx.Cleared:
+		return bookStatusCleared
+	default:
+		return bookStatusNone
+	}
+}
@@ -0,0 +1,12 @@
+// Package exporter contains writers to export the stored transactions
+// into formats used by other accounting software
+package exporter
+
+import "strings"
+
+// singleLine removes line breaks and repeated spaces from a value as
+// the line based formats use line breaks as field separators and
+// ledger uses two spaces to separate account names from amounts
+func singleLine(v string) string {
+	return strings.Join(strings.Fields(v), " ")
+}
@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+// WriteLedger writes all transactions in the given time range as a
+// ledger-cli / hledger journal. Accounts are mapped as described in
+// newBook, reconciled transactions are marked with "*", cleared ones
+// with "!".
+func WriteLedger(w io.Writer, dbc *database.Client, since, until time.Time) error {
+	b, err := newBook(dbc, since, until, func(root bookRoot, name string) string {
+		return string(root) + ":" + singleLine(name)
+	})
+	if err != nil {
+		return fmt.Errorf("loading book: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, e := range b.entries {
+		fmt.Fprintf(bw, "%s", e.time.Local().Format(time.DateOnly))
+		if m := ledgerMark(e.status); m != "" && !e.paired {
+			fmt.Fprintf(bw, " %s", m)
+		}
+		fmt.Fprintf(bw, " %s", singleLine(e.payee))
+		if e.note != "" {
+			fmt.Fprintf(bw, "  ; %s", singleLine(e.note))
+		}
+		fmt.Fprintln(bw)
+
+		for _, p := range e.postings {
+			mark := ""
+			if m := ledgerMark(p.status); m != "" && e.paired {
+				mark = m + " "
+			}
+
+			fmt.Fprintf(bw, "    %s%s  %s\n", mark, p.account, p.amount)
+		}
+
+		fmt.Fprintln(bw)
+	}
+
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("writing export: %w", err)
+	}
+
+	return nil
+}
+
+func ledgerMark(s bookStatus) string {
+	switch s {
+	case bookStatusReconciled:
+		return "*"
+	case bookStatusCleared:
+		return "!"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestWriteLedger(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", database.AccountTypeBudget)
+	require.NoError(t, err)
+	card, err := dbc.CreateAccount("Credit Card", database.AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := dbc.CreateAccount("Groceries", database.AccountTypeCategory)
+	require.NoError(t, err)
+	household, err := dbc.CreateAccount("Household", database.AccountTypeCategory)
+	require.NoError(t, err)
+
+	for _, tx := range []database.Transaction{
+		{
+			Time:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+			Payee:      "Starting Balance",
+			Amount:     100000,
+			Account:    uuid.NullUUID{UUID: checking.ID, Valid: true},
+			Category:   uuid.NullUUID{UUID: database.StartingBalance, Valid: true},
+			Cleared:    true,
+			Reconciled: true,
+		},
+		{
+			Time:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local),
+			Payee:       "Supermarket",
+			Description: "Weekly\nshopping",
+			Amount:      -12000,
+			Account:     uuid.NullUUID{UUID: card.ID, Valid: true},
+			Cleared:     true,
+			Splits: []database.TransactionSplit{
+				{Amount: -10000, Category: uuid.NullUUID{UUID: groceries.ID, Valid: true}},
+				{Amount: -2000, Category: uuid.NullUUID{UUID: household.ID, Valid: true}},
+			},
+		},
+	} {
+		_, err = dbc.CreateTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, dbc.TransferMoney(database.UnallocatedMoney, groceries.ID, 5000, ""))
+	require.NoError(t, dbc.TransferMoney(checking.ID, card.ID, 2000, "pay off"))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteLedger(buf, dbc, time.Time{}, time.Now()))
+
+	assert.Contains(t, buf.String(), "2024-01-01 * Starting Balance\n"+
+		"    assets:Checking  1000.00\n"+
+		"    equity:Starting Balance  -1000.00\n\n")
+
+	assert.Contains(t, buf.String(), "2024-01-15 ! Supermarket  ; Weekly shopping\n"+
+		"    liabilities:Credit Card  -120.00\n"+
+		"    expenses:Groceries  100.00\n"+
+		"    expenses:Household  20.00\n\n")
+
+	assert.Contains(t, buf.String(), " Transfer: Unallocated Money → Groceries\n"+
+		"    budget:Unallocated Money  -50.00\n"+
+		"    budget:Groceries  50.00\n\n")
+
+	assert.Contains(t, buf.String(), " Transfer: Checking → Credit Card  ; pay off\n"+
+		"    assets:Checking  -20.00\n"+
+		"    liabilities:Credit Card  20.00\n\n")
+}
@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+// WriteQIF writes the transactions of the given account in the given
+// time range as !Type:Bank records. Transfers are written using the
+// [Account] syntax, categories using their name.
+func WriteQIF(w io.Writer, dbc *database.Client, acc uuid.UUID, since, until time.Time) error {
+	account, err := dbc.GetAccount(acc)
+	if err != nil {
+		return fmt.Errorf("getting account: %w", err)
+	}
+
+	if account.Type == database.AccountTypeCategory {
+		return errors.New("categories can not be exported")
+	}
+
+	accounts, err := dbc.ListAccounts(true)
+	if err != nil {
+		return fmt.Errorf("listing accounts: %w", err)
+	}
+
+	names := map[uuid.UUID]string{}
+	for _, a := range accounts {
+		names[a.ID] = a.Name
+	}
+
+	paired, err := dbc.ListPairedTransactions(acc)
+	if err != nil {
+		return fmt.Errorf("listing paired transactions: %w", err)
+	}
+
+	transfers := map[uuid.UUID]string{}
+	for _, p := range paired {
+		if p.Account.Valid {
+			transfers[p.PairKey.UUID] = names[p.Account.UUID]
+		}
+	}
+
+	txs, err := dbc.ListTransactionsByAccount(acc, since, until)
+	if err != nil {
+		return fmt.Errorf("listing transactions: %w", err)
+	}
+
+	slices.SortStableFunc(txs, func(a, b database.Transaction) int { return a.Time.Compare(b.Time) })
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "!Type:Bank")
+
+	for _, tx := range txs {
+		fmt.Fprintf(bw, "D%s\n", tx.Time.Local().Format("01/02/2006"))
+		fmt.Fprintf(bw, "T%s\n", tx.Amount)
+
+		switch {
+		case tx.Reconciled:
+			fmt.Fprintln(bw, "CX")
+		case tx.Cleared:
+			fmt.Fprintln(bw, "C*")
+		}
+
+		if tx.Payee != "" {
+			fmt.Fprintf(bw, "P%s\n", singleLine(tx.Payee))
+		}
+		if tx.Description != "" {
+			fmt.Fprintf(bw, "M%s\n", singleLine(tx.Description))
+		}
+
+		if name, ok := transfers[tx.PairKey.UUID]; tx.PairKey.Valid && ok {
+			fmt.Fprintf(bw, "L[%s]\n", name)
+		} else if tx.Category.Valid {
+			fmt.Fprintf(bw, "L%s\n", names[tx.Category.UUID])
+		}
+
+		for _, s := range tx.Splits {
+			fmt.Fprintf(bw, "S%s\n", names[s.Category.UUID])
+			if s.Description != "" {
+				fmt.Fprintf(bw, "E%s\n", singleLine(s.Description))
+			}
+			fmt.Fprintf(bw, "$%s\n", s.Amount)
+		}
+
+		fmt.Fprintln(bw, "^")
+	}
+
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("writing export: %w", err)
+	}
+
+	return nil
+}
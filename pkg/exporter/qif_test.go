@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+	"git.luzifer.io/luzifer/accounting/pkg/importer"
+)
+
+func TestWriteQIF(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", database.AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := dbc.CreateAccount("Savings", database.AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := dbc.CreateAccount("Groceries", database.AccountTypeCategory)
+	require.NoError(t, err)
+
+	_, err = dbc.CreateTransaction(database.Transaction{
+		Time:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local),
+		Payee:       "Supermarket",
+		Description: "Weekly\nshopping",
+		Amount:      -4250,
+		Account:     uuid.NullUUID{UUID: checking.ID, Valid: true},
+		Category:    uuid.NullUUID{UUID: groceries.ID, Valid: true},
+		Cleared:     true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, dbc.TransferMoney(checking.ID, savings.ID, 10000, "savings"))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteQIF(buf, dbc, checking.ID, time.Time{}, time.Now()))
+
+	assert.Contains(t, buf.String(), "!Type:Bank\nD01/15/2024\nT-42.50\nC*\nPSupermarket\nMWeekly shopping\nLGroceries\n^\n")
+	assert.Contains(t, buf.String(), "T-100.00\nPTransfer: Checking → Savings\nMsavings\nL[Savings]\n^\n")
+
+	// The export must be readable by the importer
+	stmt, err := importer.ParseQIF(buf)
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 2)
+	assert.Equal(t, map[int]string{1: "Savings"}, stmt.Transfers)
+	assert.Equal(t, "Groceries", stmt.Categories[stmt.Transactions[0].Category.UUID])
+}
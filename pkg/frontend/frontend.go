@@ -27,15 +27,25 @@ type (
 var assets embed.FS
 
 // RegisterHandler takes a Router and registers the frontend onto that
-// router
-func RegisterHandler(router *mux.Router, logger *logrus.Logger) {
+// router. All pages except the login are wrapped into the
+// requireLogin middleware, the assets are served without it.
+func RegisterHandler(router *mux.Router, logger *logrus.Logger, requireLogin func(http.Handler) http.Handler) {
 	srv := frontendServer{router, logger}
 
 	router.
 		PathPrefix("/assets").
 		Handler(http.StripPrefix("/assets", http.HandlerFunc(srv.handleAsset))).
 		Methods(http.MethodGet)
-	router.NotFoundHandler = http.HandlerFunc(srv.handleIndex)
+
+	protectedIndex := requireLogin(http.HandlerFunc(srv.handleIndex))
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			srv.handleIndex(w, r)
+			return
+		}
+
+		protectedIndex.ServeHTTP(w, r)
+	})
 }
 
 func (f frontendServer) handleAsset(w http.ResponseWriter, r *http.Request) {
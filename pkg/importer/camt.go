@@ -0,0 +1,254 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+const (
+	camtCredit = "CRDT"
+	camtDebit  = "DBIT"
+
+	camtStatusBooked = "BOOK"
+)
+
+type (
+	// camtDocument covers the parts of camt.052 (BkToCstmrAcctRpt) and
+	// camt.053 (BkToCstmrStmt) documents used for the import. The tags
+	// do not carry a namespace so all versions of the schemas match.
+	camtDocument struct {
+		Reports    []camtStatement `xml:"BkToCstmrAcctRpt>Rpt"`
+		Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+	}
+
+	camtStatement struct {
+		Balances []camtBalance `xml:"Bal"`
+		Entries  []camtEntry   `xml:"Ntry"`
+	}
+
+	camtBalance struct {
+		Type      string   `xml:"Tp>CdOrPrtry>Cd"`
+		Amount    string   `xml:"Amt"`
+		CdtDbtInd string   `xml:"CdtDbtInd"`
+		Date      camtDate `xml:"Dt"`
+	}
+
+	camtDate struct {
+		Date     string `xml:"Dt"`
+		DateTime string `xml:"DtTm"`
+	}
+
+	camtEntry struct {
+		Ref                string                   `xml:"NtryRef"`
+		Amount             string                   `xml:"Amt"`
+		CdtDbtInd          string                   `xml:"CdtDbtInd"`
+		Status             camtCode                 `xml:"Sts"`
+		BookingDate        camtDate                 `xml:"BookgDt"`
+		ValueDate          camtDate                 `xml:"ValDt"`
+		AcctSvcrRef        string                   `xml:"AcctSvcrRef"`
+		AddtlInfo          string                   `xml:"AddtlNtryInf"`
+		TransactionDetails []camtTransactionDetails `xml:"NtryDtls>TxDtls"`
+	}
+
+	// camtCode is either a plain code (up to version 7) or a code
+	// wrapped in a Cd element (version 8 and later)
+	camtCode struct {
+		Text string `xml:",chardata"`
+		Code string `xml:"Cd"`
+	}
+
+	camtTransactionDetails struct {
+		AcctSvcrRef  string    `xml:"Refs>AcctSvcrRef"`
+		Debtor       camtParty `xml:"RltdPties>Dbtr"`
+		Creditor     camtParty `xml:"RltdPties>Cdtr"`
+		Unstructured []string  `xml:"RmtInf>Ustrd"`
+	}
+
+	// camtParty carries the name directly (up to version 7) or inside
+	// a Pty element (version 8 and later)
+	camtParty struct {
+		Name      string `xml:"Nm"`
+		PartyName string `xml:"Pty>Nm"`
+	}
+)
+
+// ParseCAMT reads an ISO 20022 camt.053 bank statement or camt.052
+// account report. Booked entries are marked as cleared, pending ones
+// are imported uncleared. The closing balance (or the interim booked
+// balance for reports) of the latest statement in the document is
+// used as LedgerBalance.
+func ParseCAMT(r io.Reader) (stmt Statement, err error) {
+	var doc camtDocument
+	if err = xml.NewDecoder(r).Decode(&doc); err != nil {
+		return stmt, fmt.Errorf("decoding document: %w", err)
+	}
+
+	stmts := make([]camtStatement, 0, len(doc.Statements)+len(doc.Reports))
+	stmts = append(stmts, doc.Statements...)
+	stmts = append(stmts, doc.Reports...)
+	if len(stmts) == 0 {
+		return stmt, errors.New("document contains no statement")
+	}
+
+	ids := contentIDs{}
+
+	for _, s := range stmts {
+		for _, e := range s.Entries {
+			tx, err := camtTransaction(e, ids)
+			if err != nil {
+				return stmt, fmt.Errorf("parsing entry %q: %w", e.Ref, err)
+			}
+			stmt.Transactions = append(stmt.Transactions, tx)
+		}
+
+		if err = camtLedgerBalance(s, &stmt); err != nil {
+			return stmt, fmt.Errorf("parsing balance: %w", err)
+		}
+	}
+
+	return stmt, nil
+}
+
+func camtLedgerBalance(s camtStatement, stmt *Statement) error {
+	for _, want := range []string{"CLBD", "ITBD"} {
+		for _, b := range s.Balances {
+			if b.Type != want {
+				continue
+			}
+
+			amount, err := camtAmount(b.Amount, b.CdtDbtInd)
+			if err != nil {
+				return err
+			}
+
+			date, err := b.Date.time()
+			if err != nil {
+				return err
+			}
+
+			if stmt.LedgerBalance == nil || !date.Before(stmt.LedgerDate) {
+				stmt.LedgerBalance = &amount
+				stmt.LedgerDate = date
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func camtTransaction(e camtEntry, ids contentIDs) (tx database.Transaction, err error) {
+	if tx.Amount, err = camtAmount(e.Amount, e.CdtDbtInd); err != nil {
+		return tx, err
+	}
+
+	date := e.BookingDate
+	if date.Date == "" && date.DateTime == "" {
+		// Pending entries might not have a booking date yet
+		date = e.ValueDate
+	}
+	if tx.Time, err = date.time(); err != nil {
+		return tx, err
+	}
+
+	tx.Cleared = e.Status.value() == camtStatusBooked
+
+	var descr []string
+	for _, d := range e.TransactionDetails {
+		if tx.Payee == "" {
+			// The counterparty is the creditor for outgoing money and
+			// the debtor for incoming money
+			if e.CdtDbtInd == camtDebit {
+				tx.Payee = d.Creditor.name()
+			} else {
+				tx.Payee = d.Debtor.name()
+			}
+		}
+
+		for _, u := range d.Unstructured {
+			if u = strings.TrimSpace(u); u != "" {
+				descr = append(descr, u)
+			}
+		}
+	}
+
+	tx.Description = strings.Join(descr, " ")
+	if tx.Description == "" {
+		tx.Description = strings.TrimSpace(e.AddtlInfo)
+	}
+
+	switch {
+	case e.AcctSvcrRef != "":
+		tx.ImportID = "camt:" + e.AcctSvcrRef
+
+	case len(e.TransactionDetails) == 1 && e.TransactionDetails[0].AcctSvcrRef != "":
+		tx.ImportID = "camt:" + e.TransactionDetails[0].AcctSvcrRef
+
+	default:
+		tx.ImportID = ids.id("camt", tx)
+	}
+
+	return tx, nil
+}
+
+// camtAmount parses an amount and applies the sign given by the
+// credit / debit indicator
+func camtAmount(v, cdtDbtInd string) (database.Money, error) {
+	m, err := database.ParseMoney(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("parsing amount: %w", err)
+	}
+
+	switch cdtDbtInd {
+	case camtCredit:
+		return m, nil
+	case camtDebit:
+		return -m, nil
+	default:
+		return 0, fmt.Errorf("unexpected credit / debit indicator %q", cdtDbtInd)
+	}
+}
+
+func (c camtCode) value() string {
+	if c.Code != "" {
+		return strings.TrimSpace(c.Code)
+	}
+	return strings.TrimSpace(c.Text)
+}
+
+func (d camtDate) time() (t time.Time, err error) {
+	switch {
+	case d.DateTime != "":
+		v := strings.TrimSpace(d.DateTime)
+		if t, err = time.Parse(time.RFC3339, v); err != nil {
+			// ISODateTime allows to omit the offset
+			t, err = time.ParseInLocation("2006-01-02T15:04:05", v, time.Local)
+		}
+
+	case d.Date != "":
+		t, err = time.ParseInLocation(time.DateOnly, strings.TrimSpace(d.Date), time.Local)
+
+	default:
+		return t, errors.New("no date given")
+	}
+
+	if err != nil {
+		return t, fmt.Errorf("parsing time: %w", err)
+	}
+
+	return t, nil
+}
+
+func (p camtParty) name() string {
+	if p.PartyName != "" {
+		return strings.TrimSpace(p.PartyName)
+	}
+	return strings.TrimSpace(p.Name)
+}
@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestParseCAMT053(t *testing.T) {
+	f, err := os.Open("testdata/statement.camt053.xml")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseCAMT(f)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 2)
+	assert.Equal(t, "camt:REF-1", stmt.Transactions[0].ImportID)
+	assert.Equal(t, "ACME GmbH", stmt.Transactions[0].Payee)
+	assert.Equal(t, "Salary January", stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(150000), stmt.Transactions[0].Amount)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)))
+	assert.True(t, stmt.Transactions[0].Cleared)
+
+	assert.Equal(t, "Supermarket", stmt.Transactions[1].Payee)
+	assert.Equal(t, "CARD PAYMENT", stmt.Transactions[1].Description)
+	assert.Equal(t, database.Money(-4250), stmt.Transactions[1].Amount)
+	assert.NotEmpty(t, stmt.Transactions[1].ImportID)
+
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.Equal(t, database.Money(145750), *stmt.LedgerBalance)
+	assert.True(t, stmt.LedgerDate.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)))
+}
+
+func TestParseCAMT052(t *testing.T) {
+	f, err := os.Open("testdata/report.camt052.xml")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseCAMT(f)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 1)
+	assert.Equal(t, "Streaming Service", stmt.Transactions[0].Payee)
+	assert.Equal(t, "Subscription", stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(-1999), stmt.Transactions[0].Amount)
+	assert.False(t, stmt.Transactions[0].Cleared)
+
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.Equal(t, database.Money(145750), *stmt.LedgerBalance)
+}
+
+func TestImportCAMTPendingBooked(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	acc, err := dbc.CreateAccount("test", database.AccountTypeTracking)
+	require.NoError(t, err)
+
+	pending := Statement{Transactions: []database.Transaction{{
+		Time:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local),
+		Payee:    "Streaming Service",
+		Amount:   -1999,
+		ImportID: "camt:pending",
+	}}}
+
+	res, err := Import(dbc, acc.ID, pending)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Created)
+
+	// Booked entry must confirm the pending one instead of duplicating it
+	booked := Statement{Transactions: []database.Transaction{{
+		Time:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local),
+		Payee:    "Streaming Service",
+		Amount:   -1999,
+		ImportID: "camt:REF-2",
+		Cleared:  true,
+	}}}
+
+	res, err = Import(dbc, acc.ID, booked)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Created)
+	assert.Equal(t, 1, res.Matched)
+	assert.Equal(t, database.Money(-1999), res.AccountBalance)
+
+	require.Len(t, res.Transactions, 1)
+	assert.True(t, res.Transactions[0].Cleared)
+	assert.Equal(t, "camt:REF-2", res.Transactions[0].ImportID)
+
+	// Importing the report again must not bring back the pending entry
+	res, err = Import(dbc, acc.ID, pending)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Created)
+	assert.Equal(t, 0, res.Matched)
+	assert.Equal(t, 1, res.Skipped)
+	assert.Equal(t, database.Money(-1999), res.AccountBalance)
+}
@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+// ParseCSV reads a CSV export of a bank using the layout described in
+// the given profile. All transactions are marked as cleared and get an
+// ImportID derived from their content so re-importing an overlapping
+// export does not duplicate them. Rows without amount (e.g. balance
+// notes) are listed in Ignored instead.
+//
+//nolint:funlen // single flow of parsing steps
+func ParseCSV(r io.Reader, p database.ImportProfile) (stmt Statement, err error) {
+	if err = p.Validate(); err != nil {
+		return stmt, fmt.Errorf("validating profile: %w", err)
+	}
+
+	if p.Encoding != "" {
+		enc, err := htmlindex.Get(p.Encoding)
+		if err != nil {
+			return stmt, fmt.Errorf("getting encoding: %w", err)
+		}
+		r = enc.NewDecoder().Reader(r)
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if p.Delimiter != "" {
+		cr.Comma, _ = utf8.DecodeRuneInString(p.Delimiter)
+	}
+
+	ids := contentIDs{}
+
+	for line := 1; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stmt, fmt.Errorf("reading line %d: %w", line, err)
+		}
+
+		if line <= p.SkipRows || strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		tx, err := csvTransaction(row, p)
+		if err != nil {
+			return stmt, fmt.Errorf("parsing line %d: %w", line, err)
+		}
+
+		if tx.Amount == 0 {
+			stmt.Ignored = append(stmt.Ignored, fmt.Sprintf("line %d: amount is zero", line))
+			continue
+		}
+
+		tx.ImportID = ids.id("csv", tx)
+		stmt.Transactions = append(stmt.Transactions, tx)
+	}
+
+	return stmt, nil
+}
+
+func csvTransaction(row []string, p database.ImportProfile) (tx database.Transaction, err error) {
+	col := func(n int) string {
+		if n < 1 || n > len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[n-1])
+	}
+
+	dateFormat := p.DateFormat
+	if dateFormat == "" {
+		dateFormat = time.DateOnly
+	}
+
+	if tx.Time, err = time.ParseInLocation(dateFormat, col(p.TimeColumn), time.Local); err != nil {
+		return tx, fmt.Errorf("parsing time: %w", err)
+	}
+
+	if p.AmountColumn > 0 {
+		if tx.Amount, err = parseCSVAmount(col(p.AmountColumn), p); err != nil {
+			return tx, fmt.Errorf("parsing amount: %w", err)
+		}
+	} else {
+		debit, err := parseCSVAmount(col(p.DebitColumn), p)
+		if err != nil {
+			return tx, fmt.Errorf("parsing debit: %w", err)
+		}
+
+		credit, err := parseCSVAmount(col(p.CreditColumn), p)
+		if err != nil {
+			return tx, fmt.Errorf("parsing credit: %w", err)
+		}
+
+		// Some banks export debits as negative numbers, others as
+		// positive ones: money in the debit column always goes out
+		tx.Amount = credit - max(debit, -debit)
+	}
+
+	tx.Payee = col(p.PayeeColumn)
+	tx.Description = col(p.DescriptionColumn)
+	tx.Cleared = true
+
+	return tx, nil
+}
+
+// parseCSVAmount parses an amount using the separators of the profile,
+// empty values are treated as zero. The sign may also be given after
+// the number (e.g. "12,34-").
+func parseCSVAmount(v string, p database.ImportProfile) (database.Money, error) {
+	decimalSep := p.DecimalSeparator
+	if decimalSep == "" {
+		decimalSep = "."
+	}
+
+	v = strings.TrimSpace(v)
+	if p.ThousandsSeparator != "" {
+		v = strings.ReplaceAll(v, p.ThousandsSeparator, "")
+	}
+	v = strings.ReplaceAll(v, decimalSep, ".")
+
+	// Remove currency symbols and spaces around the number
+	v = strings.TrimFunc(v, func(r rune) bool {
+		return !strings.ContainsRune("+-0123456789.", r)
+	})
+
+	if v == "" {
+		return 0, nil
+	}
+
+	if sign := v[len(v)-1]; sign == '-' || sign == '+' {
+		v = string(sign) + v[:len(v)-1]
+	}
+
+	m, err := database.ParseMoney(v)
+	if err != nil {
+		return 0, fmt.Errorf("parsing amount: %w", err)
+	}
+
+	return m, nil
+}
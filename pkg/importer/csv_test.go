@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+var testCSVProfile = database.ImportProfile{
+	Name:               "Test Bank",
+	Encoding:           "iso-8859-15",
+	Delimiter:          ";",
+	SkipRows:           2,
+	DateFormat:         "02.01.2006",
+	DecimalSeparator:   ",",
+	ThousandsSeparator: ".",
+	TimeColumn:         1,
+	PayeeColumn:        2,
+	DescriptionColumn:  3,
+	DebitColumn:        4,
+	CreditColumn:       5,
+}
+
+func TestParseCSV(t *testing.T) {
+	f, err := os.Open("testdata/statement.csv")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseCSV(f, testCSVProfile)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 4)
+	assert.Equal(t, "ACME GmbH", stmt.Transactions[0].Payee)
+	assert.Equal(t, "Gehalt", stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(150000), stmt.Transactions[0].Amount)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)))
+	assert.True(t, stmt.Transactions[0].Cleared)
+
+	assert.Equal(t, "Bäckerei", stmt.Transactions[1].Payee)
+	assert.Equal(t, "Brötchen", stmt.Transactions[1].Description)
+	assert.Equal(t, database.Money(-350), stmt.Transactions[1].Amount)
+
+	// Identical rows must not share their ImportID
+	assert.NotEqual(t, stmt.Transactions[1].ImportID, stmt.Transactions[2].ImportID)
+
+	// Positive debit with currency symbol
+	assert.Equal(t, database.Money(-4250), stmt.Transactions[3].Amount)
+	assert.Nil(t, stmt.LedgerBalance)
+}
+
+func TestParseCSVAmountColumn(t *testing.T) {
+	stmt, err := ParseCSV(
+		strings.NewReader("date,amount,payee\n2024-01-02,\"1,234.56\",ACME\n"),
+		database.ImportProfile{
+			Name:               "Simple",
+			SkipRows:           1,
+			ThousandsSeparator: ",",
+			TimeColumn:         1,
+			AmountColumn:       2,
+			PayeeColumn:        3,
+		},
+	)
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 1)
+	assert.Equal(t, database.Money(123456), stmt.Transactions[0].Amount)
+	assert.Equal(t, "ACME", stmt.Transactions[0].Payee)
+}
+
+func TestParseCSVSkipsZeroAmounts(t *testing.T) {
+	stmt, err := ParseCSV(
+		strings.NewReader("2024-01-02;ACME;1.234,56\n2024-01-03;Balance;0,00\n2024-01-04;Shop;12,34-\n"),
+		database.ImportProfile{
+			Name:               "Trailing sign",
+			Delimiter:          ";",
+			DecimalSeparator:   ",",
+			ThousandsSeparator: ".",
+			TimeColumn:         1,
+			PayeeColumn:        2,
+			AmountColumn:       3,
+		},
+	)
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 2)
+	assert.Equal(t, database.Money(123456), stmt.Transactions[0].Amount)
+	assert.Equal(t, database.Money(-1234), stmt.Transactions[1].Amount)
+	assert.Equal(t, []string{"line 2: amount is zero"}, stmt.Ignored)
+}
+
+func TestImportCSV(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	acc, err := dbc.CreateAccount("test", database.AccountTypeTracking)
+	require.NoError(t, err)
+
+	// Manually entered transaction to be matched by the import
+	manual, err := dbc.CreateTransaction(database.Transaction{
+		Time:        time.Date(2024, 1, 5, 12, 0, 0, 0, time.Local),
+		Payee:       "Supermarket",
+		Description: "entered by hand",
+		Amount:      -4250,
+		Account:     uuid.NullUUID{UUID: acc.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	f, err := os.Open("testdata/statement.csv")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseCSV(f, testCSVProfile)
+	require.NoError(t, err)
+
+	// Preview must not store anything
+	res, err := Preview(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Created)
+	assert.Equal(t, 1, res.Matched)
+	assert.Equal(t, database.Money(150000-350-350-4250), res.AccountBalance)
+
+	txs, err := dbc.ListTransactionsByAccount(acc.ID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, txs, 1)
+
+	res, err = Import(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Created)
+	assert.Equal(t, 1, res.Matched)
+	assert.Equal(t, database.Money(150000-350-350-4250), res.AccountBalance)
+
+	matched, err := dbc.GetTransactionByID(manual.ID)
+	require.NoError(t, err)
+	assert.True(t, matched.Cleared)
+	assert.Equal(t, stmt.Transactions[3].ImportID, matched.ImportID)
+	assert.Equal(t, "entered by hand", matched.Description)
+
+	// Importing the same statement again must not double the entries
+	res, err = Import(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Created)
+	assert.Equal(t, 0, res.Matched)
+	assert.Equal(t, 4, res.Skipped)
+}
@@ -0,0 +1,252 @@
+// Package importer contains parsers for bank statement formats and
+// the pipeline to import parsed statements into the database
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+type (
+	// contentIDs generates ImportIDs for formats not having a stable
+	// reference for their entries. As identical entries (i.e. two
+	// coffees at the same day) must not be considered duplicates of
+	// each other, the number of identical entries seen before is part
+	// of the ID.
+	contentIDs map[string]int
+
+	// Statement represents the parsed content of a bank statement
+	Statement struct {
+		// Transactions contains the parsed entries of the statement.
+		// The Account of the transactions is set during import.
+		Transactions []database.Transaction
+		// LedgerBalance is the closing balance reported by the bank
+		// (nil if the statement does not contain one)
+		LedgerBalance *database.Money
+		// LedgerDate is the time the LedgerBalance refers to
+		LedgerDate time.Time
+		// OpeningBalance is the balance reported by the bank before the
+		// first transaction of the statement (nil if the statement does
+		// not contain one)
+		OpeningBalance *database.Money
+
+		// Categories maps placeholder IDs used as Category in the
+		// transactions and their splits to category names. They are
+		// resolved on import, missing categories are created.
+		Categories map[uuid.UUID]string
+		// Transfers maps the index of a transaction to the name of the
+		// account the money was transferred from / to
+		Transfers map[int]string
+
+		// Ignored describes the entries of the statement not contained
+		// in the Transactions as they can not be imported (e.g. rows
+		// without amount)
+		Ignored []string
+	}
+
+	// Result contains the outcome of an Import
+	Result struct {
+		database.ImportResult
+		// LedgerBalance is the closing balance reported in the statement
+		LedgerBalance *database.Money `json:"ledgerBalance"`
+		// LedgerDate is the time the LedgerBalance refers to
+		LedgerDate *time.Time `json:"ledgerDate"`
+		// AccountBalance is the balance of the account after the import
+		AccountBalance database.Money `json:"accountBalance"`
+		// Difference is AccountBalance - LedgerBalance and therefore
+		// zero if the account matches the statement
+		Difference *database.Money `json:"difference"`
+		// OpeningBalance is the opening balance reported in the statement
+		OpeningBalance *database.Money `json:"openingBalance,omitempty"`
+		// OpeningDifference is the account balance without the
+		// transactions of the statement minus the OpeningBalance and
+		// therefore zero if the account matches the statement
+		OpeningDifference *database.Money `json:"openingDifference,omitempty"`
+		// Ignored describes the entries of the statement which were not
+		// imported
+		Ignored []string `json:"ignored,omitempty"`
+	}
+)
+
+// Import stores the transactions of the statement in the given
+// account skipping those already imported before and compares the
+// resulting account balance with the balance reported in the statement
+func Import(dbc *database.Client, acc uuid.UUID, stmt Statement) (res Result, err error) {
+	txs, transfers, categories, err := resolveReferences(dbc, acc, stmt, true)
+	if err != nil {
+		return res, fmt.Errorf("resolving references: %w", err)
+	}
+
+	if res.ImportResult, err = dbc.ImportTransactions(acc, txs, transfers, categories); err != nil {
+		return res, fmt.Errorf("importing transactions: %w", err)
+	}
+
+	return compareBalance(dbc, acc, stmt, res, 0)
+}
+
+// Preview executes the same steps as Import without storing anything.
+// The AccountBalance in the result is the balance the account would
+// have after the import. Missing categories are not created but
+// previewed as UnallocatedMoney.
+func Preview(dbc *database.Client, acc uuid.UUID, stmt Statement) (res Result, err error) {
+	txs, transfers, categories, err := resolveReferences(dbc, acc, stmt, false)
+	if err != nil {
+		return res, fmt.Errorf("resolving references: %w", err)
+	}
+
+	if res.ImportResult, err = dbc.PreviewImportTransactions(acc, txs, transfers, categories); err != nil {
+		return res, fmt.Errorf("previewing transactions: %w", err)
+	}
+
+	// Matched transactions already are part of the balance, the created
+	// ones have been rolled back and need to be added
+	var pending database.Money
+	for _, tx := range res.Transactions {
+		if tx.Status == database.ImportStatusCreated {
+			pending += tx.Amount
+		}
+	}
+
+	return compareBalance(dbc, acc, stmt, res, pending)
+}
+
+// compareBalance fills the balances of the result and compares them to
+// the ones in the statement. The pending amount is added to the
+// balance of the account.
+func compareBalance(dbc *database.Client, acc uuid.UUID, stmt Statement, res Result, pending database.Money) (Result, error) {
+	bal, err := dbc.GetAccountBalance(acc)
+	if err != nil {
+		return res, fmt.Errorf("getting account balance: %w", err)
+	}
+	res.AccountBalance = bal.Balance + pending
+	res.Ignored = stmt.Ignored
+
+	if stmt.LedgerBalance != nil {
+		diff := res.AccountBalance - *stmt.LedgerBalance
+		res.LedgerBalance = stmt.LedgerBalance
+		res.LedgerDate = &stmt.LedgerDate
+		res.Difference = &diff
+	}
+
+	if stmt.OpeningBalance != nil {
+		diff := res.AccountBalance - *stmt.OpeningBalance
+		for _, tx := range stmt.Transactions {
+			diff -= tx.Amount
+		}
+		res.OpeningBalance = stmt.OpeningBalance
+		res.OpeningDifference = &diff
+	}
+
+	return res, nil
+}
+
+// resolveReferences returns a copy of the transactions of the
+// statement having the category placeholders replaced with the IDs of
+// the categories having that name and looks up the transfer accounts
+// by their name. Placeholders of missing categories are kept and
+// returned to be created by the import if create is set. Categories
+// are removed for tracking accounts as they can not have categories.
+//
+//nolint:gocognit,gocyclo // single flow of resolving steps
+//revive:disable-next-line:flag-parameter // preview must not create
+func resolveReferences(dbc *database.Client, acc uuid.UUID, stmt Statement, create bool) ([]database.Transaction, database.ImportTransfers, database.ImportCategories, error) {
+	txs := slices.Clone(stmt.Transactions)
+	for i := range txs {
+		txs[i].Splits = slices.Clone(txs[i].Splits)
+	}
+
+	if len(stmt.Categories) == 0 && len(stmt.Transfers) == 0 {
+		return txs, nil, nil, nil
+	}
+
+	account, err := dbc.GetAccount(acc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting account: %w", err)
+	}
+
+	accounts, err := dbc.ListAccounts(true)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("listing accounts: %w", err)
+	}
+
+	var (
+		byName     = map[string]uuid.UUID{}
+		categories = map[string]uuid.UUID{}
+		missing    = database.ImportCategories{}
+	)
+	for _, a := range accounts {
+		if a.Type == database.AccountTypeCategory {
+			categories[a.Name] = a.ID
+		} else {
+			byName[a.Name] = a.ID
+		}
+	}
+
+	resolve := func(cat uuid.NullUUID) uuid.NullUUID {
+		name, ok := stmt.Categories[cat.UUID]
+		if !cat.Valid || !ok {
+			return cat
+		}
+
+		if account.Type != database.AccountTypeBudget {
+			return uuid.NullUUID{}
+		}
+
+		if id, ok := categories[name]; ok {
+			return uuid.NullUUID{UUID: id, Valid: true}
+		}
+
+		if !create {
+			return uuid.NullUUID{UUID: dbc.Budget().UnallocatedMoney, Valid: true}
+		}
+
+		// Created by the import inside its transaction, other
+		// placeholders of the same name share this one
+		categories[name] = cat.UUID
+		missing[cat.UUID] = name
+
+		return cat
+	}
+
+	for i := range txs {
+		tx := &txs[i]
+		tx.Category = resolve(tx.Category)
+
+		for j := range tx.Splits {
+			tx.Splits[j].Category = resolve(tx.Splits[j].Category)
+		}
+
+		if account.Type != database.AccountTypeBudget {
+			tx.Splits = nil
+		}
+	}
+
+	transfers := make(database.ImportTransfers, len(stmt.Transfers))
+	for i, name := range stmt.Transfers {
+		id, ok := byName[name]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("transfer account %q not found", name)
+		}
+		transfers[i] = id
+	}
+
+	return txs, transfers, missing, nil
+}
+
+// id returns the ImportID for the given transaction prefixed with the
+// given format name
+func (c contentIDs) id(prefix string, tx database.Transaction) string {
+	key := strings.Join([]string{tx.Time.Format(time.DateOnly), tx.Amount.String(), tx.Payee, tx.Description}, "\x00")
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d", key, c[key]))
+	c[key]++
+
+	return prefix + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,260 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+var (
+	mt940BalanceRegex = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})([0-9,]+)$`)
+	mt940EntryRegex   = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])[A-Z]?([0-9,]+)[A-Z][A-Z0-9]{3}([^/\n]*)(?://([^\n]*))?`)
+	mt940SepaKeyRegex = regexp.MustCompile(`([A-Z]{4})\+`)
+)
+
+type (
+	mt940Field struct {
+		tag   string
+		value string
+	}
+)
+
+// ParseMT940 reads a SWIFT MT940 statement file which might contain
+// multiple statements. All transactions are marked as cleared. The
+// opening balance of the first and the closing balance of the last
+// statement are reported.
+//
+//nolint:gocognit // single flow of parsing steps
+func ParseMT940(r io.Reader) (stmt Statement, err error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return stmt, fmt.Errorf("reading fields: %w", err)
+	}
+
+	ids := contentIDs{}
+
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+
+		switch f.tag {
+		case "60F", "60M":
+			if stmt.OpeningBalance != nil {
+				// Only the opening balance of the first statement counts
+				continue
+			}
+
+			amount, _, err := parseMT940Balance(f.value)
+			if err != nil {
+				return stmt, fmt.Errorf("parsing opening balance: %w", err)
+			}
+			stmt.OpeningBalance = &amount
+
+		case "61":
+			tx, bankRef, err := parseMT940Entry(f.value)
+			if err != nil {
+				return stmt, fmt.Errorf("parsing entry %q: %w", f.value, err)
+			}
+
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				tx.Payee, tx.Description = parseMT940Info(fields[i+1].value)
+				i++
+			}
+
+			if bankRef != "" && bankRef != "NONREF" {
+				tx.ImportID = "mt940:" + bankRef
+			} else {
+				tx.ImportID = ids.id("mt940", tx)
+			}
+
+			stmt.Transactions = append(stmt.Transactions, tx)
+
+		case "62F", "62M":
+			amount, date, err := parseMT940Balance(f.value)
+			if err != nil {
+				return stmt, fmt.Errorf("parsing closing balance: %w", err)
+			}
+			stmt.LedgerBalance = &amount
+			stmt.LedgerDate = date
+		}
+	}
+
+	if stmt.OpeningBalance == nil && stmt.LedgerBalance == nil && len(stmt.Transactions) == 0 {
+		return stmt, errors.New("document contains no statement")
+	}
+
+	return stmt, nil
+}
+
+// readMT940Fields splits the document into its tagged fields. Lines
+// not starting with a tag are continuations of the previous field,
+// message headers / trailers are skipped.
+func readMT940Fields(r io.Reader) (fields []mt940Field, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case line == "-", strings.HasPrefix(line, "{"), strings.HasPrefix(line, "-}"):
+			// End of message or SWIFT header / trailer blocks
+
+		case len(line) > 1 && line[0] == ':' && strings.Index(line[1:], ":") > 0:
+			tag, value, _ := strings.Cut(line[1:], ":")
+			fields = append(fields, mt940Field{tag: tag, value: value})
+
+		case len(fields) > 0:
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning document: %w", err)
+	}
+
+	return fields, nil
+}
+
+// parseMT940Amount parses an amount using a comma as decimal separator
+func parseMT940Amount(v string) (database.Money, error) {
+	m, err := database.ParseMoney(strings.ReplaceAll(v, ",", "."))
+	if err != nil {
+		return 0, fmt.Errorf("parsing amount: %w", err)
+	}
+
+	return m, nil
+}
+
+// parseMT940Balance parses a balance field (:60F:, :62F:) in the format
+// <C|D><YYMMDD><currency><amount>
+func parseMT940Balance(v string) (database.Money, time.Time, error) {
+	m := mt940BalanceRegex.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return 0, time.Time{}, fmt.Errorf("unexpected balance format %q", v)
+	}
+
+	date, err := time.ParseInLocation("060102", m[2], time.Local)
+	if err != nil {
+		return 0, date, fmt.Errorf("parsing date: %w", err)
+	}
+
+	amount, err := parseMT940Amount(m[4])
+	if err != nil {
+		return 0, date, err
+	}
+
+	if m[1] == "D" {
+		amount = -amount
+	}
+
+	return amount, date, nil
+}
+
+// parseMT940Entry parses a statement line (:61:) in the format
+// <value date YYMMDD>[<entry date MMDD>]<[R]C|[R]D>[<funds code>]
+// <amount><type><customer ref>[//<bank ref>] and returns the bank
+// reference
+func parseMT940Entry(v string) (tx database.Transaction, bankRef string, err error) {
+	m := mt940EntryRegex.FindStringSubmatch(v)
+	if m == nil {
+		return tx, "", errors.New("unexpected entry format")
+	}
+
+	valueDate, err := time.ParseInLocation("060102", m[1], time.Local)
+	if err != nil {
+		return tx, "", fmt.Errorf("parsing value date: %w", err)
+	}
+	tx.Time = valueDate
+
+	if m[2] != "" {
+		// The entry date does not carry a year, it might be in the
+		// year before or after the value date
+		entryDate, err := time.ParseInLocation("0102", m[2], time.Local)
+		if err != nil {
+			return tx, "", fmt.Errorf("parsing entry date: %w", err)
+		}
+
+		tx.Time = entryDate.AddDate(valueDate.Year(), 0, 0)
+		switch {
+		case tx.Time.Sub(valueDate) > 180*24*time.Hour: //revive:disable-line:add-constant // half a year
+			tx.Time = tx.Time.AddDate(-1, 0, 0)
+		case valueDate.Sub(tx.Time) > 180*24*time.Hour: //revive:disable-line:add-constant // half a year
+			tx.Time = tx.Time.AddDate(1, 0, 0)
+		}
+	}
+
+	if tx.Amount, err = parseMT940Amount(m[4]); err != nil {
+		return tx, "", err
+	}
+
+	// Reversals (RC, RD) carry the sign of the reversed entry
+	if m[3] == "D" || m[3] == "RC" {
+		tx.Amount = -tx.Amount
+	}
+
+	tx.Cleared = true
+
+	return tx, strings.TrimSpace(m[6]), nil
+}
+
+// parseMT940Info extracts payee and purpose from an information field
+// (:86:). Structured fields as used by German banks
+// (<code><sep>00<booking text><sep>20<purpose>…<sep>32<name>…) are
+// split into their subfields, unstructured fields are used as purpose.
+func parseMT940Info(v string) (payee, purpose string) {
+	v = strings.ReplaceAll(v, "\n", "")
+
+	if len(v) < 4 || !isDigits(v[:3]) || strings.ContainsRune("0123456789", rune(v[3])) {
+		return "", strings.TrimSpace(v)
+	}
+
+	var (
+		sep      = v[3:4]
+		names    []string
+		purposes []string
+	)
+
+	for _, sub := range strings.Split(v[4:], sep) {
+		if len(sub) < 2 || !isDigits(sub[:2]) {
+			continue
+		}
+
+		switch code, value := sub[:2], sub[2:]; {
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			purposes = append(purposes, value)
+		case code == "32", code == "33":
+			names = append(names, value)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(names, "")), parseSEPAPurpose(strings.Join(purposes, ""))
+}
+
+// parseSEPAPurpose returns the remittance information (SVWZ+) of a
+// purpose containing SEPA keys (EREF+, MREF+, SVWZ+, …) or the whole
+// purpose if there is no remittance information
+func parseSEPAPurpose(v string) string {
+	keys := mt940SepaKeyRegex.FindAllStringSubmatchIndex(v, -1)
+	for i, k := range keys {
+		if v[k[2]:k[3]] != "SVWZ" {
+			continue
+		}
+
+		end := len(v)
+		if i+1 < len(keys) {
+			end = keys[i+1][0]
+		}
+
+		return strings.TrimSpace(v[k[1]:end])
+	}
+
+	return strings.TrimSpace(v)
+}
+
+func isDigits(v string) bool {
+	return strings.Trim(v, "0123456789") == ""
+}
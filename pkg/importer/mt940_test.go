@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestParseMT940(t *testing.T) {
+	f, err := os.Open("testdata/statement.mt940")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseMT940(f)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 4)
+	assert.Equal(t, "mt940:BANKREF1", stmt.Transactions[0].ImportID)
+	assert.Equal(t, "ACME GmbH", stmt.Transactions[0].Payee)
+	assert.Equal(t, "Gehalt Januar 2024 ACME", stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(150000), stmt.Transactions[0].Amount)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)))
+	assert.True(t, stmt.Transactions[0].Cleared)
+
+	assert.Equal(t, "Supermarkt", stmt.Transactions[1].Payee)
+	assert.Equal(t, "Einkauf Filiale 12", stmt.Transactions[1].Description)
+	assert.Equal(t, database.Money(-4250), stmt.Transactions[1].Amount)
+
+	// Reversal of a debit is incoming money
+	assert.Empty(t, stmt.Transactions[2].Payee)
+	assert.Equal(t, "Storno Gebuehr", stmt.Transactions[2].Description)
+	assert.Equal(t, database.Money(1000), stmt.Transactions[2].Amount)
+	assert.True(t, stmt.Transactions[2].Time.Equal(time.Date(2023, 12, 31, 0, 0, 0, 0, time.Local)))
+
+	// Entry date is in the year after the value date
+	assert.True(t, stmt.Transactions[3].Time.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)))
+	assert.NotEqual(t, stmt.Transactions[1].ImportID, stmt.Transactions[3].ImportID)
+
+	require.NotNil(t, stmt.OpeningBalance)
+	assert.Equal(t, database.Money(0), *stmt.OpeningBalance)
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.Equal(t, database.Money(144751), *stmt.LedgerBalance)
+	assert.True(t, stmt.LedgerDate.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)))
+}
+
+func TestImportMT940(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	acc, err := dbc.CreateAccount("test", database.AccountTypeTracking)
+	require.NoError(t, err)
+
+	f, err := os.Open("testdata/statement.mt940")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseMT940(f)
+	require.NoError(t, err)
+
+	// Preview must compare the balance including the new transactions
+	res, err := Preview(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, database.Money(144751), res.AccountBalance)
+	require.NotNil(t, res.Difference)
+	assert.Equal(t, database.Money(0), *res.Difference)
+
+	res, err = Import(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 4, res.Created)
+	assert.Equal(t, database.Money(144751), res.AccountBalance)
+	require.NotNil(t, res.Difference)
+	assert.Equal(t, database.Money(0), *res.Difference)
+	require.NotNil(t, res.OpeningDifference)
+	assert.Equal(t, database.Money(0), *res.OpeningDifference)
+}
@@ -0,0 +1,246 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+type (
+	// ofxNode represents an element of an OFX document. Elements
+	// containing a value are leafs, all others are aggregates.
+	ofxNode struct {
+		name     string
+		value    string
+		children []*ofxNode
+	}
+)
+
+// ParseOFX reads an OFX 1.x (SGML) or OFX 2.x (XML) bank or credit-card
+// statement. All transactions are marked as cleared and carry the
+// FITID as ImportID. Transactions without amount (e.g. card
+// verifications) are listed in Ignored instead.
+func ParseOFX(r io.Reader) (stmt Statement, err error) {
+	root, err := parseOFXTree(r)
+	if err != nil {
+		return stmt, fmt.Errorf("parsing document: %w", err)
+	}
+
+	stmtRs := root.findAll("STMTRS")
+	stmtRs = append(stmtRs, root.findAll("CCSTMTRS")...)
+	if len(stmtRs) != 1 {
+		return stmt, fmt.Errorf("expected one statement, found %d", len(stmtRs))
+	}
+
+	for _, trn := range stmtRs[0].findAll("STMTTRN") {
+		tx, err := ofxTransaction(trn)
+		if err != nil {
+			return stmt, fmt.Errorf("parsing transaction %q: %w", trn.get("FITID"), err)
+		}
+
+		if tx.Amount == 0 {
+			stmt.Ignored = append(stmt.Ignored, fmt.Sprintf("transaction %q: amount is zero", tx.ImportID))
+			continue
+		}
+
+		stmt.Transactions = append(stmt.Transactions, tx)
+	}
+
+	if bal := stmtRs[0].findAll("LEDGERBAL"); len(bal) > 0 {
+		amount, err := parseOFXAmount(bal[0].get("BALAMT"))
+		if err != nil {
+			return stmt, fmt.Errorf("parsing ledger balance: %w", err)
+		}
+
+		if stmt.LedgerDate, err = parseOFXTime(bal[0].get("DTASOF")); err != nil {
+			return stmt, fmt.Errorf("parsing ledger balance date: %w", err)
+		}
+
+		stmt.LedgerBalance = &amount
+	}
+
+	return stmt, nil
+}
+
+func ofxTransaction(trn *ofxNode) (tx database.Transaction, err error) {
+	if tx.Amount, err = parseOFXAmount(trn.get("TRNAMT")); err != nil {
+		return tx, fmt.Errorf("parsing amount: %w", err)
+	}
+
+	if tx.Time, err = parseOFXTime(trn.get("DTPOSTED")); err != nil {
+		return tx, fmt.Errorf("parsing date: %w", err)
+	}
+
+	tx.ImportID = trn.get("FITID")
+	tx.Payee = trn.get("NAME")
+	if payee := trn.findAll("PAYEE"); tx.Payee == "" && len(payee) > 0 {
+		tx.Payee = payee[0].get("NAME")
+	}
+	tx.Description = trn.get("MEMO")
+	tx.Cleared = true
+
+	if tx.ImportID == "" {
+		return tx, errors.New("missing FITID")
+	}
+
+	return tx, nil
+}
+
+// parseOFXTree parses SGML and XML flavored OFX documents into a tree.
+// Leaf elements do not need to be closed (SGML), closing tags of leaf
+// elements are ignored (XML).
+//
+//nolint:gocognit,gocyclo // simple tokenizer
+func parseOFXTree(r io.Reader) (*ofxNode, error) {
+	var (
+		br    = bufio.NewReader(r)
+		root  = &ofxNode{}
+		stack = []*ofxNode{root}
+		last  *ofxNode
+	)
+
+	for {
+		// Skip everything up to the next tag (SGML headers, whitespace)
+		text, err := br.ReadString('<')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading document: %w", err)
+		}
+
+		if v := strings.TrimSpace(strings.TrimSuffix(text, "<")); v != "" && last != nil {
+			last.value = html.UnescapeString(v)
+			last = nil
+		}
+
+		tag, err := br.ReadString('>')
+		if err != nil {
+			return nil, fmt.Errorf("reading tag: %w", err)
+		}
+		tag = strings.TrimSuffix(tag, ">")
+
+		switch {
+		case strings.HasPrefix(tag, "?"), strings.HasPrefix(tag, "!"):
+			// Processing instruction or comment
+
+		case strings.TrimSpace(tag) == "":
+			return nil, errors.New("empty tag")
+
+		case strings.HasPrefix(tag, "/"):
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			last = nil
+
+		default:
+			node := &ofxNode{name: strings.ToUpper(strings.Fields(tag)[0])}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+
+			// Whether the element is an aggregate or a leaf is decided by
+			// its content: leafs directly contain a value
+			next, err := br.Peek(1)
+			for err == nil && (next[0] == ' ' || next[0] == '\t' || next[0] == '\r' || next[0] == '\n') {
+				_, _ = br.ReadByte()
+				next, err = br.Peek(1)
+			}
+
+			if err == nil && next[0] == '<' {
+				stack = append(stack, node)
+				last = nil
+			} else {
+				last = node
+			}
+		}
+	}
+
+	return root, nil
+}
+
+// parseOFXAmount parses an OFX amount, some banks use a comma as
+// decimal separator
+func parseOFXAmount(v string) (database.Money, error) {
+	v = strings.TrimSpace(v)
+	if !strings.Contains(v, ".") {
+		v = strings.ReplaceAll(v, ",", ".")
+	}
+
+	m, err := database.ParseMoney(v)
+	if err != nil {
+		return 0, fmt.Errorf("parsing amount: %w", err)
+	}
+
+	return m, nil
+}
+
+// parseOFXTime parses an OFX date-time in the format
+// YYYYMMDD[HHMMSS[.XXX]][[offset[:TZ]]] where the time and the offset
+// are optional and missing offsets are treated as UTC
+func parseOFXTime(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+
+	loc := time.UTC
+	if i := strings.Index(v, "["); i >= 0 {
+		tz := strings.TrimSuffix(v[i+1:], "]")
+		v = v[:i]
+
+		offset, _, _ := strings.Cut(tz, ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing timezone offset: %w", err)
+		}
+		loc = time.FixedZone(tz, int(hours*float64(time.Hour/time.Second)))
+	}
+
+	v, _, _ = strings.Cut(v, ".")
+
+	for _, layout := range []string{"20060102150405", "200601021504", "20060102"} {
+		if len(v) != len(layout) {
+			continue
+		}
+
+		t, err := time.ParseInLocation(layout, v, loc)
+		if err != nil {
+			return t, fmt.Errorf("parsing time: %w", err)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unexpected time format %q", v)
+}
+
+// findAll returns all descendants having the given name
+func (n *ofxNode) findAll(name string) (nodes []*ofxNode) {
+	for _, c := range n.children {
+		if c.name == name {
+			nodes = append(nodes, c)
+		}
+		nodes = append(nodes, c.findAll(name)...)
+	}
+
+	return nodes
+}
+
+// get returns the value of the first direct child having the given
+// name
+func (n *ofxNode) get(name string) string {
+	for _, c := range n.children {
+		if c.name == name {
+			return c.value
+		}
+	}
+
+	return ""
+}
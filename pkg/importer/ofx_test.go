@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestParseOFXSGML(t *testing.T) {
+	f, err := os.Open("testdata/statement.ofx")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseOFX(f)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 2)
+	assert.Equal(t, "2024010201", stmt.Transactions[0].ImportID)
+	assert.Equal(t, "ACME Inc.", stmt.Transactions[0].Payee)
+	assert.Equal(t, "Salary & Bonus", stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(150000), stmt.Transactions[0].Amount)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 1, 2, 17, 0, 0, 0, time.UTC)))
+	assert.True(t, stmt.Transactions[0].Cleared)
+
+	assert.Equal(t, database.Money(-4250), stmt.Transactions[1].Amount)
+	assert.Equal(t, "Supermarket", stmt.Transactions[1].Payee)
+
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.Equal(t, database.Money(145750), *stmt.LedgerBalance)
+	assert.True(t, stmt.LedgerDate.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestParseOFXEmptyTag(t *testing.T) {
+	for _, body := range []string{"<OFX><>", "<OFX>< >"} {
+		_, err := ParseOFX(strings.NewReader(body))
+		assert.Error(t, err, body)
+	}
+}
+
+func TestParseOFXXML(t *testing.T) {
+	f, err := os.Open("testdata/statement.qfx")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseOFX(f)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 1)
+	assert.Equal(t, "CC-1", stmt.Transactions[0].ImportID)
+	assert.Equal(t, "Streaming Service", stmt.Transactions[0].Payee)
+	assert.Empty(t, stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(-1999), stmt.Transactions[0].Amount)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 1, 5, 8, 30, 0, 0, time.UTC)))
+	assert.Equal(t, []string{`transaction "CC-2": amount is zero`}, stmt.Ignored)
+
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.Equal(t, database.Money(-1999), *stmt.LedgerBalance)
+}
+
+func TestImportOFX(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	acc, err := dbc.CreateAccount("test", database.AccountTypeBudget)
+	require.NoError(t, err)
+
+	f, err := os.Open("testdata/statement.ofx")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseOFX(f)
+	require.NoError(t, err)
+
+	res, err := Import(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Created)
+	assert.Equal(t, 0, res.Skipped)
+	assert.Equal(t, database.Money(145750), res.AccountBalance)
+	require.NotNil(t, res.Difference)
+	assert.Equal(t, database.Money(0), *res.Difference)
+
+	// Importing the same statement again must not double the entries
+	res, err = Import(dbc, acc.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Created)
+	assert.Equal(t, 2, res.Skipped)
+	assert.Equal(t, database.Money(145750), res.AccountBalance)
+}
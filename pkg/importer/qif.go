@@ -0,0 +1,271 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+const qifMaxMonth = 12
+
+type (
+	qifRecord struct {
+		date     string
+		amount   string
+		payee    string
+		memo     string
+		category string
+		cleared  string
+		splits   []qifSplit
+	}
+
+	qifSplit struct {
+		category string
+		memo     string
+		amount   string
+	}
+)
+
+// ParseQIF reads the !Type:Bank and !Type:CCard records of a QIF file,
+// records of other types are ignored. Categories (L lines) are
+// resolved to category accounts by their name on import, transfers
+// ([Account] syntax) become paired transactions.
+//
+// As QIF does not define the order of day and month in dates, the
+// month is expected first (as Quicken does) unless one of the dates
+// has a first component not being a valid month.
+func ParseQIF(r io.Reader) (stmt Statement, err error) {
+	records, err := readQIFRecords(r)
+	if err != nil {
+		return stmt, fmt.Errorf("reading records: %w", err)
+	}
+
+	dayFirst := false
+	for _, rec := range records {
+		if p := qifDateParts(rec.date); len(p) == 3 && len(p[0]) < 4 { //revive:disable-line:add-constant // day, month, year
+			if n, _ := strconv.Atoi(p[0]); n > qifMaxMonth {
+				dayFirst = true
+				break
+			}
+		}
+	}
+
+	var (
+		ids          = contentIDs{}
+		placeholders = map[string]uuid.UUID{}
+	)
+
+	category := func(name string) uuid.NullUUID {
+		// Classes are appended to the category using a slash
+		name, _, _ = strings.Cut(name, "/")
+		if name == "" {
+			return uuid.NullUUID{}
+		}
+
+		if _, ok := placeholders[name]; !ok {
+			placeholders[name] = uuid.Must(uuid.NewRandom())
+			if stmt.Categories == nil {
+				stmt.Categories = map[uuid.UUID]string{}
+			}
+			stmt.Categories[placeholders[name]] = name
+		}
+
+		return uuid.NullUUID{UUID: placeholders[name], Valid: true}
+	}
+
+	for i, rec := range records {
+		tx := database.Transaction{
+			Payee:       rec.payee,
+			Description: rec.memo,
+		}
+
+		if tx.Time, err = parseQIFDate(rec.date, dayFirst); err != nil {
+			return stmt, fmt.Errorf("parsing record %d: %w", i+1, err)
+		}
+
+		if tx.Amount, err = parseQIFAmount(rec.amount); err != nil {
+			return stmt, fmt.Errorf("parsing record %d: %w", i+1, err)
+		}
+
+		switch strings.ToUpper(rec.cleared) {
+		case "*", "C":
+			tx.Cleared = true
+		case "X", "R":
+			tx.Cleared = true
+			tx.Reconciled = true
+		}
+
+		if name, ok := qifTransfer(rec.category); ok {
+			if stmt.Transfers == nil {
+				stmt.Transfers = map[int]string{}
+			}
+			stmt.Transfers[len(stmt.Transactions)] = name
+		} else if len(rec.splits) == 0 {
+			tx.Category = category(rec.category)
+		}
+
+		for _, s := range rec.splits {
+			if _, ok := qifTransfer(s.category); ok {
+				return stmt, fmt.Errorf("parsing record %d: transfers in splits are not supported", i+1)
+			}
+
+			split := database.TransactionSplit{
+				Description: s.memo,
+				Category:    category(s.category),
+			}
+
+			if split.Amount, err = parseQIFAmount(s.amount); err != nil {
+				return stmt, fmt.Errorf("parsing record %d: split: %w", i+1, err)
+			}
+
+			tx.Splits = append(tx.Splits, split)
+		}
+
+		tx.ImportID = ids.id("qif", tx)
+		stmt.Transactions = append(stmt.Transactions, tx)
+	}
+
+	return stmt, nil
+}
+
+// readQIFRecords reads the records of the supported account types
+//
+//nolint:gocyclo // simple line based format
+func readQIFRecords(r io.Reader) (records []qifRecord, err error) {
+	var (
+		scanner   = bufio.NewScanner(r)
+		supported bool
+		rec       qifRecord
+	)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if line[0] == '!' {
+			header := strings.ToLower(strings.TrimSpace(line))
+			supported = header == "!type:bank" || header == "!type:ccard"
+			rec = qifRecord{}
+			continue
+		}
+
+		if !supported {
+			continue
+		}
+
+		code, value := line[0], strings.TrimSpace(line[1:])
+
+		switch code {
+		case '^':
+			records = append(records, rec)
+			rec = qifRecord{}
+		case 'D':
+			rec.date = value
+		case 'T', 'U':
+			rec.amount = value
+		case 'P':
+			rec.payee = value
+		case 'M':
+			rec.memo = value
+		case 'L':
+			rec.category = value
+		case 'C':
+			rec.cleared = value
+		case 'S':
+			rec.splits = append(rec.splits, qifSplit{category: value})
+		case 'E':
+			if len(rec.splits) > 0 {
+				rec.splits[len(rec.splits)-1].memo = value
+			}
+		case '$':
+			if len(rec.splits) > 0 {
+				rec.splits[len(rec.splits)-1].amount = value
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning document: %w", err)
+	}
+
+	return records, nil
+}
+
+// parseQIFAmount parses an amount which might contain commas as
+// thousands separator
+func parseQIFAmount(v string) (database.Money, error) {
+	m, err := database.ParseMoney(strings.ReplaceAll(strings.TrimSpace(v), ",", ""))
+	if err != nil {
+		return 0, fmt.Errorf("parsing amount: %w", err)
+	}
+
+	return m, nil
+}
+
+// parseQIFDate parses the different date notations found in QIF files
+// (i.e. "01/02/2024", "1/ 2'24", "02.01.2024", "2024-01-02")
+func parseQIFDate(v string, dayFirst bool) (time.Time, error) {
+	p := qifDateParts(v)
+	if len(p) != 3 { //revive:disable-line:add-constant // day, month, year
+		return time.Time{}, fmt.Errorf("unexpected date format %q", v)
+	}
+
+	var n [3]int
+	for i := range p {
+		var err error
+		if n[i], err = strconv.Atoi(p[i]); err != nil {
+			return time.Time{}, fmt.Errorf("unexpected date format %q", v)
+		}
+	}
+
+	year, month, day := n[2], n[0], n[1]
+	switch {
+	case len(p[0]) == 4: //revive:disable-line:add-constant // ISO notation
+		year, month, day = n[0], n[1], n[2]
+	case dayFirst:
+		month, day = n[1], n[0]
+	}
+
+	if len(p[2]) == 2 && len(p[0]) != 4 {
+		// Two digit years: Quicken marks years after 2000 with an
+		// apostrophe, others just cut the century
+		year += 1900
+		if strings.Contains(v, "'") || year < 1970 {
+			year += 100
+		}
+	}
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if t.Month() != time.Month(month) || t.Day() != day {
+		return t, fmt.Errorf("invalid date %q", v)
+	}
+
+	return t, nil
+}
+
+// qifDateParts splits a date into its numeric components
+func qifDateParts(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return strings.ContainsRune("/.-' ", r)
+	})
+}
+
+// qifTransfer returns the account name of a category in [Account]
+// transfer syntax
+func qifTransfer(category string) (string, bool) {
+	if !strings.HasPrefix(category, "[") {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(category[1:], "]")
+	return name, true
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.luzifer.io/luzifer/accounting/pkg/database"
+)
+
+func TestParseQIF(t *testing.T) {
+	f, err := os.Open("testdata/statement.qif")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseQIF(f)
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 3)
+	assert.Equal(t, "ACME Inc.", stmt.Transactions[0].Payee)
+	assert.Equal(t, "Salary", stmt.Transactions[0].Description)
+	assert.Equal(t, database.Money(150000), stmt.Transactions[0].Amount)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)))
+	assert.True(t, stmt.Transactions[0].Cleared)
+	assert.False(t, stmt.Transactions[0].Reconciled)
+	assert.Equal(t, "Income:Salary", stmt.Categories[stmt.Transactions[0].Category.UUID])
+
+	assert.False(t, stmt.Transactions[1].Category.Valid)
+	require.Len(t, stmt.Transactions[1].Splits, 2)
+	assert.Equal(t, "Groceries", stmt.Categories[stmt.Transactions[1].Splits[0].Category.UUID])
+	assert.Equal(t, "Food", stmt.Transactions[1].Splits[0].Description)
+	assert.Equal(t, database.Money(-10000), stmt.Transactions[1].Splits[0].Amount)
+
+	assert.True(t, stmt.Transactions[2].Reconciled)
+	assert.Equal(t, map[int]string{2: "Savings"}, stmt.Transfers)
+}
+
+func TestParseQIFDayFirst(t *testing.T) {
+	stmt, err := ParseQIF(strings.NewReader("!Type:CCard\nD01.02.2024\nT-1\n^\nD13.02.2024\nT-2\n^\n"))
+	require.NoError(t, err)
+
+	require.Len(t, stmt.Transactions, 2)
+	assert.True(t, stmt.Transactions[0].Time.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)))
+	assert.True(t, stmt.Transactions[1].Time.Equal(time.Date(2024, 2, 13, 0, 0, 0, 0, time.Local)))
+}
+
+func TestImportQIF(t *testing.T) {
+	dbc, err := database.New("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+
+	checking, err := dbc.CreateAccount("Checking", database.AccountTypeBudget)
+	require.NoError(t, err)
+	savings, err := dbc.CreateAccount("Savings", database.AccountTypeBudget)
+	require.NoError(t, err)
+	groceries, err := dbc.CreateAccount("Groceries", database.AccountTypeCategory)
+	require.NoError(t, err)
+
+	f, err := os.Open("testdata/statement.qif")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	stmt, err := ParseQIF(f)
+	require.NoError(t, err)
+
+	// Preview must not create the missing categories
+	res, err := Preview(dbc, checking.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Created)
+
+	cats, err := dbc.ListAccountsByType(database.AccountTypeCategory, true)
+	require.NoError(t, err)
+	assert.Len(t, cats, 3) // Unallocated Money, Starting Balance, Groceries
+
+	// A failing import must not leave created categories behind
+	broken := stmt
+	broken.Transactions = append(slices.Clone(stmt.Transactions), database.Transaction{Time: time.Now(), Payee: "Invalid"})
+	_, err = Import(dbc, checking.ID, broken)
+	require.Error(t, err)
+
+	cats, err = dbc.ListAccountsByType(database.AccountTypeCategory, true)
+	require.NoError(t, err)
+	assert.Len(t, cats, 3)
+
+	res, err = Import(dbc, checking.ID, stmt)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Created)
+	assert.Equal(t, database.Money(150000-12000-20000), res.AccountBalance)
+
+	cats, err = dbc.ListAccountsByType(database.AccountTypeCategory, true)
+	require.NoError(t, err)
+	assert.Len(t, cats, 5) // + Income:Salary, Household
+
+	bal, err := dbc.GetAccountBalance(groceries.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.Money(-10000), bal.Balance)
+
+	// Transfer must have created a counterpart sharing the PairKey
+	bal, err = dbc.GetAccountBalance(savings.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.Money(20000), bal.Balance)
+
+	txs, err := dbc.ListTransactionsByAccount(savings.ID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.True(t, txs[0].PairKey.Valid)
+	assert.Equal(t, res.Transactions[2].PairKey, txs[0].PairKey)
+
+	// Importing the other side of the transfer matches the counterpart
+	res, err = Import(dbc, savings.ID, Statement{
+		Transactions: []database.Transaction{{
+			Time:     time.Date(2024, 1, 20, 0, 0, 0, 0, time.Local),
+			Payee:    "Transfer",
+			Amount:   20000,
+			ImportID: "qif:other",
+		}},
+		Transfers: map[int]string{0: "Checking"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Created)
+	assert.Equal(t, 1, res.Matched)
+	assert.Equal(t, database.Money(20000), res.AccountBalance)
+}